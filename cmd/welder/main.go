@@ -24,8 +24,19 @@ func run(args []string) int {
 	(&build.All{}).Mount(app)
 	(&build.Run{}).Mount(app)
 	(&build.Version{}).Mount(app)
+	(&build.Changelog{}).Mount(app)
+	(&build.Release{}).Mount(app)
 	(&build.Volumes{}).Mount(app)
 	(&build.Mutagen{}).Mount(app)
+	(&build.Ps{}).Mount(app)
+	(&build.Exec{}).Mount(app)
+	(&build.Logs{}).Mount(app)
+	(&build.Pull{}).Mount(app)
+	(&build.Bundle{}).Mount(app)
+	(&build.Schema{}).Mount(app)
+	(&build.Validate{}).Mount(app)
+	(&build.Migrate{}).Mount(app)
+	(&build.Imports{}).Mount(app)
 
 	// The `mutagen` command passes all arguments to the underlying `mutagen` command directly
 	// All other commands will go through to our kingpin application which we can manage directly here.
@@ -43,7 +43,6 @@ require (
 	go.uber.org/atomic v1.10.0
 	golang.org/x/net v0.24.0
 	golang.org/x/sync v0.7.0
-	gopkg.in/mikefarah/yaml.v2 v2.4.0
 	gopkg.in/src-d/go-billy.v4 v4.3.2
 	gopkg.in/src-d/go-git.v4 v4.13.1
 	gopkg.in/yaml.v2 v2.4.0
@@ -150,6 +149,5 @@ replace (
 	github.com/moby/moby => github.com/moby/moby v20.10.9+incompatible
 	github.com/opencontainers/runc => github.com/opencontainers/runc v1.0.0 // VULN-536691
 	go.opencensus.io => go.opencensus.io v0.23.0
-	gopkg.in/mikefarah/yaml.v2 => github.com/mikefarah/yaml/v2 v2.4.0
 	k8s.io/legacy-cloud-providers => k8s.io/legacy-cloud-providers v0.22.0-beta.0 // VULN-515069. Use stable version when available
 )
@@ -50,6 +50,10 @@ func (o *BitbucketPipelines) registerExecCommand(cmd *kingpin.CmdClause) {
 	execute.Flag("atlassian", "Enable Atlassian internal tweaks (default)").
 		Default("true").
 		BoolVar(&o.Params.AtlassianMode)
+	execute.Flag("shell", "Interpreter to execute scripts with (e.g. bash)").
+		StringVar(&o.Params.Shell)
+	execute.Flag("strict-shell", "Execute scripts with 'set -eo pipefail' semantics").
+		BoolVar(&o.Params.StrictShell)
 
 	bbpFile := filepath.Join(o.curDir, "bitbucket-pipelines.yml")
 	if pp, err := pipelines.NewBitbucketPipelines(bbpFile, ctx); err == nil {
@@ -0,0 +1,66 @@
+package build
+
+import (
+	"github.com/alecthomas/kingpin"
+
+	"github.com/simple-container-com/welder/pkg/welder"
+)
+
+type Bundle struct {
+	CommonParams
+	BuildParams
+
+	OutputPath  string
+	InputPath   string
+	SkipMutagen bool
+	Attempts    int
+}
+
+func (o *Bundle) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("bundle", "Export/import images referenced by the project for air-gapped environments")
+	o.registerCommonFlags(cmd)
+	o.registerBuildFlags(cmd)
+	cmd.Flag("skip-mutagen", "Do not export/import mutagen binary").
+		BoolVar(&o.SkipMutagen)
+	exportCmd := cmd.Command("export", "Export all images referenced by the project (and mutagen binary) into a single archive")
+	exportCmd.Flag("output", "Path to the bundle archive to write").
+		Short('o').
+		Default("welder-bundle.tar.gz").
+		StringVar(&o.OutputPath)
+	exportCmd.Flag("attempts", "Number of attempts to pull each image missing locally").
+		Default("3").
+		IntVar(&o.Attempts)
+	exportCmd.Action(registerAction(o.Export))
+	importCmd := cmd.Command("import", "Load images from the bundle archive into the local Docker daemon (and install mutagen binary)")
+	importCmd.Arg("bundle", "Path to the bundle archive to read").
+		Required().
+		ExistingFileVar(&o.InputPath)
+	importCmd.Action(registerAction(o.Import))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Bundle) Export() error {
+	buildCtx, err := o.ToBuildCtx("bundle", o.CommonParams)
+	if err != nil {
+		return err
+	}
+	_, err = buildCtx.ExportBundle(welder.BundleExportOpts{
+		OutputPath:  o.OutputPath,
+		SkipMutagen: o.SkipMutagen,
+		Pull:        welder.PullOpts{Attempts: o.Attempts},
+	})
+	return err
+}
+
+func (o *Bundle) Import() error {
+	buildCtx, err := o.ToBuildCtx("bundle", o.CommonParams)
+	if err != nil {
+		return err
+	}
+	_, err = buildCtx.ImportBundle(welder.BundleImportOpts{
+		InputPath:   o.InputPath,
+		SkipMutagen: o.SkipMutagen,
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/welder"
+)
+
+type Changelog struct {
+	CommonParams
+	BuildParams
+	From       string
+	To         string
+	TagPattern string
+	Output     string
+	File       string
+}
+
+func (o *Changelog) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("changelog", "Print changes grouped by Conventional Commit type and scope (only changes of the module when module is active)")
+	o.registerCommonFlags(cmd)
+	o.registerBuildFlags(cmd)
+	cmd.Flag("from", "Revision to list changes since (the latest tag reachable from --to by default, the previous one if --to is tagged)").
+		StringVar(&o.From)
+	cmd.Flag("to", "Revision to list changes until").
+		Default("HEAD").
+		StringVar(&o.To)
+	cmd.Flag("tag-pattern", "Glob pattern of version tags considered when --from is not specified (e.g. 'api-*')").
+		StringVar(&o.TagPattern)
+	cmd.Flag("output", fmt.Sprintf("Output format: %v", welder.ChangelogFormats)).
+		Short('o').
+		Default(welder.ChangelogFormatMarkdown).
+		EnumVar(&o.Output, welder.ChangelogFormats...)
+	cmd.Flag("file", "Prepend changelog to the file instead of printing it (e.g. CHANGELOG.md)").
+		StringVar(&o.File)
+	cmd.Action(registerAction(o.Changelog))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Changelog) Changelog() error {
+	buildCtx, err := o.ToBuildCtx("changelog", o.CommonParams)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build context")
+	}
+	verCtx, err := welder.NewVersionCtx(buildCtx, nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create version context")
+	}
+	changelog, err := verCtx.Changelog(o.From, o.To, o.TagPattern)
+	if err != nil {
+		return err
+	}
+	if o.File == "" {
+		return changelog.Write(os.Stdout, o.Output)
+	}
+	if o.Output != welder.ChangelogFormatMarkdown {
+		return errors.Errorf("only %s changelog can be prepended to the file", welder.ChangelogFormatMarkdown)
+	}
+	var buf bytes.Buffer
+	if err := changelog.Write(&buf, o.Output); err != nil {
+		return err
+	}
+	return welder.PrependChangelog(o.File, buf.Bytes())
+}
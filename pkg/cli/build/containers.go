@@ -0,0 +1,115 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/simple-container-com/welder/pkg/render"
+	"github.com/simple-container-com/welder/pkg/util"
+)
+
+type Ps struct {
+	BasicParams
+	BuildParams
+	render.OutputFlag
+	AllProjects bool
+}
+
+type Exec struct {
+	BasicParams
+	BuildParams
+	RunParams
+	RunID   string
+	Command string
+}
+
+type Logs struct {
+	BasicParams
+	BuildParams
+	RunID  string
+	Follow bool
+}
+
+func (o *Ps) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("ps", "List containers created by Welder for the project")
+	o.registerBasicFlags(cmd)
+	o.OutputFlag.Mount(cmd)
+	cmd.Flag("all-projects", "List containers of all projects").
+		Short('A').
+		BoolVar(&o.AllProjects)
+	cmd.Action(registerAction(o.Ps))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Ps) Ps() error {
+	buildCtx, err := o.ToBuildCtx("ps", CommonParams{BasicParams: o.BasicParams})
+	if err != nil {
+		return err
+	}
+	containers, err := buildCtx.Containers(o.AllProjects)
+	if err != nil {
+		return err
+	}
+	if o.Output != "" {
+		return render.Write(os.Stdout, o.Output, containers)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RUN ID\tMODULE/TASK\tIMAGE\tCONFIG HASH\tAGE\tSTATUS\tCONTAINER")
+	for _, c := range containers {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.RunID, c.RunName, c.Image, util.LastNChars(c.ConfigHash, 12),
+			util.FormatDuration(time.Since(c.Created)), c.Status, c.Name)
+	}
+	return w.Flush()
+}
+
+func (o *Exec) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("exec", "Execute command in a running container created by Welder")
+	o.registerBasicFlags(cmd)
+	o.registerRunFlags(cmd)
+	cmd.Arg("run-id", "Run ID (or name) of the container (see 'welder ps')").
+		Required().
+		StringVar(&o.RunID)
+	cmd.Arg("command", "Command to execute in container").
+		Default("sh").
+		StringVar(&o.Command)
+	cmd.Action(registerAction(o.Exec))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Exec) Exec() error {
+	buildCtx, err := o.ToBuildCtx("exec", CommonParams{BasicParams: o.BasicParams})
+	if err != nil {
+		return err
+	}
+	if err := o.AddRunParams(buildCtx); err != nil {
+		return err
+	}
+	return buildCtx.ExecInContainer(o.RunID, o.Command)
+}
+
+func (o *Logs) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("logs", "Stream logs of a container created by Welder")
+	o.registerBasicFlags(cmd)
+	cmd.Flag("follow", "Follow log output").
+		Short('f').
+		BoolVar(&o.Follow)
+	cmd.Arg("run-id", "Run ID (or name) of the container (see 'welder ps')").
+		Required().
+		StringVar(&o.RunID)
+	cmd.Action(registerAction(o.Logs))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Logs) Logs() error {
+	buildCtx, err := o.ToBuildCtx("logs", CommonParams{BasicParams: o.BasicParams})
+	if err != nil {
+		return err
+	}
+	return buildCtx.ContainerLogs(o.RunID, o.Follow)
+}
@@ -19,12 +19,13 @@ type Docker struct {
 	CommonParams
 	BuildParams
 
-	DockerPush         bool
-	DockerConfigPath   string
-	KanikoExecutorPath string
-	KanikoCachePath    string
-	KanikoExtraArgs    string
-	DockerImages       []string
+	DockerPush          bool
+	DockerConfigPath    string
+	Builder             string
+	BuilderExecutorPath string
+	BuilderCachePath    string
+	BuilderExtraArgs    string
+	DockerImages        []string
 }
 
 func (o *Docker) Mount(a *kingpin.Application) *kingpin.CmdClause {
@@ -51,6 +52,16 @@ func (o *Docker) Mount(a *kingpin.Application) *kingpin.CmdClause {
 	buildCmd.Action(registerAction(o.Build))
 	buildCmd.Flag("push", "Push after building (default: false)").
 		BoolVar(&o.DockerPush)
+	buildCmd.Flag("builder", "Builder backend to build images with (overrides 'builder' of image definitions)").
+		EnumVar(&o.Builder, "docker", "kaniko", "buildkit", "buildah")
+	buildCmd.Flag("executor-path", "Path to the builder's executable (default depends on the builder)").
+		Short('E').
+		StringVar(&o.BuilderExecutorPath)
+	buildCmd.Flag("cache-path", "Path to the builder's cache directory").
+		Short('C').
+		StringVar(&o.BuilderCachePath)
+	buildCmd.Flag("extra-args", "Extra args to pass to the builder's executable").
+		StringVar(&o.BuilderExtraArgs)
 	buildCmd.Arg("image", "Docker images to build ("+availableImages+")").
 		StringsVar(&o.DockerImages)
 	pushCmd := cmd.Command("push", "Push Docker images specified for the project")
@@ -65,13 +76,13 @@ func (o *Docker) Mount(a *kingpin.Application) *kingpin.CmdClause {
 	kanikoCmd.Flag("executor-path", "Path to Kaniko executor binary (default: /kaniko/executor)").
 		Short('E').
 		Default("/kaniko/executor").
-		StringVar(&o.KanikoExecutorPath)
+		StringVar(&o.BuilderExecutorPath)
 	kanikoCmd.Flag("cache-path", "Path to Kaniko cache directory (default: /cache)").
 		Short('C').
 		Default("/cache").
-		StringVar(&o.KanikoCachePath)
+		StringVar(&o.BuilderCachePath)
 	kanikoCmd.Flag("extra-args", "Extra args to pass to kaniko executor").
-		StringVar(&o.KanikoExtraArgs)
+		StringVar(&o.BuilderExtraArgs)
 	kanikoCmd.Arg("image", "Docker images to build/push ("+availableImages+")").
 		StringsVar(&o.DockerImages)
 	kanikoCmd.Action(registerAction(o.Kaniko))
@@ -94,12 +105,13 @@ func (o *Docker) Build() error {
 		return err
 	}
 
-	if err := buildCtx.BuildDocker(o.DockerImages); err != nil {
-		return err
-	} else if o.DockerPush {
-		return o.Push()
-	}
-	return nil
+	return buildCtx.BuildDockerWithBuilder(o.DockerImages, welder.ImageBuilderOpts{
+		Builder:      types.ImageBuilderType(o.Builder),
+		ExecutorPath: o.BuilderExecutorPath,
+		CachePath:    o.BuilderCachePath,
+		ExtraArgs:    o.BuilderExtraArgs,
+		Push:         o.DockerPush,
+	})
 }
 
 func (o *Docker) Config() error {
@@ -130,10 +142,11 @@ func (o *Docker) Kaniko() error {
 	if err != nil {
 		return err
 	}
-	return buildCtx.KanikoBuild(welder.KanikoOpts{
-		ExecutorPath: o.KanikoExecutorPath,
-		CachePath:    o.KanikoCachePath,
-		ExtraArgs:    o.KanikoExtraArgs,
-		DockerImages: o.DockerImages,
+	return buildCtx.BuildDockerWithBuilder(o.DockerImages, welder.ImageBuilderOpts{
+		Builder:      types.ImageBuilderKaniko,
+		ExecutorPath: o.BuilderExecutorPath,
+		CachePath:    o.BuilderCachePath,
+		ExtraArgs:    o.BuilderExtraArgs,
+		Push:         true,
 	})
 }
@@ -0,0 +1,50 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+type Imports struct {
+	Dir string
+}
+
+func (o *Imports) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("imports", "Manage commits of imported task libraries recorded in welder.lock")
+	cmd.Flag("dir", "Directory within the project (current directory by default)").
+		Short('d').
+		StringVar(&o.Dir)
+	lockCmd := cmd.Command("lock", "Record commits of imports that are not locked yet")
+	lockCmd.Action(registerAction(o.Lock))
+	updateCmd := cmd.Command("update", "Resolve refs of all imports again and record their commits")
+	updateCmd.Action(registerAction(o.Update))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Imports) Lock() error {
+	return o.lock(false)
+}
+
+func (o *Imports) Update() error {
+	return o.lock(true)
+}
+
+func (o *Imports) lock(update bool) error {
+	rootDir, _, err := types.DetectBuildContext(o.Dir)
+	if err != nil {
+		return err
+	}
+	lock, err := types.LockImports(rootDir, update)
+	if err != nil {
+		return err
+	}
+	for _, imp := range lock.Imports {
+		fmt.Printf(" - %s@%s: %s\n", imp.Git, imp.Ref, imp.Commit)
+	}
+	fmt.Printf("%s is up to date (%d imports)\n", types.ImportsLockFileName, len(lock.Imports))
+	return nil
+}
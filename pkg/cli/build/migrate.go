@@ -0,0 +1,66 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+type Migrate struct {
+	Dir    string
+	DryRun bool
+}
+
+func (o *Migrate) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("migrate", "Upgrade welder.yaml of the project to the current schema version")
+	cmd.Flag("dir", "Directory within the project (current directory by default)").
+		Short('d').
+		StringVar(&o.Dir)
+	cmd.Flag("dry-run", "Only print the changes without writing them").
+		BoolVar(&o.DryRun)
+	cmd.Action(registerAction(o.Migrate))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Migrate) Migrate() error {
+	rootDir, _, err := types.DetectBuildContext(o.Dir)
+	if err != nil {
+		return err
+	}
+	results, err := types.MigrateBuildDefinition(rootDir, o.DryRun)
+	if err != nil {
+		return err
+	}
+	res := results[0]
+	if !res.Migrated {
+		fmt.Printf("%s is already at schema version %s\n", types.BuildConfigFileName, res.FromVersion)
+		return nil
+	}
+	changes := 0
+	for _, fileRes := range results {
+		if len(fileRes.Changes) == 0 {
+			continue
+		}
+		fileName := fileRes.File
+		if rel, err := filepath.Rel(rootDir, fileRes.File); err == nil {
+			fileName = rel
+		}
+		fmt.Printf("%s:\n", fileName)
+		for _, change := range fileRes.Changes {
+			fmt.Printf(" - %s\n", change)
+		}
+		changes += len(fileRes.Changes)
+	}
+	if o.DryRun {
+		fmt.Printf("%s would be migrated from schema version %s to %s (%d changes in %d files)\n",
+			types.BuildConfigFileName, res.FromVersion, res.ToVersion, changes, len(results))
+		return nil
+	}
+	fmt.Printf("%s has been migrated from schema version %s to %s (%d changes in %d files)\n",
+		types.BuildConfigFileName, res.FromVersion, res.ToVersion, changes, len(results))
+	return nil
+}
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"github.com/alecthomas/kingpin"
+
+	"github.com/simple-container-com/welder/pkg/welder"
+)
+
+type Pull struct {
+	CommonParams
+	BuildParams
+
+	DryRun   bool
+	Attempts int
+}
+
+func (o *Pull) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("pull", "Pull all images referenced by the project in parallel (e.g. to prefetch images on CI agents)")
+	o.registerCommonFlags(cmd)
+	o.registerBuildFlags(cmd)
+	cmd.Flag("dry-run", "Only print images that would be pulled").
+		BoolVar(&o.DryRun)
+	cmd.Flag("attempts", "Number of attempts to pull each image").
+		Default("3").
+		IntVar(&o.Attempts)
+	cmd.Action(registerAction(o.Pull))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Pull) Pull() error {
+	buildCtx, err := o.ToBuildCtx("pull", o.CommonParams)
+	if err != nil {
+		return err
+	}
+	return buildCtx.PullImages(welder.PullOpts{
+		DryRun:   o.DryRun,
+		Attempts: o.Attempts,
+	})
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+
+	"github.com/simple-container-com/welder/pkg/welder"
+)
+
+type Release struct {
+	CommonParams
+	BuildParams
+	RunParams
+	Bump      string
+	TagFormat string
+	Deploy    bool
+	NoPush    bool
+}
+
+func (o *Release) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("release", "Bump version of the modules, commit and tag it, build modules, push Docker images, "+
+		"optionally deploy to auto-deploy environments and push the commit along with tags")
+	o.registerCommonFlags(cmd)
+	o.registerBuildFlags(cmd)
+	o.registerRunFlags(cmd)
+	cmd.Flag("bump", fmt.Sprintf("Part of the version to bump: %v (auto is calculated from Conventional Commits)", welder.ReleaseBumps)).
+		Default(welder.ReleaseBumpAuto).
+		EnumVar(&o.Bump, welder.ReleaseBumps...)
+	cmd.Flag("tag-format", "Format of the release tag (supports ${module} and ${version})").
+		Default(welder.DefaultReleaseTagFormat).
+		StringVar(&o.TagFormat)
+	cmd.Flag("deploy", "Deploy released modules to environments with autoDeploy enabled").
+		BoolVar(&o.Deploy)
+	cmd.Flag("no-push", "Do not push release commit and tags").
+		BoolVar(&o.NoPush)
+	cmd.Action(registerAction(o.Release))
+	appVersion = a.Model().Version
+
+	return cmd
+}
+
+func (o *Release) Release() error {
+	buildCtx, err := o.ToBuildCtx("release", o.CommonParams)
+	if err != nil {
+		return err
+	}
+	if err := o.AddRunParams(buildCtx); err != nil {
+		return err
+	}
+	return buildCtx.Release(welder.ReleaseOpts{
+		Bump:      o.Bump,
+		TagFormat: o.TagFormat,
+		Deploy:    o.Deploy,
+		Push:      !o.NoPush,
+	})
+}
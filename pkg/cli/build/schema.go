@@ -0,0 +1,40 @@
+package build
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+type Schema struct {
+	Output string
+}
+
+func (o *Schema) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("schema", "Print JSON Schema of welder.yaml for the current schema version")
+	cmd.Flag("output", "File to write the schema to (stdout by default)").
+		Short('o').
+		StringVar(&o.Output)
+	cmd.Action(registerAction(o.Schema))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Schema) Schema() error {
+	schema, err := types.JSONSchema()
+	if err != nil {
+		return err
+	}
+	if o.Output == "" {
+		fmt.Println(string(schema))
+		return nil
+	}
+	if err := os.WriteFile(o.Output, schema, 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write schema to %s", o.Output)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/alecthomas/kingpin"
+	"github.com/simple-container-com/welder/pkg/docker"
 	"github.com/simple-container-com/welder/pkg/util"
 	"github.com/simple-container-com/welder/pkg/welder"
 	"github.com/simple-container-com/welder/pkg/welder/types"
@@ -25,6 +26,8 @@ type BasicParams struct {
 	ForceOnHost     bool
 	SyncMode        string
 	PrintTimestamps bool
+	Offline         bool
+	PullPolicy      string
 }
 
 type CommonParams struct {
@@ -96,6 +99,10 @@ func (o *BasicParams) registerBasicFlags(cmd *kingpin.CmdClause) {
 	cmd.Flag("on-host", "Run all commands on host environment instead of Docker").
 		Short('H').
 		BoolVar(&o.ForceOnHost)
+	cmd.Flag("offline", "Never pull images (fail if any of referenced images is not available locally)").
+		BoolVar(&o.Offline)
+	cmd.Flag("pull", "Override pull policy of all images (always|if-not-present|never)").
+		EnumVar(&o.PullPolicy, "always", "if-not-present", "never")
 }
 
 func (o *CommonParams) registerCommonFlags(cmd *kingpin.CmdClause) {
@@ -131,6 +138,7 @@ func (o *BuildParams) ToBuildCtx(ctxName string, common CommonParams) (*welder.B
 	for k, v := range o.Args {
 		args[k] = types.StringValue(v)
 	}
+	types.SetImportsOffline(common.Offline)
 	ctx := &welder.BuildContext{
 		CommonCtx: &types.CommonCtx{
 			Profiles:         o.Profiles,
@@ -148,6 +156,8 @@ func (o *BuildParams) ToBuildCtx(ctxName string, common CommonParams) (*welder.B
 			ReuseContainers:  common.ReuseContainers,
 			RemoveOrphans:    common.RemoveOrphans,
 			ForceOnHost:      common.ForceOnHost,
+			Offline:          common.Offline,
+			PullPolicy:       docker.PullPolicy(common.PullPolicy),
 		},
 	}
 	logger := util.NewPrefixLogger(ctxName, ctx.Verbose)
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+type Validate struct {
+	Dir string
+}
+
+func (o *Validate) Mount(a *kingpin.Application) *kingpin.CmdClause {
+	cmd := a.Command("validate", "Validate welder.yaml of the project against the schema and semantic rules")
+	cmd.Flag("dir", "Directory within the project (current directory by default)").
+		Short('d').
+		StringVar(&o.Dir)
+	cmd.Action(registerAction(o.Validate))
+	appVersion = a.Model().Version
+	return cmd
+}
+
+func (o *Validate) Validate() error {
+	rootDir, _, err := types.DetectBuildContext(o.Dir)
+	if err != nil {
+		return err
+	}
+	diagnostics := types.ValidateBuildDefinition(rootDir)
+	for _, d := range diagnostics {
+		fmt.Println(d.String())
+	}
+	if diagnostics.HasErrors() {
+		return errors.Errorf("%s is invalid", types.BuildConfigFileName)
+	}
+	fmt.Printf("%s is valid\n", types.BuildConfigFileName)
+	return nil
+}
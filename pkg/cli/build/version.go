@@ -11,7 +11,9 @@ import (
 type Version struct {
 	CommonParams
 	BuildParams
-	Value string
+	Value      string
+	Auto       bool
+	TagPattern string
 }
 
 func (o *Version) Mount(a *kingpin.Application) *kingpin.CmdClause {
@@ -26,6 +28,10 @@ func (o *Version) Mount(a *kingpin.Application) *kingpin.CmdClause {
 	bumpMinor.Action(registerAction(o.BumpMinor))
 	bumpMajor := cmd.Command("bump-major", "Bump major-version")
 	bumpMajor.Action(registerAction(o.BumpMajor))
+	bump := cmd.Command("bump", "Bump version")
+	bump.Action(registerAction(o.Bump))
+	bump.Flag("auto", "Bump version according to Conventional Commits made since the latest tag (only commits changing files of the module are considered when module is active)").BoolVar(&o.Auto)
+	bump.Flag("tag-pattern", "Glob pattern of version tags considered with --auto (e.g. 'api-*')").StringVar(&o.TagPattern)
 	setVersion := cmd.Command("set", "Bump major-version")
 	setVersion.Action(registerAction(o.Set))
 	setVersion.Arg("version", "Version value to set").StringVar(&o.Value)
@@ -46,6 +52,22 @@ func (o *Version) BumpPatch() error {
 	return o.ctx().BumpPatch()
 }
 
+func (o *Version) Bump() error {
+	if !o.Auto {
+		return errors.New("bump mode is not specified: use --auto or bump-patch, bump-minor, bump-major commands")
+	}
+	res, err := o.ctx().BumpAuto(o.TagPattern)
+	if err != nil {
+		return err
+	}
+	since := res.Since
+	if since == "" {
+		since = "the first commit"
+	}
+	fmt.Printf("%d commit(s) since %s, version bump: %s\n", res.Commits, since, res.Bump)
+	return nil
+}
+
 func (o *Version) Set() error {
 	return o.ctx().SetVersionInConfig(o.Value)
 }
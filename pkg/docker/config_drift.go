@@ -0,0 +1,197 @@
+package docker
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// LabelNameConfigSummary label keeps summary of the container configuration (to explain why container is re-created)
+const LabelNameConfigSummary = "WelderBuildContainerConfig"
+
+// containerConfig is a summary of configuration the container is created with
+// (values of environment variables are hashed, so that secrets are not exposed via labels)
+type containerConfig map[string]string
+
+// calcContainerConfig calculates summary of the current configuration (image, volumes, env, user and tweaks)
+func (run *Run) calcContainerConfig(runCtx RunContext) containerConfig {
+	env := append(append([]string{}, run.envVars...), runCtx.Env...)
+	envNames := make([]string, 0, len(env))
+	for _, envVar := range env {
+		envNames = append(envNames, strings.SplitN(envVar, "=", 2)[0])
+	}
+	sort.Strings(envNames)
+	return containerConfig{
+		"image":      run.Reference,
+		"imageID":    run.localImageID(),
+		"volumes":    volumesSummary(run.volumeBinds, run.volumeMounts),
+		"ports":      strings.Join(run.ports, ","),
+		"entrypoint": strings.Join(run.entrypoint, " "),
+		"command":    strings.Join(run.command, " "),
+		"user":       runCtx.User,
+		"os":         runCtx.CurrentOS,
+		"ci":         runCtx.CurrentCI.Name,
+		"tweaks":     run.tweaksSummary(),
+		"env":        strings.Join(envNames, ","),
+		"envValues":  hashOf(strings.Join(env, "\n")),
+	}
+}
+
+// hash returns hash sum of the configuration (to figure out whether container needs to be re-created)
+func (c containerConfig) hash() (string, error) {
+	// keys of the map are sorted when marshalling, so the result is stable
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	hash := md5.Sum(bytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// diff returns human-readable list of changes comparing to the previous configuration
+func (c containerConfig) diff(prev containerConfig) []string {
+	var res []string
+	keys := make([]string, 0, len(c)+len(prev))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	for key := range prev {
+		if _, ok := c[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, exists := c[key]
+		prevValue, prevExists := prev[key]
+		if exists && prevExists && value == prevValue {
+			continue
+		}
+		switch key {
+		case "env":
+			added, removed := diffLists(strings.Split(prevValue, ","), strings.Split(value, ","))
+			if len(added) > 0 {
+				res = append(res, fmt.Sprintf("env variables added: %s", strings.Join(added, ", ")))
+			}
+			if len(removed) > 0 {
+				res = append(res, fmt.Sprintf("env variables removed: %s", strings.Join(removed, ", ")))
+			}
+			continue
+		case "envValues":
+			res = append(res, "values of env variables changed")
+			continue
+		}
+		res = append(res, fmt.Sprintf("%s: %q -> %q", key, prevValue, value))
+	}
+	return res
+}
+
+// parseContainerConfig reads configuration summary from labels of the container (nil if container has no summary)
+func parseContainerConfig(labels map[string]string) containerConfig {
+	value, ok := labels[LabelNameConfigSummary]
+	if !ok {
+		return nil
+	}
+	var res containerConfig
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		return nil
+	}
+	return res
+}
+
+// findContainerOfRun returns container created by the same run (regardless of its configuration)
+func (run *Run) findContainerOfRun() (types.Container, bool, error) {
+	containers, err := run.dockerAPI.ContainerList(run.GoContext(), types.ContainerListOptions{All: true})
+	if err != nil {
+		return types.Container{}, false, err
+	}
+	for _, c := range containers {
+		if c.Labels[LabelNameContainerID] == run.RunID {
+			return c, true, nil
+		}
+	}
+	return types.Container{}, false, nil
+}
+
+// localImageID returns ID of the image available locally (empty if image is not pulled yet)
+func (run *Run) localImageID() string {
+	if run.dockerAPI == nil {
+		return ""
+	}
+	inspect, _, err := run.dockerAPI.ImageInspectWithRaw(run.GoContext(), run.Reference)
+	if err != nil {
+		return ""
+	}
+	return inspect.ID
+}
+
+// tweaksSummary returns summary of settings that affect extra system integrations applied to the container
+func (run *Run) tweaksSummary() string {
+	labels := make([]string, 0, len(run.labels))
+	for k, v := range run.labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	res := fmt.Sprintf("privileged=%t,dockerSocket=%t,keepEnv=%t,labels=[%s]",
+		run.privileged, run.mountDockerSocket, run.keepEnvVariables, strings.Join(labels, ","))
+	if run.dockerUtil != nil {
+		res += fmt.Sprintf(",dockerHost=%s,inDocker=%t", run.dockerUtil.DockerHost(), run.dockerUtil.IsRunningInDocker())
+	}
+	return res
+}
+
+func volumesSummary(volumeGroups ...[]Volume) string {
+	var res []string
+	for _, volumes := range volumeGroups {
+		for _, v := range volumes {
+			res = append(res, fmt.Sprintf("%s:%s:%s:%s", v.Name, v.HostPath, v.ContPath, v.Mode))
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+func hashOf(value string) string {
+	hash := md5.Sum([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+// diffLists returns items added to and removed from the list
+func diffLists(prev []string, current []string) (added []string, removed []string) {
+	prevSet := make(map[string]bool)
+	for _, item := range prev {
+		prevSet[item] = true
+	}
+	currentSet := make(map[string]bool)
+	for _, item := range current {
+		currentSet[item] = true
+		if !prevSet[item] && item != "" {
+			added = append(added, item)
+		}
+	}
+	for _, item := range prev {
+		if !currentSet[item] && item != "" {
+			removed = append(removed, item)
+		}
+	}
+	return
+}
+
+// removeDriftedContainer removes container of the same run if its configuration differs from the current one
+func (run *Run) removeDriftedContainer(runCtx RunContext) error {
+	existing, found, err := run.findContainerOfRun()
+	if err != nil || !found {
+		return err
+	}
+	changes := []string{"configuration hash has changed"}
+	if prevConfig := parseContainerConfig(existing.Labels); prevConfig != nil {
+		changes = run.initialConfig.diff(prevConfig)
+	}
+	runCtx.Logf("Configuration of the reused container %s has changed, re-creating it:\n  - %s",
+		strings.TrimPrefix(strings.Join(existing.Names, ","), "/"), strings.Join(changes, "\n  - "))
+	return run.Destroy()
+}
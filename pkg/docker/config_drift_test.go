@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestContainerConfigDiff(t *testing.T) {
+	RegisterTestingT(t)
+
+	run := &Run{
+		RunID:       "test",
+		Reference:   "alpine:3.18",
+		volumeBinds: []Volume{{HostPath: "/src", ContPath: "/src", Mode: VolumeModeRW}},
+		envVars:     []string{"FOO=foo"},
+	}
+	runCtx := RunContext{User: "builder", Env: []string{"SECRET=s3cr3t"}}
+	prev := run.calcContainerConfig(runCtx)
+	prevHash, err := prev.hash()
+	Expect(err).To(BeNil())
+
+	// same configuration results in the same hash
+	sameHash, err := run.calcContainerConfig(runCtx).hash()
+	Expect(err).To(BeNil())
+	Expect(sameHash).To(Equal(prevHash))
+	Expect(run.calcContainerConfig(runCtx).diff(prev)).To(BeEmpty())
+
+	run.Reference = "alpine:3.19"
+	run.envVars = []string{"FOO=bar", "BAR=bar"}
+	runCtx.User = "root"
+	current := run.calcContainerConfig(runCtx)
+	currentHash, err := current.hash()
+	Expect(err).To(BeNil())
+	Expect(currentHash).NotTo(Equal(prevHash))
+
+	Expect(current.diff(prev)).To(Equal([]string{
+		"env variables added: BAR",
+		"values of env variables changed",
+		`image: "alpine:3.18" -> "alpine:3.19"`,
+		`user: "builder" -> "root"`,
+	}))
+	// values of env variables must not be exposed
+	Expect(current["envValues"]).NotTo(ContainSubstring("s3cr3t"))
+
+	// configuration without summary label is not parsed
+	Expect(parseContainerConfig(map[string]string{})).To(BeNil())
+	Expect(parseContainerConfig(map[string]string{LabelNameConfigSummary: `{"user":"root"}`})).To(Equal(containerConfig{"user": "root"}))
+}
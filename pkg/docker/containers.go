@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// ContainerInfo describes container created by Welder
+type ContainerInfo struct {
+	ID         string    `json:"id" yaml:"id"`
+	Name       string    `json:"name" yaml:"name"`
+	RunID      string    `json:"runId" yaml:"runId"`
+	Project    string    `json:"project,omitempty" yaml:"project,omitempty"`
+	RunName    string    `json:"runName,omitempty" yaml:"runName,omitempty"`
+	Image      string    `json:"image" yaml:"image"`
+	ConfigHash string    `json:"configHash" yaml:"configHash"`
+	Created    time.Time `json:"created" yaml:"created"`
+	State      string    `json:"state" yaml:"state"`
+	Status     string    `json:"status" yaml:"status"`
+}
+
+// IsRunning returns true if container is currently running
+func (c ContainerInfo) IsRunning() bool {
+	return c.State == "running"
+}
+
+// ListWelderContainers returns containers created by Welder (for the specified project only if it is not empty)
+func (u *DockerUtil) ListWelderContainers(project string) ([]ContainerInfo, error) {
+	containers, err := u.docker.ContainerList(u.GoContext(), types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list containers")
+	}
+	return welderContainersOf(containers, project), nil
+}
+
+// FindWelderContainer finds container created by Welder by its run ID, name or ID prefix
+// if there are several containers with the same run ID, running one is preferred
+func (u *DockerUtil) FindWelderContainer(project string, runIDOrName string) (ContainerInfo, error) {
+	containers, err := u.ListWelderContainers(project)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+	return findWelderContainer(containers, runIDOrName)
+}
+
+// ContainerRunConfig returns user and environment variables the container was created with
+// (commands of steps run with them, so they are used to execute commands within existing container)
+func (u *DockerUtil) ContainerRunConfig(containerID string) (string, []string, error) {
+	inspResp, err := u.docker.ContainerInspect(u.GoContext(), containerID)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to inspect container %s", containerID)
+	}
+	if inspResp.Config == nil {
+		return "", nil, nil
+	}
+	return inspResp.Config.User, inspResp.Config.Env, nil
+}
+
+// AttachToContainer makes run use existing container instead of creating a new one
+func (run *Run) AttachToContainer(containerID string) *Run {
+	run.containerID = containerID
+	run.osDistribution = run.Util().DetectOSDistributionFromContainer(containerID)
+	// volumes of existing container are managed by the run that created it
+	run.volumeApproach = VolumeApproachExternal
+	return run
+}
+
+func welderContainersOf(containers []types.Container, project string) []ContainerInfo {
+	res := make([]ContainerInfo, 0)
+	for _, c := range containers {
+		runID, ok := c.Labels[LabelNameContainerID]
+		if !ok {
+			continue
+		}
+		info := ContainerInfo{
+			ID:         c.ID,
+			RunID:      runID,
+			Project:    c.Labels[LabelNameProject],
+			RunName:    c.Labels[LabelNameRunName],
+			Image:      c.Labels[LabelNameImage],
+			ConfigHash: c.Labels[LabelNameConfigHash],
+			Created:    time.Unix(c.Created, 0),
+			State:      c.State,
+			Status:     c.Status,
+		}
+		if len(c.Names) > 0 {
+			info.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if info.Image == "" {
+			info.Image = c.Image
+		}
+		if project != "" {
+			// containers created by older versions do not have project label, relying on run ID prefix
+			if info.Project != "" && info.Project != project {
+				continue
+			} else if info.Project == "" && !strings.HasPrefix(runID, project) {
+				continue
+			}
+		}
+		res = append(res, info)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Created.After(res[j].Created)
+	})
+	return res
+}
+
+func findWelderContainer(containers []ContainerInfo, runIDOrName string) (ContainerInfo, error) {
+	var found *ContainerInfo
+	for i, c := range containers {
+		if c.RunID != runIDOrName && c.Name != runIDOrName && !strings.HasPrefix(c.ID, runIDOrName) {
+			continue
+		}
+		if found == nil || (!found.IsRunning() && c.IsRunning()) {
+			found = &containers[i]
+		}
+	}
+	if found == nil {
+		return ContainerInfo{}, errors.Errorf("container not found: %s", runIDOrName)
+	}
+	return *found, nil
+}
@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestWelderContainersOf(t *testing.T) {
+	RegisterTestingT(t)
+
+	containers := []types.Container{
+		{ID: "aaa111", Names: []string{"/myproject-build-abcde"}, Image: "ab-alpine", Created: 100, State: "exited", Labels: map[string]string{
+			LabelNameContainerID: "myproject-build", LabelNameProject: "myproject", LabelNameRunName: "build", LabelNameImage: "alpine:3.18",
+		}},
+		{ID: "bbb222", Names: []string{"/myproject-build-fghij"}, Image: "ab-alpine", Created: 200, State: "running", Labels: map[string]string{
+			LabelNameContainerID: "myproject-build", LabelNameProject: "myproject", LabelNameRunName: "build", LabelNameImage: "alpine:3.18",
+		}},
+		{ID: "ccc333", Names: []string{"/myproject-old-klmno"}, Image: "ab-ubuntu", Created: 50, State: "running", Labels: map[string]string{
+			LabelNameContainerID: "myproject-old",
+		}},
+		{ID: "ddd444", Names: []string{"/other-build-pqrst"}, Created: 300, State: "running", Labels: map[string]string{
+			LabelNameContainerID: "other-build", LabelNameProject: "other",
+		}},
+		{ID: "eee555", Names: []string{"/not-welder"}, Created: 400, State: "running"},
+	}
+
+	res := welderContainersOf(containers, "myproject")
+	Expect(res).To(HaveLen(3))
+	Expect(res[0].ID).To(Equal("bbb222"))
+	Expect(res[0].Name).To(Equal("myproject-build-fghij"))
+	Expect(res[0].Image).To(Equal("alpine:3.18"))
+	Expect(res[2].ID).To(Equal("ccc333"))
+	Expect(res[2].Image).To(Equal("ab-ubuntu"))
+
+	Expect(welderContainersOf(containers, "")).To(HaveLen(4))
+
+	found, err := findWelderContainer(res, "myproject-build")
+	Expect(err).To(BeNil())
+	Expect(found.ID).To(Equal("bbb222"))
+
+	found, err = findWelderContainer(res, "aaa")
+	Expect(err).To(BeNil())
+	Expect(found.ID).To(Equal("aaa111"))
+
+	_, err = findWelderContainer(res, "missing")
+	Expect(err).NotTo(BeNil())
+}
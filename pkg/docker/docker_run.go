@@ -40,6 +40,9 @@ import (
 const (
 	LabelNameContainerID     = "WelderBuildContainerID"
 	LabelNameConfigHash      = "WelderBuildContainerConfigHash"
+	LabelNameProject         = "WelderBuildProject"
+	LabelNameRunName         = "WelderBuildRunName"
+	LabelNameImage           = "WelderBuildImage"
 	HostSystemHostname       = "host.docker.internal" // hostname to access host machine (as of https://docs.docker.com/docker-for-mac/networking/)
 	GatewayHostname          = "gateway"              // hostname to access gateway (in Linux it'd be the same as host machine, in Mac it'd be a host of Docker VM)
 	DefaultContainerCommand  = "sleep 100000"
@@ -67,6 +70,8 @@ func NewRun(runID string, ref string) (*Run, error) {
 	if runID == "" {
 		runID = DefaultRunID
 	}
+	// run ID is used in container names and temp dirs (names of imported tasks contain slashes)
+	runID = strings.ReplaceAll(runID, "/", "-")
 	volumeApproach := VolumeApproachBind
 	if dockerUtil.IsRunningInDocker() || dockerUtil.IsDockerHostRemote() {
 		volumeApproach = VolumeApproachAdd
@@ -164,6 +169,11 @@ func (run *Run) Run(runCtx RunContext, commands ...string) error {
 }
 
 func (run *Run) PrepareContainer(runCtx RunContext) (string, error) {
+	// make sure base image is pulled to host (digest of the image is a part of the configuration)
+	if err := run.makeSureImagePulled(runCtx); err != nil {
+		return "", errors.Wrapf(err, "failed to pull image")
+	}
+
 	// get hash of the configuration
 	configHash, err := run.calcConfigHash(runCtx)
 	if err != nil {
@@ -179,6 +189,13 @@ func (run *Run) PrepareContainer(runCtx RunContext) (string, error) {
 		runCtx.Debugf("existing container not found: %s", err.Error())
 	}
 
+	// if container of the same run exists, but its configuration differs, it has to be re-created
+	if run.reuseContainers && containerID == "" && !run.cleanupOrphans {
+		if err := run.removeDriftedContainer(runCtx); err != nil {
+			return "", err
+		}
+	}
+
 	// if cleanup orphans is configured or reuse containers is not enabled
 	if run.cleanupOrphans || !run.reuseContainers {
 		runCtx.Debugf("removing orphans due to requested cleanup / reuse containers not enabled")
@@ -218,11 +235,6 @@ func (run *Run) PrepareContainer(runCtx RunContext) (string, error) {
 }
 
 func (run *Run) createContainer(runCtx RunContext) (string, error) {
-	// make sure base image is pulled to host
-	if err := run.makeSureImagePulled(runCtx); err != nil {
-		return "", errors.Wrapf(err, "failed to pull image")
-	}
-
 	// calc extra system integrations
 	tweaks := run.extraSystemIntegrations(runCtx)
 
@@ -253,12 +265,21 @@ func (run *Run) createContainer(runCtx RunContext) (string, error) {
 	env = append(env, tweaks.extraEnv...)
 	env = append(env, runCtx.Env...)
 
+	labels := map[string]string{
+		LabelNameContainerID: run.RunID,
+		LabelNameConfigHash:  run.initialConfigHash,
+		LabelNameImage:       run.Reference,
+	}
+	if configSummary, err := json.Marshal(run.initialConfig); err == nil {
+		labels[LabelNameConfigSummary] = string(configSummary)
+	}
+	for k, v := range run.labels {
+		labels[k] = v
+	}
+
 	config := &container.Config{
-		Image: imageID,
-		Labels: map[string]string{
-			LabelNameContainerID: run.RunID,
-			LabelNameConfigHash:  run.initialConfigHash,
-		},
+		Image:        imageID,
+		Labels:       labels,
 		Env:          env,
 		ExposedPorts: exposedPorts,
 		WorkingDir:   runCtx.WorkDir,
@@ -964,7 +985,13 @@ func (run *Run) makeSureImagePulled(runCtx RunContext) error {
 	if err != nil {
 		return err
 	}
-	if len(images) == 0 {
+	if len(images) == 0 && run.offline {
+		return errors.Errorf("image %s is not available locally and cannot be pulled in offline mode", run.Reference)
+	}
+	if len(images) == 0 && run.pullPolicy == PullPolicyNever {
+		return errors.Errorf("image %s is not available locally and pull policy is %q", run.Reference, PullPolicyNever)
+	}
+	if len(images) == 0 || (run.pullPolicy == PullPolicyAlways && !run.offline) {
 		// pull image for current platform
 		err := run.pullImageForPlatformAndWait(runCtx, runtime.GOARCH)
 		if err != nil {
@@ -1007,7 +1034,7 @@ func (run *Run) pullImageForPlatformAndWait(runCtx RunContext, platform string)
 	}
 
 	eg.Go(func() error {
-		return run.streamMessagesToChannel(bufio.NewReader(reader), dockerMsgReader.msgChan)
+		return streamMessagesToChannel(bufio.NewReader(reader), dockerMsgReader.msgChan)
 	})
 
 	if runCtx.Logger != nil {
@@ -1029,10 +1056,28 @@ func (run *Run) pullImageForPlatformAndWait(runCtx RunContext, platform string)
 
 func (run *Run) execSingleCommand(containerID string, cmd ExecContext) (ExecResult, error) {
 	cmd.command = strings.TrimSpace(cmd.command)
-	envFile := fmt.Sprintf("/tmp/%s.env", uuid.New().String())
+	var shell util.Shell
+	if !cmd.serviceCmd { // service commands are always executed with the default shell
+		shell = cmd.runCtx.Shell
+	}
+	// on linux we can read the environment variables after command execution (if command runs in POSIX shell)
+	captureEnv := run.osDistribution.IsLinuxBased() && shell.IsPosix()
+	envFile := ""
+	if captureEnv {
+		envFile = fmt.Sprintf("/tmp/%s.env", uuid.New().String())
+	}
 	command := []string{cmd.command}
-	if run.osDistribution.IsLinuxBased() { // on linux we can read the environment variables after command execution
-		command = []string{"/bin/sh", "-c", fmt.Sprintf(`trap "env > %s" EXIT; %s`, envFile, cmd.command)}
+	if shell.IsScriptFile() {
+		scriptFile, err := run.copyScriptToContainer(containerID, cmd.command)
+		if err != nil {
+			return ExecResult{}, err
+		}
+		if !cmd.detach { // detached command may still be reading the script file
+			defer run.removeFromContainer(containerID, scriptFile, cmd.runCtx)
+		}
+		command = shell.Cmd("/bin/sh", scriptFile, envFile)
+	} else if run.osDistribution.IsLinuxBased() || !shell.IsDefault() {
+		command = shell.Cmd("/bin/sh", cmd.command, envFile)
 	}
 	execConfig := types.ExecConfig{
 		Privileged:   run.privileged,
@@ -1108,7 +1153,7 @@ func (run *Run) execSingleCommand(containerID string, cmd ExecContext) (ExecResu
 	res.Pid = ceiResp.Pid
 
 	// read environment variables from the file after command execution (can only do on Linux containers)
-	if run.osDistribution.IsLinuxBased() {
+	if captureEnv {
 		if content, err := run.Util().ReadFileFromContainer(containerID, envFile); err != nil {
 			cmd.runCtx.Warnf("failed to read environment variables from container: %s", err.Error())
 		} else {
@@ -1125,7 +1170,39 @@ func (run *Run) execSingleCommand(containerID string, cmd ExecContext) (ExecResu
 	return res, nil
 }
 
-func (run *Run) streamMessagesToChannel(reader *bufio.Reader, msgChan chan readerNextMessage) error {
+// copyScriptToContainer writes script into temporary directory of the container, returns path to the script file
+func (run *Run) copyScriptToContainer(containerID string, script string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "welder-script")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create temp dir for script file")
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	fileName := fmt.Sprintf("%s.script", uuid.New().String())
+	if err := os.WriteFile(path.Join(tmpDir, fileName), []byte(script), 0o644); err != nil {
+		return "", errors.Wrapf(err, "failed to write script file")
+	}
+	if err := run.Util().CopyToContainer(tmpDir, containerID, "/tmp"); err != nil {
+		return "", errors.Wrapf(err, "failed to copy script file into container %s", containerID)
+	}
+	return path.Join("/tmp", fileName), nil
+}
+
+// removeFromContainer removes file from the container (as root, since copied files are owned by the host user)
+func (run *Run) removeFromContainer(containerID string, filePath string, runCtx *RunContext) {
+	ctx := run.GoContext()
+	crResp, err := run.dockerAPI.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		User: "0",
+		Cmd:  []string{"rm", "-f", filePath},
+	})
+	if err == nil {
+		err = run.dockerAPI.ContainerExecStart(ctx, crResp.ID, types.ExecStartCheck{Detach: true})
+	}
+	if err != nil {
+		runCtx.Warnf("failed to remove %s from container %s: %s", filePath, containerID, err.Error())
+	}
+}
+
+func streamMessagesToChannel(reader *bufio.Reader, msgChan chan readerNextMessage) error {
 	scanner := util.NewLineOrReturnScanner(reader)
 	for {
 		if !scanner.Scan() {
@@ -8,12 +8,14 @@ import (
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 
+	"github.com/docker/cli/cli/command/image/build"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -101,8 +103,14 @@ func (dockerFile *Dockerfile) Build() (MsgReader, error) {
 		contextPath = dockerFile.ContextPath
 	}
 
+	dockerFileBytes, err := ioutil.ReadFile(dockerFile.FilePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read Dockerfile from %s", dockerFile.FilePath)
+	}
+	rewrittenDockerFile, rewritten := dockerFile.Registries.RewriteDockerfile(string(dockerFileBytes))
+
 	authConfigs := make(map[string]types.AuthConfig)
-	if from, err := ParseFrom(dockerFile.FilePath); err != nil {
+	if from, err := parseFromContent(rewrittenDockerFile, dockerFile.FilePath); err != nil {
 		return nil, err
 	} else {
 		registry, err := RegistryFromImageReference(from)
@@ -138,6 +146,16 @@ func (dockerFile *Dockerfile) Build() (MsgReader, error) {
 	if err != nil {
 		return nil, err
 	}
+	if rewritten {
+		// sending rewritten Dockerfile within build context instead of the original one
+		var rewrittenTar io.ReadCloser
+		rewrittenTar, buildOptions.Dockerfile, err = build.AddDockerfileToBuildContext(
+			ioutil.NopCloser(strings.NewReader(rewrittenDockerFile)), ioutil.NopCloser(tarOptions))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to add rewritten Dockerfile to build context")
+		}
+		tarOptions = rewrittenTar
+	}
 	resp, err := dockerFile.client.API().ImageBuild(dockerFile.GoContext(), tarOptions, buildOptions)
 	if err != nil {
 		return nil, err
@@ -231,19 +249,54 @@ func ParseFrom(dockerFilePath string) (string, error) {
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to read Dockerfile from %s", dockerFilePath)
 	}
+	return parseFromContent(string(dockerFileBytes), dockerFilePath)
+}
+
+func parseFromContent(dockerFileContents string, dockerFilePath string) (string, error) {
 	refRegex := reference.NameRegexp.String() +
 		"(?::" + reference.TagRegexp.String() + ")?" +
 		"(?:@" + reference.DigestRegexp.String() + ")?"
 	dockerFileRegexp := "(?i)FROM (" + refRegex + ")"
 	fromRegexp := regexp.MustCompile(dockerFileRegexp)
-	if !fromRegexp.Match(dockerFileBytes) {
+	if !fromRegexp.MatchString(dockerFileContents) {
 		return "", errors.Errorf("could not parse provided Dockerfile from %s", dockerFilePath)
 	}
-	dockerFileContents := string(dockerFileBytes)
 	fromValue := fromRegexp.FindStringSubmatch(dockerFileContents)
 	return fromValue[1], nil
 }
 
+var dockerfileFromRegexp = regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)(.*)$`)
+var dockerfileStageRegexp = regexp.MustCompile(`(?i)\s+AS\s+(\S+)`)
+
+// ParseBaseImages returns images referenced by all FROM instructions of the Dockerfile
+func ParseBaseImages(dockerFileContents string) []string {
+	var res []string
+	visitDockerfileBaseImages(strings.Split(dockerFileContents, "\n"), func(_ int, _ string, image string, _ string) {
+		res = append(res, image)
+	})
+	return res
+}
+
+// visitDockerfileBaseImages invokes callback for every FROM instruction referencing an external image
+// (references to previous stages, scratch and images defined by build args are skipped)
+func visitDockerfileBaseImages(lines []string, callback func(lineIdx int, fromPrefix string, image string, rest string)) {
+	stages := make(map[string]bool)
+	for i, line := range lines {
+		match := dockerfileFromRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		image, rest := match[2], match[3]
+		skip := stages[strings.ToLower(image)] || strings.EqualFold(image, "scratch") || strings.Contains(image, "$")
+		if stage := dockerfileStageRegexp.FindStringSubmatch(rest); stage != nil {
+			stages[strings.ToLower(stage[1])] = true
+		}
+		if !skip {
+			callback(i, match[1], image, rest)
+		}
+	}
+}
+
 // calcConfigHash calculates hash sum of configuration (to figure out whether container needs to be re-created)
 func (dockerFile *Dockerfile) calcConfigHash() (string, error) {
 	var b bytes.Buffer
@@ -65,6 +65,17 @@ func TestParseFrom(t *testing.T) {
 	Expect(from).To(Equal("ubuntu:latest"))
 }
 
+func TestParseBaseImages(t *testing.T) {
+	RegisterTestingT(t)
+	Expect(ParseBaseImages(`ARG BASE=alpine
+FROM golang:1.22 AS builder
+FROM --platform=linux/amd64 ${BASE}
+FROM builder AS tests
+FROM scratch
+from ubuntu:22.04
+`)).To(Equal([]string{"golang:1.22", "ubuntu:22.04"}))
+}
+
 func TestInvalidDockerfile_Build(t *testing.T) {
 	dockerFile := newDockerFile(t, "testdata/InvalidDockerfile")
 
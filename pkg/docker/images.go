@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// ImageExistsLocally returns true if image with the provided reference is present in the local Docker daemon
+func (u *DockerUtil) ImageExistsLocally(ref string) (bool, error) {
+	if _, _, err := u.docker.ImageInspectWithRaw(u.GoContext(), ref); err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to inspect Docker image %s", ref)
+	}
+	return true, nil
+}
+
+// MissingImages returns references of the provided images that are not present in the local Docker daemon
+func (u *DockerUtil) MissingImages(refs []string) ([]string, error) {
+	var missing []string
+	for _, ref := range refs {
+		exists, err := u.ImageExistsLocally(ref)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, ref)
+		}
+	}
+	return missing, nil
+}
+
+// SaveImages writes tarball with the provided images (in 'docker save' format) to the writer
+func (u *DockerUtil) SaveImages(refs []string, writer io.Writer) error {
+	reader, err := u.docker.ImageSave(u.GoContext(), refs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save images")
+	}
+	defer func() { _ = reader.Close() }()
+	if _, err := io.Copy(writer, reader); err != nil {
+		return errors.Wrapf(err, "failed to write saved images")
+	}
+	return nil
+}
+
+// LoadImages loads images from the tarball (in 'docker save' format) into the local Docker daemon
+func (u *DockerUtil) LoadImages(reader io.Reader, callback MsgCallback) error {
+	resp, err := u.docker.ImageLoad(u.GoContext(), reader, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load images")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if !resp.JSON {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	if err := waitForMessages(resp.Body, callback); err != nil {
+		return errors.Wrapf(err, "failed to load images")
+	}
+	return nil
+}
+
+// waitForMessages streams Docker daemon messages from the reader to the callback until EOF
+func waitForMessages(reader io.Reader, callback MsgCallback) error {
+	var eg errgroup.Group
+	dockerMsgReader := chanMsgReader{msgChan: make(chan readerNextMessage)}
+	eg.Go(func() error {
+		return streamMessagesToChannel(bufio.NewReader(reader), dockerMsgReader.msgChan)
+	})
+	if err := dockerMsgReader.Listen(false, callback); err != nil {
+		// drain remaining messages so that streaming goroutine can finish
+		go func() {
+			for next := range dockerMsgReader.msgChan {
+				if next.EOF {
+					return
+				}
+			}
+		}()
+		return err
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// PullImage pulls image for the specified platform (using auth from Docker config) and waits until it's pulled
+func (u *DockerUtil) PullImage(ref string, platform string, callback MsgCallback) error {
+	registry, err := RegistryFromImageReference(ref)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve registry of %s", ref)
+	}
+	reader, err := u.docker.ImagePull(u.GoContext(), ref, types.ImagePullOptions{
+		RegistryAuth: registry.AuthHeader,
+		Platform:     platform,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to pull image %s", ref)
+	}
+	defer func() { _ = reader.Close() }()
+
+	if err := waitForMessages(reader, callback); err != nil {
+		return errors.Wrapf(err, "failed to pull image %s", ref)
+	}
+	return nil
+}
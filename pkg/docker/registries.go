@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
+
+// RegistriesConfig defines rules to rewrite references of images that are pulled
+// (e.g. to pull images through a proxy registry in CI)
+type RegistriesConfig struct {
+	Mirrors []RegistryMirror   `yaml:"mirrors,omitempty" json:"mirrors,omitempty" jsonschema:"title=Mirrors to pull images of registries from"`
+	Rewrite []ImageRewriteRule `yaml:"rewrite,omitempty" json:"rewrite,omitempty" jsonschema:"title=Rules to rewrite image references by prefix (applied before mirrors)"`
+}
+
+// RegistryMirror redirects pulls of images from registry to its mirror
+type RegistryMirror struct {
+	Registry string `yaml:"registry,omitempty" json:"registry,omitempty" jsonschema:"title=Registry to mirror,example=docker.io"`
+	Mirror   string `yaml:"mirror,omitempty" json:"mirror,omitempty" jsonschema:"title=Mirror registry (optionally with path prefix),example=docker-proxy.example.com/hub"`
+}
+
+// ImageRewriteRule replaces prefix of image reference
+type ImageRewriteRule struct {
+	From string `yaml:"from,omitempty" json:"from,omitempty" jsonschema:"title=Prefix of image reference to replace,example=docker-proxy.example.com/"`
+	To   string `yaml:"to,omitempty" json:"to,omitempty" jsonschema:"title=Replacement of the prefix,example=docker.example.com/"`
+}
+
+// DefaultRegistries defines built-in rules applied after the rules of the user config and the project
+// (images of the legacy proxy registry are pulled from docker.simple-container.com)
+var DefaultRegistries = RegistriesConfig{
+	Rewrite: []ImageRewriteRule{
+		{From: "docker-proxy.services.atlassian.com/", To: "docker.simple-container.com/"},
+	},
+}
+
+// IsEmpty returns true if there are no rules defined
+func (c RegistriesConfig) IsEmpty() bool {
+	return len(c.Mirrors) == 0 && len(c.Rewrite) == 0
+}
+
+// Merge returns config with rules of both configs (rules of the current config take precedence)
+func (c RegistriesConfig) Merge(other RegistriesConfig) RegistriesConfig {
+	return RegistriesConfig{
+		Mirrors: append(append([]RegistryMirror{}, c.Mirrors...), other.Mirrors...),
+		Rewrite: append(append([]ImageRewriteRule{}, c.Rewrite...), other.Rewrite...),
+	}
+}
+
+// RewriteImageReference applies first matching rewrite rule and then first matching mirror to the image reference
+func (c RegistriesConfig) RewriteImageReference(ref string) string {
+	res := ref
+	for _, rule := range c.Rewrite {
+		if rule.From != "" && strings.HasPrefix(res, rule.From) {
+			res = rule.To + strings.TrimPrefix(res, rule.From)
+			break
+		}
+	}
+	if len(c.Mirrors) == 0 {
+		return res
+	}
+	named, err := reference.ParseNormalizedNamed(res)
+	if err != nil {
+		return res
+	}
+	domain := normalizeRegistryDomain(reference.Domain(named))
+	for _, mirror := range c.Mirrors {
+		if mirror.Mirror == "" || normalizeRegistryDomain(mirror.Registry) != domain {
+			continue
+		}
+		res = strings.TrimSuffix(mirror.Mirror, "/") + "/" + reference.Path(named)
+		if tagged, ok := named.(reference.Tagged); ok {
+			res += ":" + tagged.Tag()
+		}
+		if digested, ok := named.(reference.Digested); ok {
+			res += "@" + digested.Digest().String()
+		}
+		break
+	}
+	return res
+}
+
+// RewriteDockerfile rewrites images referenced by FROM instructions of the Dockerfile
+// returns false if nothing was changed
+func (c RegistriesConfig) RewriteDockerfile(content string) (string, bool) {
+	if c.IsEmpty() {
+		return content, false
+	}
+	changed := false
+	lines := strings.Split(content, "\n")
+	visitDockerfileBaseImages(lines, func(lineIdx int, fromPrefix string, image string, rest string) {
+		if rewritten := c.RewriteImageReference(image); rewritten != image {
+			lines[lineIdx] = fromPrefix + rewritten + rest
+			changed = true
+		}
+	})
+	return strings.Join(lines, "\n"), changed
+}
+
+func normalizeRegistryDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "/"))
+	switch domain {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return domain
+}
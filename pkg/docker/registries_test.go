@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRewriteImageReference(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := RegistriesConfig{
+		Rewrite: []ImageRewriteRule{
+			{From: "docker-proxy.services.example.com/", To: "docker.example.com/"},
+		},
+		Mirrors: []RegistryMirror{
+			{Registry: "docker.io", Mirror: "proxy.internal/hub/"},
+			{Registry: "ghcr.io", Mirror: "proxy.internal/ghcr"},
+		},
+	}
+
+	testCases := []struct {
+		ref      string
+		expected string
+	}{
+		{ref: "alpine:latest", expected: "proxy.internal/hub/library/alpine:latest"},
+		{ref: "alpine", expected: "proxy.internal/hub/library/alpine"},
+		{ref: "index.docker.io/smecsia/welder:1.0", expected: "proxy.internal/hub/smecsia/welder:1.0"},
+		{ref: "ghcr.io/org/image@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			expected: "proxy.internal/ghcr/org/image@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{ref: "docker-proxy.services.example.com/sox/go:1.22", expected: "docker.example.com/sox/go:1.22"},
+		{ref: "quay.io/org/image:1", expected: "quay.io/org/image:1"},
+		{ref: "not a reference", expected: "not a reference"},
+	}
+	for _, tc := range testCases {
+		Expect(cfg.RewriteImageReference(tc.ref)).To(Equal(tc.expected), tc.ref)
+	}
+
+	Expect(RegistriesConfig{}.RewriteImageReference("alpine")).To(Equal("alpine"))
+
+	// rules of the current config take precedence
+	merged := RegistriesConfig{Mirrors: []RegistryMirror{{Registry: "docker.io", Mirror: "user.mirror"}}}.Merge(cfg)
+	Expect(merged.RewriteImageReference("alpine:3")).To(Equal("user.mirror/library/alpine:3"))
+
+	// built-in rules are applied unless configured rules match the image
+	withDefaults := cfg.Merge(DefaultRegistries)
+	Expect(withDefaults.RewriteImageReference("docker-proxy.services.atlassian.com/sox/go:1.22")).
+		To(Equal("docker.simple-container.com/sox/go:1.22"))
+	Expect(withDefaults.RewriteImageReference("docker-proxy.services.example.com/go:1")).To(Equal("docker.example.com/go:1"))
+}
+
+func TestRewriteDockerfile(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := RegistriesConfig{Mirrors: []RegistryMirror{{Registry: "docker.io", Mirror: "proxy.internal"}}}
+
+	res, changed := cfg.RewriteDockerfile(`ARG BASE=alpine
+FROM golang:1.22 AS builder
+RUN go build
+FROM --platform=linux/amd64 ${BASE}
+FROM builder as tests
+FROM scratch
+COPY --from=builder /app /app
+`)
+	Expect(changed).To(BeTrue())
+	Expect(res).To(Equal(`ARG BASE=alpine
+FROM proxy.internal/library/golang:1.22 AS builder
+RUN go build
+FROM --platform=linux/amd64 ${BASE}
+FROM builder as tests
+FROM scratch
+COPY --from=builder /app /app
+`))
+
+	_, changed = cfg.RewriteDockerfile("FROM quay.io/org/image:1\n")
+	Expect(changed).To(BeFalse())
+}
@@ -47,6 +47,7 @@ type Dockerfile struct {
 	BuilderVersion         string
 	DockerIgnoreFile       string
 	SkipHashLabel          bool
+	Registries             RegistriesConfig // rules to rewrite images referenced by FROM instructions
 	id                     string
 	client                 dockerext.DockerCLIExt
 	Context                context.Context
@@ -77,6 +78,26 @@ const (
 	VolumeApproachExternal VolumeApproach = "external"
 )
 
+// PullPolicy defines when images should be pulled from the registry
+type PullPolicy string
+
+const (
+	// PullPolicyAlways pulls image every time (e.g. to refresh mutable tags like ':latest')
+	PullPolicyAlways PullPolicy = "always"
+	// PullPolicyIfNotPresent pulls image only if it is not available locally (default)
+	PullPolicyIfNotPresent PullPolicy = "if-not-present"
+	// PullPolicyNever never pulls image (fails if image is not available locally)
+	PullPolicyNever PullPolicy = "never"
+)
+
+func (PullPolicy) Enum() []interface{} {
+	return []interface{}{
+		PullPolicyAlways,
+		PullPolicyIfNotPresent,
+		PullPolicyNever,
+	}
+}
+
 // Volume defines volume to attach to the container
 type Volume struct {
 	Name     string     `yaml:"name,omitempty"`
@@ -101,6 +122,7 @@ type RunContext struct {
 	ErrorOnExitCode bool                           // return error if exit code != 0
 	Detached        bool                           // run in detached mode (do not destroy after commands finished)
 	Env             []string                       // list of environment variables to inject into commands when running
+	Shell           util.Shell                     // interpreter to execute commands with (default shell if not specified)
 	RunBeforeExec   func(containerID string) error // run this callback before executing commands
 	RunAfterExec    func(containerID string) error // run this callback after executing commands
 	Logger          util.Logger
@@ -130,30 +152,34 @@ type Run struct {
 	RunID     string // identifier for this Docker Run
 	Reference string // base Docker image reference
 
-	volumeBinds       []Volume        // list of volumes to connect in this run
-	volumeMounts      []Volume        // list of volumes to connect in this run
-	ports             []string        // expose ports spec
-	privileged        bool            // request creation of the privileged container
-	mountDockerSocket bool            // allow to interact with Docker from inside the created container (will mount docker.sock)
-	context           context.Context // go context to rely on
-	cleanupOrphans    bool            // remove orphan containers if found before creating new ones
-	reuseContainers   bool            // allow reusing existing containers with the same runID (if found)
-	disableCache      bool            // if true forces to rebuild build image every time
-	volumeApproach    VolumeApproach  // defines how to copy volume binds into container
-	envVars           []string        // list of environment variables to inject into the container when creating
-	stopTimeout       time.Duration   // how long to wait before killing container
-	command           []string        // commands to run in the created container (default: DefaultContainerCommand)
-	entrypoint        []string        // entrypoint for the created container
-	keepEnvVariables  bool            // if true get env after each executed command and pass to the next one
+	volumeBinds       []Volume          // list of volumes to connect in this run
+	volumeMounts      []Volume          // list of volumes to connect in this run
+	ports             []string          // expose ports spec
+	privileged        bool              // request creation of the privileged container
+	mountDockerSocket bool              // allow to interact with Docker from inside the created container (will mount docker.sock)
+	context           context.Context   // go context to rely on
+	cleanupOrphans    bool              // remove orphan containers if found before creating new ones
+	reuseContainers   bool              // allow reusing existing containers with the same runID (if found)
+	disableCache      bool              // if true forces to rebuild build image every time
+	offline           bool              // if true never pulls images (fails if image is not available locally)
+	pullPolicy        PullPolicy        // defines when image should be pulled (default: if-not-present)
+	volumeApproach    VolumeApproach    // defines how to copy volume binds into container
+	envVars           []string          // list of environment variables to inject into the container when creating
+	stopTimeout       time.Duration     // how long to wait before killing container
+	command           []string          // commands to run in the created container (default: DefaultContainerCommand)
+	entrypoint        []string          // entrypoint for the created container
+	keepEnvVariables  bool              // if true get env after each executed command and pass to the next one
+	labels            map[string]string // extra labels to put on the created container
 
 	dockerAPI         *client.Client
 	containerID       string
 	network           NetworkData
 	dockerUtil        *DockerUtil
 	initialConfigHash string
-	osDistribution    OSDistribution // detected OS distribution of the base image
-	useDefaultCommand bool           // if true, container is using default command specified in the image
-	useDefaultUser    bool           // if true, container is using default user specified in the image
+	initialConfig     containerConfig // summary of the configuration the container is created with
+	osDistribution    OSDistribution  // detected OS distribution of the base image
+	useDefaultCommand bool            // if true, container is using default command specified in the image
+	useDefaultUser    bool            // if true, container is using default user specified in the image
 }
 
 // NetworkData represents internal info about created container's network
@@ -3,9 +3,6 @@ package docker
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
-	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"runtime"
 	"time"
@@ -39,6 +36,16 @@ func (run *Run) SetCommand(command ...string) *Run {
 	return run
 }
 
+func (run *Run) AddLabels(labels map[string]string) *Run {
+	if run.labels == nil {
+		run.labels = make(map[string]string)
+	}
+	for k, v := range labels {
+		run.labels[k] = v
+	}
+	return run
+}
+
 func (run *Run) SetStopTimeout(timeout time.Duration) *Run {
 	run.stopTimeout = timeout
 	return run
@@ -87,6 +94,16 @@ func (run *Run) SetDisableCache(val bool) *Run {
 	return run
 }
 
+func (run *Run) SetOffline(val bool) *Run {
+	run.offline = val
+	return run
+}
+
+func (run *Run) SetPullPolicy(policy PullPolicy) *Run {
+	run.pullPolicy = policy
+	return run
+}
+
 func (run *Run) SetContext(ctx context.Context) *Run {
 	run.context = ctx
 	return run
@@ -151,18 +168,8 @@ func (run *Run) GoContext() context.Context {
 
 // calcConfigHash calculates hash sum of configuration (to figure out whether container needs to be re-created)
 func (run *Run) calcConfigHash(runCtx RunContext) (string, error) {
-	var b bytes.Buffer
-	gob.Register(run.volumeBinds)
-	err := gob.NewEncoder(&b).Encode([]interface{}{
-		run.Reference, run.privileged, run.mountDockerSocket,
-		run.envVars, run.entrypoint, run.command,
-		run.volumeBinds, run.volumeMounts, run.ports,
-		runCtx.User, runCtx.CurrentOS, runCtx.CurrentCI.Name,
-		runCtx.Env,
-	})
-	hash := md5.New()
-	hash.Write(b.Bytes())
-	return hex.EncodeToString(hash.Sum(nil)), err
+	run.initialConfig = run.calcContainerConfig(runCtx)
+	return run.initialConfig.hash()
 }
 
 // RunContext helpers
@@ -264,6 +271,7 @@ func (ctx *RunContext) Clone() RunContext {
 		ErrorOnExitCode: ctx.ErrorOnExitCode,
 		Logger:          ctx.Logger,
 		Env:             make([]string, len(ctx.Env)),
+		Shell:           ctx.Shell,
 		RunBeforeExec:   ctx.RunBeforeExec,
 		RunAfterExec:    ctx.RunAfterExec,
 	}
@@ -275,6 +283,15 @@ func (ctx *RunContext) Debugf(msgfmt string, args ...interface{}) {
 	ctx.logger(true).Debugf(msgfmt, args...)
 }
 
+// Logf prints message regardless of debug mode
+func (ctx *RunContext) Logf(msgfmt string, args ...interface{}) {
+	if ctx.Logger != nil {
+		ctx.Logger.Logf(msgfmt, args...)
+		return
+	}
+	util.NewStdoutLogger(ctx.Stdout, ctx.Stderr).Logf(msgfmt, args...)
+}
+
 func (ctx *RunContext) Warnf(msgfmt string, args ...interface{}) {
 	ctx.logger(false).Log(color.RedString("WARN: ") + color.YellowString(fmt.Sprintf(msgfmt), args...))
 }
@@ -312,12 +312,17 @@ func (u *DockerUtil) WaitUntilContainerExits(containerID string) error {
 	}
 }
 
-// StreamContainerLogsTo returns true if container exists
+// StreamContainerLogsTo follows logs of the container and streams them to the provided writers
 func (u *DockerUtil) StreamContainerLogsTo(containerID string, stdout io.Writer, stderr io.Writer) error {
+	return u.StreamContainerLogs(containerID, stdout, stderr, true)
+}
+
+// StreamContainerLogs streams logs of the container to the provided writers (optionally following new output)
+func (u *DockerUtil) StreamContainerLogs(containerID string, stdout io.Writer, stderr io.Writer, follow bool) error {
 	reader, err := u.docker.ContainerLogs(u.GoContext(), containerID, types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Follow:     true,
+		Follow:     follow,
 	})
 	if err != nil {
 		return err
@@ -23,6 +23,7 @@ type Exec struct {
 	context    context.Context
 	output     *bytes.Buffer
 	resEnvFile string
+	scriptFile string
 }
 
 // ExecRes result of execution
@@ -33,8 +34,10 @@ type ExecRes struct {
 
 // Opts execution options
 type Opts struct {
-	Wd  string
-	Env []string
+	Wd       string
+	Env      []string
+	CleanEnv bool       // if true, command starts with the provided env only (instead of inheriting host environment)
+	Shell    util.Shell // interpreter to execute command with (sh if not specified)
 }
 
 // NewExec initializes new host executor
@@ -58,7 +61,11 @@ func Lookup(command string) (string, error) {
 func (e *Exec) ExecCommandAndLog(subject string, cmd string, opts Opts) (ExecRes, error) {
 	res := ExecRes{}
 	e.logger.Debugf("Executing %q", cmd)
-	run := e.prepareCommand(cmd, opts)
+	run, err := e.prepareCommand(cmd, opts)
+	if err != nil {
+		return res, err
+	}
+	defer e.removeScriptFile()
 	var eg errgroup.Group
 
 	logReader, logWriter := io.Pipe()
@@ -84,7 +91,7 @@ func (e *Exec) ExecCommandAndLog(subject string, cmd string, opts Opts) (ExecRes
 	}
 	res.Pid = run.ProcessState.Pid()
 	res.Env = []string{}
-	_, err := os.Stat(e.resEnvFile)
+	_, err = os.Stat(e.resEnvFile)
 	if !os.IsNotExist(err) {
 		if envFileBytes, err := os.ReadFile(e.resEnvFile); err == nil {
 			res.Env = strings.Split(string(envFileBytes), "\n")
@@ -105,7 +112,11 @@ func (e *Exec) ExecCommandAndLog(subject string, cmd string, opts Opts) (ExecRes
 // ExecCommand executes command and returns output
 func (e *Exec) ExecCommand(cmd string, opts Opts) (string, error) {
 	e.logger.Debugf("Executing '%s'", cmd)
-	run := e.prepareCommand(cmd, opts)
+	run, err := e.prepareCommand(cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer e.removeScriptFile()
 	res, err := run.CombinedOutput()
 	return string(res), err
 }
@@ -113,7 +124,11 @@ func (e *Exec) ExecCommand(cmd string, opts Opts) (string, error) {
 // ProxyExec executes command with all binding to parent process
 func (e *Exec) ProxyExec(cmd string, opts Opts) error {
 	e.logger.Debugf("Executing '%s'", cmd)
-	run := e.prepareCommand(cmd, opts)
+	run, err := e.prepareCommand(cmd, opts)
+	if err != nil {
+		return err
+	}
+	defer e.removeScriptFile()
 	run.Stdout = os.Stdout
 	run.Stdin = os.Stdin
 	run.Stderr = os.Stderr
@@ -143,6 +158,7 @@ func (e *Exec) ProxyExec(cmd string, opts Opts) error {
 			var waitStatus syscall.WaitStatus
 			if exitError, ok := err.(*exec.ExitError); ok {
 				waitStatus = exitError.Sys().(syscall.WaitStatus)
+				e.removeScriptFile() // deferred calls are not run on exit
 				os.Exit(waitStatus.ExitStatus())
 			}
 			return err
@@ -151,11 +167,33 @@ func (e *Exec) ProxyExec(cmd string, opts Opts) error {
 	return nil
 }
 
-func (e *Exec) prepareCommand(cmd string, opts Opts) *exec.Cmd {
-	e.resEnvFile = fmt.Sprintf("/tmp/%s.env", uuid.New().String())
-	args := []string{"-c", fmt.Sprintf(`trap "env > %s" EXIT; %s`, e.resEnvFile, cmd)}
-	run := exec.CommandContext(e.context, "sh", args...)
-	if len(opts.Env) > 0 {
+func (e *Exec) prepareCommand(cmd string, opts Opts) (*exec.Cmd, error) {
+	e.resEnvFile = ""
+	if opts.Shell.IsPosix() { // only POSIX shells can write environment on exit
+		e.resEnvFile = fmt.Sprintf("/tmp/%s.env", uuid.New().String())
+	}
+	e.scriptFile = ""
+	if opts.Shell.IsScriptFile() {
+		scriptFile, err := os.CreateTemp("", "welder-script-*")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create script file")
+		}
+		e.scriptFile = scriptFile.Name()
+		_, err = scriptFile.WriteString(cmd)
+		if closeErr := scriptFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			e.removeScriptFile()
+			return nil, errors.Wrapf(err, "failed to write script file")
+		}
+		cmd = e.scriptFile
+	}
+	command := opts.Shell.Cmd("sh", cmd, e.resEnvFile)
+	run := exec.CommandContext(e.context, command[0], command[1:]...)
+	if opts.CleanEnv {
+		run.Env = append([]string{}, opts.Env...)
+	} else if len(opts.Env) > 0 {
 		run.Env = os.Environ()
 		for _, env := range opts.Env {
 			run.Env = append(run.Env, env)
@@ -164,7 +202,18 @@ func (e *Exec) prepareCommand(cmd string, opts Opts) *exec.Cmd {
 	if opts.Wd != "" {
 		run.Dir = opts.Wd
 	}
-	return run
+	return run, nil
+}
+
+// removeScriptFile removes script file written for interpreters which expect path to the script (if any)
+func (e *Exec) removeScriptFile() {
+	if e.scriptFile == "" {
+		return
+	}
+	if err := os.Remove(e.scriptFile); err != nil && !os.IsNotExist(err) {
+		e.logger.Debugf("failed to remove script file %s: %s", e.scriptFile, err.Error())
+	}
+	e.scriptFile = ""
 }
 
 func commandExists(cmd string) bool {
@@ -9,19 +9,23 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
 	"github.com/simple-container-com/welder/pkg/util"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
 )
@@ -32,6 +36,8 @@ const (
 	refspecTag              = "+refs/tags/%s:refs/tags/%[1]s"
 	refspecSingleBranch     = "+refs/heads/%s:refs/remotes/%s/%[1]s"
 	refspecSingleBranchHEAD = "+HEAD:refs/remotes/%s/HEAD"
+	refspecPushHEAD         = "HEAD:refs/heads/%s"
+	refspecPushTag          = "refs/tags/%s:refs/tags/%[1]s"
 )
 
 type Git interface {
@@ -45,6 +51,17 @@ type Git interface {
 	Alternates() ([]string, error)
 	Worktrees() ([]string, error)
 	Remotes() ([]Remote, error)
+	Tag() (string, error)
+	Describe(match string) (Description, error)
+	DescribeRevision(revision string, match string) (Description, error)
+	HeadCommit() (CommitInfo, error)
+	ChangedFiles(since string) ([]string, error)
+	Commits(since string, until string, paths ...string) ([]CommitInfo, error)
+	Commit(msg string) (string, error)
+	CreateTag(tagName string, msg string) error
+	DeleteTag(tagName string) error
+	Reset(revision string) error
+	Push(branch string, tagNames ...string) error
 }
 
 type Remote struct {
@@ -52,6 +69,21 @@ type Remote struct {
 	URLs []string
 }
 
+// Description defines position of HEAD relative to the latest tag reachable from it (similar to `git describe --tags`)
+type Description struct {
+	Tag      string // empty if there are no tags reachable from HEAD
+	Distance int    // number of commits since the tag (number of all commits if there are no tags)
+}
+
+// CommitInfo defines details of a commit
+type CommitInfo struct {
+	Hash      string
+	Author    string
+	Email     string
+	Message   string
+	Timestamp time.Time // committer time
+}
+
 type GitImpl struct {
 	RootPath   string
 	PushBranch string
@@ -191,6 +223,198 @@ func (ctx *GitImpl) Branch() (string, error) {
 	return name, nil
 }
 
+// Tag returns tag pointing to HEAD (the latest one if there are several), empty if HEAD isn't tagged
+func (ctx *GitImpl) Tag() (string, error) {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to resolve HEAD")
+	}
+	tags, err := tagsByCommit(r)
+	if err != nil {
+		return "", err
+	}
+	return latestTag(tags[head.Hash()]), nil
+}
+
+// Describe returns the latest tag reachable from HEAD along with number of commits made since it,
+// only tags matching the glob pattern are considered (e.g. `api-*`, all tags if match is empty)
+func (ctx *GitImpl) Describe(match string) (Description, error) {
+	return ctx.DescribeRevision("HEAD", match)
+}
+
+// DescribeRevision returns the latest tag reachable from the revision (e.g. `v1.2.0` or `HEAD~1`) along with number
+// of commits made since it, only tags matching the glob pattern are considered (all tags if match is empty)
+func (ctx *GitImpl) DescribeRevision(revision string, match string) (Description, error) {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return Description{}, err
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return Description{}, errors.Wrapf(err, "unable to resolve revision %s", revision)
+	}
+	tags, err := tagsByCommit(r)
+	if err != nil {
+		return Description{}, err
+	}
+	if tags, err = matchingTags(tags, match); err != nil {
+		return Description{}, err
+	}
+	commits, err := r.Log(&git.LogOptions{From: *hash, Order: git.LogOrderBSF})
+	if err != nil {
+		return Description{}, errors.Wrapf(err, "failed to read git log")
+	}
+	var res Description
+	var tagged *plumbing.Hash
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if tag := latestTag(tags[commit.Hash]); tag != "" {
+			res.Tag = tag
+			tagged = &commit.Hash
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return Description{}, errors.Wrapf(err, "failed to read git log")
+	}
+	// distance is the number of commits reachable from the revision but not from the tag (as `git describe` counts it)
+	excluded := make(map[plumbing.Hash]bool)
+	if tagged != nil {
+		if excluded, err = reachableCommits(r, *tagged); err != nil {
+			return Description{}, err
+		}
+	}
+	reachable, err := reachableCommits(r, *hash)
+	if err != nil {
+		return Description{}, err
+	}
+	for commitHash := range reachable {
+		if !excluded[commitHash] {
+			res.Distance++
+		}
+	}
+	return res, nil
+}
+
+// reachableCommits returns hashes of all commits reachable from the commit (including itself)
+func reachableCommits(r *git.Repository, hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	commits, err := r.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read git log")
+	}
+	res := make(map[plumbing.Hash]bool)
+	err = commits.ForEach(func(commit *object.Commit) error {
+		res[commit.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read git log")
+	}
+	return res, nil
+}
+
+// HeadCommit returns details of the latest commit
+func (ctx *GitImpl) HeadCommit() (CommitInfo, error) {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return CommitInfo{}, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return CommitInfo{}, errors.Wrap(err, "unable to resolve HEAD")
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return CommitInfo{}, errors.Wrapf(err, "failed to read commit %s", head.Hash())
+	}
+	return commitInfo(commit), nil
+}
+
+// Commits returns commits reachable from until revision (HEAD if empty) but not from since revision (all commits
+// if since is empty), newest first; when paths are specified only commits changing files within them are returned
+// (e.g. `services/api`)
+func (ctx *GitImpl) Commits(since string, until string, paths ...string) ([]CommitInfo, error) {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return nil, err
+	}
+	if until == "" {
+		until = "HEAD"
+	}
+	untilHash, err := r.ResolveRevision(plumbing.Revision(until))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to resolve revision %s", until)
+	}
+	excluded := make(map[plumbing.Hash]bool)
+	if since != "" {
+		sinceHash, err := r.ResolveRevision(plumbing.Revision(since))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve revision %s", since)
+		}
+		if excluded, err = reachableCommits(r, *sinceHash); err != nil {
+			return nil, err
+		}
+	}
+	commits, err := r.Log(&git.LogOptions{From: *untilHash})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read git log")
+	}
+	var res []CommitInfo
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if excluded[commit.Hash] {
+			return nil
+		}
+		if len(paths) > 0 {
+			changed, err := commitChangesPaths(commit, paths)
+			if err != nil || !changed {
+				return err
+			}
+		}
+		res = append(res, commitInfo(commit))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read git log")
+	}
+	return res, nil
+}
+
+// ChangedFiles returns sorted list of files changed between the revision and HEAD (all files of HEAD if since is empty)
+func (ctx *GitImpl) ChangedFiles(since string) ([]string, error) {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := revisionTree(r, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var sinceTree *object.Tree
+	if since != "" {
+		if sinceTree, err = revisionTree(r, since); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(sinceTree, headTree)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s with HEAD", since)
+	}
+	res := make([]string, 0, len(changes))
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" {
+				res = util.AddIfNotExist(res, name)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
 func (ctx *GitImpl) Remotes() ([]Remote, error) {
 	r, _, err := ctx.gitWorkTree()
 	if err != nil {
@@ -280,6 +504,130 @@ func (ctx *GitImpl) CreateTagAndPush(tagName string) error {
 	return nil
 }
 
+// Commit commits all changes of tracked files without pushing them, returns hash of the commit
+func (ctx *GitImpl) Commit(msg string) (string, error) {
+	r, wt, err := ctx.gitWorkTree()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read worktree")
+	}
+	author, err := ctx.signature(r)
+	if err != nil {
+		return "", err
+	}
+	hash, err := wt.Commit(msg, &git.CommitOptions{All: true, Author: author})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to commit with msg=%q, author=%q", msg, author.Name)
+	}
+	return hash.String(), nil
+}
+
+// CreateTag creates tag pointing to HEAD without pushing it (annotated tag if message is specified)
+func (ctx *GitImpl) CreateTag(tagName string, msg string) error {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return errors.Wrap(err, "unable to resolve HEAD")
+	}
+	var opts *git.CreateTagOptions
+	if msg != "" {
+		tagger, err := ctx.signature(r)
+		if err != nil {
+			return err
+		}
+		opts = &git.CreateTagOptions{Tagger: tagger, Message: msg}
+	}
+	if _, err := r.CreateTag(tagName, head.Hash(), opts); err != nil {
+		return errors.Wrapf(err, "failed to create tag %s", tagName)
+	}
+	return nil
+}
+
+// DeleteTag deletes local tag
+func (ctx *GitImpl) DeleteTag(tagName string) error {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return err
+	}
+	if err := r.DeleteTag(tagName); err != nil {
+		return errors.Wrapf(err, "failed to delete tag %s", tagName)
+	}
+	return nil
+}
+
+// Reset resets HEAD to the revision discarding changes of tracked files (similar to `git reset --hard`)
+func (ctx *GitImpl) Reset(revision string) error {
+	r, wt, err := ctx.gitWorkTree()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read worktree")
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve revision %s", revision)
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: *hash, Mode: git.HardReset}); err != nil {
+		return errors.Wrapf(err, "failed to reset to %s", revision)
+	}
+	return nil
+}
+
+// Push pushes HEAD to the branch of the remote along with the tags
+func (ctx *GitImpl) Push(branch string, tagNames ...string) error {
+	r, _, err := ctx.gitWorkTree()
+	if err != nil {
+		return err
+	}
+	curUser, err := user.Current()
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect username")
+	}
+	auth, err := ssh.NewSSHAgentAuth(curUser.Username)
+	if err != nil {
+		return errors.Wrapf(err, "failed to init SSH Aget Auth")
+	}
+	refSpecs := []config.RefSpec{config.RefSpec(fmt.Sprintf(refspecPushHEAD, branch))}
+	for _, tagName := range tagNames {
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf(refspecPushTag, tagName)))
+	}
+	err = r.Push(&git.PushOptions{RemoteName: ctx.Remote, RefSpecs: refSpecs, Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.Wrapf(err, "failed to push branch %s and tags %v", branch, tagNames)
+	}
+	return nil
+}
+
+// signature returns signature of the author of commits and tags
+// (configured author, or user of git config of the repository, or the one of global git config)
+func (ctx *GitImpl) signature(r *git.Repository) (*object.Signature, error) {
+	res := &object.Signature{Name: ctx.Author, When: time.Now()}
+	if res.Name != "" {
+		return res, nil
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read git config")
+	}
+	userSection := cfg.Raw.Section("user")
+	if userSection.Option("name") == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			if file, err := os.Open(filepath.Join(homeDir, ".gitconfig")); err == nil {
+				defer file.Close()
+				globalCfg := format.New()
+				if err := format.NewDecoder(file).Decode(globalCfg); err == nil {
+					userSection = globalCfg.Section("user")
+				}
+			}
+		}
+	}
+	res.Name, res.Email = userSection.Option("name"), userSection.Option("email")
+	if res.Name == "" {
+		return nil, errors.New("git author is not configured (set user.name in git config)")
+	}
+	return res, nil
+}
+
 // Alternates returns list of alternates
 func (ctx *GitImpl) Alternates() ([]string, error) {
 	res := make([]string, 0)
@@ -380,6 +728,137 @@ func (ctx *GitImpl) Worktrees() ([]string, error) {
 	return res, nil
 }
 
+func commitInfo(commit *object.Commit) CommitInfo {
+	return CommitInfo{
+		Hash:      commit.Hash.String(),
+		Author:    commit.Author.Name,
+		Email:     commit.Author.Email,
+		Message:   strings.TrimSpace(commit.Message),
+		Timestamp: commit.Committer.When,
+	}
+}
+
+// commitChangesPaths returns true if commit changes files within any of the paths (compared to its first parent)
+func commitChangesPaths(commit *object.Commit, paths []string) (bool, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read tree of commit %s", commit.Hash)
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read parent of commit %s", commit.Hash)
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return false, errors.Wrapf(err, "failed to read tree of commit %s", parent.Hash)
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read changes of commit %s", commit.Hash)
+	}
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && isWithinPaths(name, paths) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func isWithinPaths(file string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p == "." || p == "" || file == p || strings.HasPrefix(file, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingTags returns only tags matching the glob pattern (all tags if pattern is empty)
+func matchingTags(tags map[plumbing.Hash][]string, pattern string) (map[plumbing.Hash][]string, error) {
+	if pattern == "" {
+		return tags, nil
+	}
+	res := make(map[plumbing.Hash][]string)
+	for hash, names := range tags {
+		for _, name := range names {
+			matches, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid tag pattern %q", pattern)
+			}
+			if matches {
+				res[hash] = append(res[hash], name)
+			}
+		}
+	}
+	return res, nil
+}
+
+// revisionTree returns tree of the commit resolved from revision (e.g. `HEAD~1` or tag name)
+func revisionTree(r *git.Repository, revision string) (*object.Tree, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to resolve revision %s", revision)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read commit %s", hash)
+	}
+	return commit.Tree()
+}
+
+// tagsByCommit returns names of tags by hashes of commits they point to (both lightweight and annotated)
+func tagsByCommit(r *git.Repository) (map[plumbing.Hash][]string, error) {
+	tags, err := r.Tags()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list tags")
+	}
+	res := make(map[plumbing.Hash][]string)
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := r.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// tags of non-commit objects are ignored
+				return nil
+			}
+			hash = commit.Hash
+		} else if err != plumbing.ErrObjectNotFound {
+			return err
+		}
+		res[hash] = append(res[hash], ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list tags")
+	}
+	return res, nil
+}
+
+// latestTag returns the greatest tag (by semantic version if tags are versions)
+func latestTag(tags []string) string {
+	res := ""
+	for _, tag := range tags {
+		if res == "" || tagLess(res, tag) {
+			res = tag
+		}
+	}
+	return res
+}
+
+func tagLess(a, b string) bool {
+	aVersion, aErr := semver.NewVersion(a)
+	bVersion, bErr := semver.NewVersion(b)
+	if aErr == nil && bErr == nil {
+		return aVersion.LessThan(bVersion)
+	}
+	return a < b
+}
+
 func (ctx *GitImpl) gitWorkTree() (*git.Repository, *git.Worktree, error) {
 	r, err := git.PlainOpen(ctx.RootPath)
 	if err != nil {
@@ -0,0 +1,56 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestDescribeMergedHistory(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	Expect(err).To(BeNil())
+	wt, err := r.Worktree()
+	Expect(err).To(BeNil())
+	commit := func(content string, parents ...plumbing.Hash) plumbing.Hash {
+		Expect(os.WriteFile(filepath.Join(dir, "file"), []byte(content), 0o644)).To(BeNil())
+		_, err := wt.Add("file")
+		Expect(err).To(BeNil())
+		hash, err := wt.Commit(content, &git.CommitOptions{
+			Author:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			Parents: parents,
+		})
+		Expect(err).To(BeNil())
+		return hash
+	}
+
+	// A - B - C - M
+	//  \         /
+	//   T ------
+	a := commit("a")
+	b := commit("b", a)
+	c := commit("c", b)
+	tagged := commit("t", a)
+	commit("m", c, tagged)
+	_, err = r.CreateTag("v1.0.0", a, nil)
+	Expect(err).To(BeNil())
+	_, err = r.CreateTag("v1.1.0", tagged, nil)
+	Expect(err).To(BeNil())
+
+	// tagged commit is the closest one, but B and C are not reachable from it
+	description, err := New(dir).Describe("")
+	Expect(err).To(BeNil())
+	Expect(description).To(Equal(Description{Tag: "v1.1.0", Distance: 3}))
+
+	description, err = New(dir).DescribeRevision("HEAD~1", "")
+	Expect(err).To(BeNil())
+	Expect(description).To(Equal(Description{Tag: "v1.0.0", Distance: 2}))
+}
@@ -39,6 +39,61 @@ func (m *GitMock) Remotes() ([]git.Remote, error) {
 	return args.Get(0).([]git.Remote), args.Error(1)
 }
 
+func (m *GitMock) Tag() (string, error) {
+	args := m.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (m *GitMock) Describe(match string) (git.Description, error) {
+	args := m.Called(match)
+	return args.Get(0).(git.Description), args.Error(1)
+}
+
+func (m *GitMock) DescribeRevision(revision string, match string) (git.Description, error) {
+	args := m.Called(revision, match)
+	return args.Get(0).(git.Description), args.Error(1)
+}
+
+func (m *GitMock) HeadCommit() (git.CommitInfo, error) {
+	args := m.Called()
+	return args.Get(0).(git.CommitInfo), args.Error(1)
+}
+
+func (m *GitMock) ChangedFiles(since string) ([]string, error) {
+	args := m.Called(since)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *GitMock) Commits(since string, until string, paths ...string) ([]git.CommitInfo, error) {
+	args := m.Called(since, until, paths)
+	return args.Get(0).([]git.CommitInfo), args.Error(1)
+}
+
+func (m *GitMock) Commit(msg string) (string, error) {
+	args := m.Called(msg)
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (m *GitMock) CreateTag(tagName string, msg string) error {
+	args := m.Called(tagName, msg)
+	return args.Error(0)
+}
+
+func (m *GitMock) DeleteTag(tagName string) error {
+	args := m.Called(tagName)
+	return args.Error(0)
+}
+
+func (m *GitMock) Reset(revision string) error {
+	args := m.Called(revision)
+	return args.Error(0)
+}
+
+func (m *GitMock) Push(branch string, tagNames ...string) error {
+	args := m.Called(branch, tagNames)
+	return args.Error(0)
+}
+
 func (m *GitMock) HashShort() (string, error) {
 	args := m.Called()
 	return args.Get(0).(string), args.Error(1)
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+const remoteCacheRemoteName = "origin"
+
+// RemoteCache keeps bare copy of the remote repository to read files at specific revisions
+type RemoteCache struct {
+	URL string
+	Dir string
+}
+
+// NewRemoteCache returns cache of the remote repository stored within cacheRoot
+func NewRemoteCache(cacheRoot string, url string) *RemoteCache {
+	hash := sha256.Sum256([]byte(url))
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+	return &RemoteCache{
+		URL: url,
+		Dir: filepath.Join(cacheRoot, name+"-"+hex.EncodeToString(hash[:])[:12]),
+	}
+}
+
+// Fetch fetches all branches and tags of the remote repository into the cache
+func (c *RemoteCache) Fetch() error {
+	repo, err := c.open()
+	if err != nil {
+		return err
+	}
+	auth, err := remoteAuth(c.URL)
+	if err != nil {
+		return err
+	}
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteCacheRemoteName,
+		RefSpecs: []config.RefSpec{
+			"+refs/heads/*:refs/heads/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+		Tags:  git.AllTags,
+		Force: true,
+		Auth:  auth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.Wrapf(err, "failed to fetch %s", c.URL)
+	}
+	return nil
+}
+
+// Resolve resolves ref (tag, branch or commit hash) to the commit hash using cached state of the repository
+func (c *RemoteCache) Resolve(ref string) (string, error) {
+	repo, err := c.open()
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %q in %s", ref, c.URL)
+	}
+	return hash.String(), nil
+}
+
+// ReadFile reads file from the tree of the specified commit
+func (c *RemoteCache) ReadFile(commit string, filePath string) ([]byte, error) {
+	repo, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	commitObj, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, errors.Wrapf(err, "commit %s of %s is not available", commit, c.URL)
+	}
+	file, err := commitObj.File(strings.TrimPrefix(filepath.ToSlash(filePath), "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s at commit %s of %s", filePath, commit, c.URL)
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s at commit %s of %s", filePath, commit, c.URL)
+	}
+	defer func() { _ = reader.Close() }()
+	return io.ReadAll(reader)
+}
+
+// HasCommit returns true if commit is available in the cache
+func (c *RemoteCache) HasCommit(commit string) bool {
+	repo, err := c.open()
+	if err != nil {
+		return false
+	}
+	_, err = repo.CommitObject(plumbing.NewHash(commit))
+	return err == nil
+}
+
+// open opens cached repository (initializes it if it doesn't exist yet)
+func (c *RemoteCache) open() (*git.Repository, error) {
+	if _, err := os.Stat(c.Dir); err == nil {
+		repo, err := git.PlainOpen(c.Dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open cached repository %s", c.Dir)
+		}
+		return repo, nil
+	}
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "failed to create cache dir %s", c.Dir)
+	}
+	repo, err := git.PlainInit(c.Dir, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to init cached repository %s", c.Dir)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: remoteCacheRemoteName, URLs: []string{c.URL}}); err != nil {
+		return nil, errors.Wrapf(err, "failed to configure remote %s", c.URL)
+	}
+	return repo, nil
+}
+
+// remoteAuth returns SSH agent auth for SSH remotes (other remotes do not need auth or use credentials helper of URL)
+func remoteAuth(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid git url %s", url)
+	}
+	if endpoint.Protocol != "ssh" {
+		return nil, nil
+	}
+	curUser, err := user.Current()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to detect username")
+	}
+	sshUser := endpoint.User
+	if sshUser == "" {
+		sshUser = curUser.Username
+	}
+	auth, err := ssh.NewSSHAgentAuth(sshUser)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to init SSH Agent Auth")
+	}
+	return auth, nil
+}
@@ -27,6 +27,7 @@ const (
 	statusWatchingForChanges = "Watching for changes"
 	connectionDisconnected   = "Disconnected"
 	connectionConnected      = "Connected"
+	agentsFileName           = "mutagen-agents.tar.gz"
 )
 
 var (
@@ -282,13 +283,9 @@ func (m *Mutagen) extractBinaryIfNecessary() (string, error) {
 }
 
 func (m *Mutagen) unzipMutagenToHomeDir() (string, error) {
-	usr, err := user.Current()
+	outputDir, err := binDir()
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to get current user")
-	}
-	outputDir := path.Join(usr.HomeDir, ".mutagen", "bin")
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-		return "", errors.Wrapf(err, "failed to make sure mutagen dir exists")
+		return "", err
 	}
 	m.logger.Debugf("created mutagen dir %s", outputDir)
 
@@ -296,35 +293,23 @@ func (m *Mutagen) unzipMutagenToHomeDir() (string, error) {
 	if _, err := os.Stat(mutagenBinaryPath); err != nil && os.IsNotExist(err) {
 		m.logger.Debugf("mutagen binary not found at %s, unzipping...", mutagenBinaryPath)
 
-		zipFileName := fmt.Sprintf("mutagen-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
-		mutagenZipPath := fmt.Sprintf("build/binaries/mutagen/%s", zipFileName)
-		zipBytes, err := render.GetFile(mutagenZipPath)
+		zipFileName := binaryArchiveName()
+		zipBytes, err := readAsset(zipFileName)
 		if err != nil {
-			return "", errors.Wrapf(err, "failed to get zip bytes from asset %s", mutagenZipPath)
+			return "", err
 		}
-
-		zipFilePath := path.Join(outputDir, zipFileName)
-		m.logger.Debugf("writing mutagen to file %s", zipFilePath)
-		err = ioutil.WriteFile(zipFilePath, zipBytes, os.ModePerm)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to write zip bytes from asset to file %s", zipFilePath)
-		}
-
-		m.logger.Debugf("unzipping mutagen from archive %s", zipFilePath)
-		err = archiver.Unarchive(zipFilePath, outputDir)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to unarchive mutagen from zip file %s", zipFilePath)
+		if err := installBinaryArchive(m.logger, outputDir, zipFileName, zipBytes); err != nil {
+			return "", err
 		}
 	}
 
-	agentsFileName := "mutagen-agents.tar.gz"
 	agentsFilePath := path.Join(outputDir, agentsFileName)
 	if _, err := os.Stat(agentsFilePath); err != nil && os.IsNotExist(err) {
 		m.logger.Debugf("mutagen library file not found at %s, extracting...", agentsFilePath)
 
-		agentsBytes, err := render.GetFile(fmt.Sprintf("build/binaries/mutagen/%s", agentsFileName))
+		agentsBytes, err := readAsset(agentsFileName)
 		if err != nil {
-			return "", errors.Wrapf(err, "failed to get zip bytes from asset %s", agentsFileName)
+			return "", err
 		}
 
 		m.logger.Debugf("writing %s to file %s", agentsFileName, agentsFilePath)
@@ -335,6 +320,82 @@ func (m *Mutagen) unzipMutagenToHomeDir() (string, error) {
 	return mutagenBinaryPath, nil
 }
 
+// DistributionFiles returns files of mutagen distribution for the current platform (e.g. to export them into offline bundle)
+func DistributionFiles() (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	for _, fileName := range []string{binaryArchiveName(), agentsFileName} {
+		content, err := readAsset(fileName)
+		if err != nil {
+			return nil, err
+		}
+		res[fileName] = content
+	}
+	return res, nil
+}
+
+// InstallDistributionFiles installs files of mutagen distribution (e.g. imported from offline bundle) into mutagen dir
+func InstallDistributionFiles(logger util.Logger, files map[string][]byte) (string, error) {
+	outputDir, err := binDir()
+	if err != nil {
+		return "", err
+	}
+	for fileName, content := range files {
+		if fileName == binaryArchiveName() {
+			if err := installBinaryArchive(logger, outputDir, fileName, content); err != nil {
+				return "", err
+			}
+			continue
+		}
+		filePath := path.Join(outputDir, fileName)
+		logger.Debugf("writing %s to file %s", fileName, filePath)
+		if err := ioutil.WriteFile(filePath, content, os.ModePerm); err != nil {
+			return "", errors.Wrapf(err, "failed to write file %s", filePath)
+		}
+	}
+	return path.Join(outputDir, "mutagen"), nil
+}
+
+func binDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get current user")
+	}
+	outputDir := path.Join(usr.HomeDir, ".mutagen", "bin")
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return "", errors.Wrapf(err, "failed to make sure mutagen dir exists")
+	}
+	return outputDir, nil
+}
+
+func binaryArchiveName() string {
+	return fmt.Sprintf("mutagen-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+}
+
+func readAsset(fileName string) ([]byte, error) {
+	assetPath := fmt.Sprintf("build/binaries/mutagen/%s", fileName)
+	content, err := render.GetFile(assetPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get bytes from asset %s", assetPath)
+	}
+	return content, nil
+}
+
+func installBinaryArchive(logger util.Logger, outputDir string, zipFileName string, zipBytes []byte) error {
+	zipFilePath := path.Join(outputDir, zipFileName)
+	logger.Debugf("writing mutagen to file %s", zipFilePath)
+	if err := ioutil.WriteFile(zipFilePath, zipBytes, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "failed to write zip bytes from asset to file %s", zipFilePath)
+	}
+
+	logger.Debugf("unzipping mutagen from archive %s", zipFilePath)
+	zip := archiver.NewZip()
+	zip.OverwriteExisting = true
+	if err := zip.Unarchive(zipFilePath, outputDir); err != nil {
+		return errors.Wrapf(err, "failed to unarchive mutagen from zip file %s", zipFilePath)
+	}
+	return nil
+}
+
 func (m *Mutagen) addCallbackOnTermSignal(callback func() error) {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGKILL)
@@ -205,5 +205,7 @@ func (ctx *BitbucketContext) NewDockerRun(runID string, name string, image strin
 		return nil, errors.Wrapf(err, "failed to configure volumes")
 	}
 
-	return dockerRun, nil
+	return dockerRun.
+		SetOffline(ctx.Offline).
+		SetPullPolicy(ctx.EffectivePullPolicy("")), nil
 }
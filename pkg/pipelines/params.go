@@ -4,12 +4,23 @@ import (
 	"strings"
 
 	"github.com/simple-container-com/welder/pkg/pipelines/schema"
+	"github.com/simple-container-com/welder/pkg/util"
 )
 
 type BitbucketPipelinesRunParams struct {
 	StepName      string // name of the step to run
 	SkipPipes     bool   // if true, skip all pipes
 	AtlassianMode bool   // if true, skip all Atlassian-specific steps and run extra steps
+	Shell         string // interpreter to execute scripts with (default shell if empty)
+	StrictShell   bool   // if true, execute scripts with `set -eo pipefail` semantics
+}
+
+// ShellToRun returns interpreter to execute scripts of the steps with
+func (p *BitbucketPipelinesRunParams) ShellToRun() (util.Shell, error) {
+	if p == nil {
+		return util.Shell{}, nil
+	}
+	return util.ParseShell(p.Shell, p.StrictShell)
 }
 
 var (
@@ -21,13 +32,6 @@ var (
 	}
 )
 
-func (p *BitbucketPipelinesRunParams) NormalizeDockerReference(reference string) string {
-	if strings.HasPrefix(reference, "docker-proxy.services.atlassian.com") {
-		return strings.ReplaceAll(reference, "docker-proxy.services.atlassian.com", "docker.simple-container.com")
-	}
-	return reference
-}
-
 func (p *BitbucketPipelinesRunParams) ShouldSkipScript(scripts schema.Script, index int) bool {
 	if scripts.IsScript(index) {
 		script, err := scripts.GetScript(index)
@@ -95,6 +95,7 @@ func (pipe *BitbucketPipe) Run(runCtx *docker.RunContext) error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to get docker image for pipe %s", pipe.Name)
 	}
+	image = pipe.Registries().RewriteImageReference(image)
 	runID := docker.CleanupDockerID(fmt.Sprintf("%s-%s", pipe.projectName, pipe.Name))
 	dockerRun, err := pipe.NewDockerRun(runID, pipe.Name, image)
 	if err != nil {
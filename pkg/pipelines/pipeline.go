@@ -146,9 +146,7 @@ func (pipelines *BitbucketPipelines) runStep(step schema.Step, runParams *Bitbuc
 	if err != nil {
 		return errors.Wrapf(err, "failed to get Docker image name for step %s", stepName)
 	}
-	if runParams != nil {
-		image = runParams.NormalizeDockerReference(image)
-	}
+	image = pipelines.Registries().RewriteImageReference(image)
 
 	imageRef, err := docker.ResolveDockerImageReference(image)
 	if err != nil {
@@ -169,7 +167,12 @@ func (pipelines *BitbucketPipelines) runStep(step schema.Step, runParams *Bitbuc
 	eg.Go(util.ReaderToLogFunc(logReader, false, "", subCtx.Logger(), fmt.Sprintf("%s with image %s", stepName, imageRef.Reference)))
 	eg.Go(util.ReaderToLogFunc(logReaderErr, true, "ERR: ", subCtx.Logger(), fmt.Sprintf("%s with image %s", stepName, imageRef.Reference)))
 
+	shell, err := runParams.ShellToRun()
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse shell for step %s", stepName)
+	}
 	runCtx := docker.RunContext{
+		Shell:           shell,
 		Stdout:          stdout,
 		Stderr:          stderr,
 		WorkDir:         pipelines.RootDir(),
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,41 +15,153 @@ import (
 func (tpl *Template) extGit(noSubstitution, path string, defaultValue *string) (string, error) {
 	if tpl.git == nil {
 		if tpl.strict {
-			panic("Strict mode enabled, but Git context wasn't found while processing " + noSubstitution)
+			return noSubstitution, errors.New("git repository is not found")
 		}
 		// skip if git is not available
 		return noSubstitution, nil
 	}
-	hash, err := tpl.git.Hash()
+	values, err := tpl.gitValues(path)
 	if err != nil {
+		if defaultValue != nil {
+			return *defaultValue, nil
+		}
 		return noSubstitution, err
 	}
-	hashShort := hash[:7]
+	res, err := util.GetValue(path, values)
 	if err != nil {
+		if defaultValue != nil {
+			return *defaultValue, nil
+		}
 		return noSubstitution, err
 	}
-	branchRaw, err := tpl.git.Branch()
+	return res.(string), nil
+}
+
+// gitValues returns values for git placeholders (only the ones requested by path are read from repository)
+func (tpl *Template) gitValues(path string) (map[string]interface{}, error) {
+	switch strings.SplitN(path, ".", 2)[0] {
+	case "commit":
+		hash, err := tpl.git.Hash()
+		if err != nil {
+			return nil, err
+		}
+		commit := map[string]string{
+			"short": hash[:7],
+			"full":  hash,
+		}
+		if path != "commit.short" && path != "commit.full" {
+			info, err := tpl.git.HeadCommit()
+			if err != nil {
+				return nil, err
+			}
+			commit["author"] = info.Author
+			commit["email"] = info.Email
+			commit["message"] = info.Message
+			commit["timestamp"] = strconv.FormatInt(info.Timestamp.Unix(), 10)
+		}
+		return map[string]interface{}{"commit": commit}, nil
+	case "branch":
+		branchRaw, err := tpl.git.Branch()
+		if err != nil {
+			return nil, err
+		}
+		branchClean := strings.ReplaceAll(branchRaw, "/", "-")
+		return map[string]interface{}{
+			"branch":       branchClean,
+			"branch.raw":   branchRaw,
+			"branch.clean": branchClean,
+		}, nil
+	case "tag":
+		tag, err := tpl.git.Tag()
+		return map[string]interface{}{"tag": tag}, err
+	case "distance":
+		description, err := tpl.git.Describe("")
+		return map[string]interface{}{"distance": strconv.Itoa(description.Distance)}, err
+	case "dirty":
+		clean, _, err := tpl.git.IsWorkTreeClean()
+		return map[string]interface{}{"dirty": strconv.FormatBool(!clean)}, err
+	case "describe":
+		describe, err := tpl.gitDescribe()
+		return map[string]interface{}{"describe": describe}, err
+	case "remote":
+		url, err := tpl.gitRemoteURL()
+		return map[string]interface{}{"remote": map[string]string{"url": url}}, err
+	case "changed":
+		changed, err := tpl.gitChangedFiles(path)
+		return map[string]interface{}{"changed": changed, path: changed}, err
+	}
+	return nil, errors.Errorf("unknown git placeholder: %s", path)
+}
+
+// gitDescribe returns description of HEAD similar to `git describe --tags --dirty --always` (e.g. 1.4.0-12-gabc1234-dirty)
+func (tpl *Template) gitDescribe() (string, error) {
+	description, err := tpl.git.Describe("")
 	if err != nil {
-		return noSubstitution, err
+		return "", err
 	}
-	branchClean := strings.ReplaceAll(branchRaw, "/", "-")
+	hash, err := tpl.git.Hash()
+	if err != nil {
+		return "", err
+	}
+	clean, _, err := tpl.git.IsWorkTreeClean()
+	if err != nil {
+		return "", err
+	}
+	res := hash[:7]
+	if description.Tag != "" && description.Distance == 0 {
+		res = description.Tag
+	} else if description.Tag != "" {
+		res = fmt.Sprintf("%s-%d-g%s", description.Tag, description.Distance, hash[:7])
+	}
+	if !clean {
+		res += "-dirty"
+	}
+	return res, nil
+}
 
-	res, err := util.GetValue(path, map[string]interface{}{
-		"commit": map[string]string{
-			"short": hashShort,
-			"full":  hash,
-		},
-		"branch":       branchClean,
-		"branch.raw":   branchRaw,
-		"branch.clean": branchClean,
-	})
+// gitRemoteURL returns URL of the origin remote (or of the first one if there is no origin)
+func (tpl *Template) gitRemoteURL() (string, error) {
+	remotes, err := tpl.git.Remotes()
 	if err != nil {
-		if defaultValue != nil {
-			return *defaultValue, err
+		return "", err
+	}
+	url := ""
+	for _, remote := range remotes {
+		if len(remote.URLs) > 0 && (url == "" || remote.Name == "origin") {
+			url = remote.URLs[0]
 		}
-		return noSubstitution, err
 	}
-	return res.(string), nil
+	return url, nil
+}
+
+// gitChangedFiles returns space-separated list of changed files:
+// uncommitted (changed), changed by the latest commit (changed.head) or since the latest tag (changed.tag)
+func (tpl *Template) gitChangedFiles(path string) (string, error) {
+	var files []string
+	switch path {
+	case "changed":
+		_, changed, err := tpl.git.IsWorkTreeClean()
+		if err != nil {
+			return "", err
+		}
+		files = strings.Fields(changed)
+	case "changed.head":
+		var err error
+		if files, err = tpl.git.ChangedFiles("HEAD~1"); err != nil {
+			return "", err
+		}
+	case "changed.tag":
+		description, err := tpl.git.Describe("")
+		if err != nil {
+			return "", err
+		}
+		if files, err = tpl.git.ChangedFiles(description.Tag); err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.Errorf("unknown git placeholder: %s", path)
+	}
+	return strings.Join(files, " "), nil
 }
 
 func (tpl *Template) extEnv(noSubstitution, path string, defaultValue *string) (string, error) {
@@ -73,7 +186,7 @@ func (tpl *Template) extUser(noSubstitution, path string, defaultValue *string)
 	})
 	if err != nil {
 		if defaultValue != nil {
-			return *defaultValue, err
+			return *defaultValue, nil
 		}
 		return noSubstitution, err
 	}
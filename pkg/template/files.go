@@ -0,0 +1,108 @@
+package template
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/simple-container-com/welder/pkg/util"
+)
+
+// queryIndexRegexp matches array indexes of queries (e.g. `[0]` of `.a[0].b`)
+var queryIndexRegexp = regexp.MustCompile(`\[(\d+)]`)
+
+// extFile enables placeholders like ${file:VERSION} (trimmed contents of the file)
+func (tpl *Template) extFile(noSubstitution, path string, defaultValue *string) (string, error) {
+	content, err := tpl.readFile(path, defaultValue)
+	if err != nil || content == nil {
+		return valueOrDefault(noSubstitution, defaultValue), err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// extSHA256 enables placeholders like ${sha256:go.sum} (hex-encoded SHA-256 of the file)
+func (tpl *Template) extSHA256(noSubstitution, path string, defaultValue *string) (string, error) {
+	content, err := tpl.readFile(path, defaultValue)
+	if err != nil || content == nil {
+		return valueOrDefault(noSubstitution, defaultValue), err
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// extJSON enables placeholders like ${json:package.json#.version}
+func (tpl *Template) extJSON(noSubstitution, path string, defaultValue *string) (string, error) {
+	return tpl.queryFile(noSubstitution, path, defaultValue, json.Unmarshal)
+}
+
+// extYAML enables placeholders like ${yaml:Chart.yaml#.dependencies[0].version}
+func (tpl *Template) extYAML(noSubstitution, path string, defaultValue *string) (string, error) {
+	return tpl.queryFile(noSubstitution, path, defaultValue, yaml.Unmarshal)
+}
+
+// queryFile reads structured file and returns value by the query specified after `#`
+// scalar values are returned as is, objects and arrays are returned as JSON
+func (tpl *Template) queryFile(noSubstitution, path string, defaultValue *string, unmarshal func([]byte, interface{}) error) (string, error) {
+	filePath, query, _ := strings.Cut(path, "#")
+	content, err := tpl.readFile(filePath, defaultValue)
+	if err != nil || content == nil {
+		return valueOrDefault(noSubstitution, defaultValue), err
+	}
+	var document interface{}
+	if err := unmarshal(content, &document); err != nil {
+		return noSubstitution, errors.Wrapf(err, "failed to parse %s", filePath)
+	}
+	value := document
+	if key := queryKey(query); key != "" {
+		if value, err = util.GetValue(key, document); err != nil || value == nil {
+			if defaultValue != nil {
+				return *defaultValue, nil
+			}
+			return noSubstitution, errors.Errorf("value %q is not found in %s", query, filePath)
+		}
+	}
+	if res, ok := value.(string); ok {
+		return res, nil
+	}
+	var res bytes.Buffer
+	encoder := json.NewEncoder(&res)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return noSubstitution, errors.Wrapf(err, "failed to marshal value %q of %s", query, filePath)
+	}
+	return strings.TrimSpace(res.String()), nil
+}
+
+// readFile reads file relative to the base dir, returns nil if file doesn't exist and default value is specified
+func (tpl *Template) readFile(path string, defaultValue *string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(tpl.baseDir, path)
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) && defaultValue != nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return content, nil
+}
+
+// queryKey converts query like `.a[0].b` into the key supported by util.GetValue (`a.0.b`)
+func queryKey(query string) string {
+	return strings.TrimPrefix(queryIndexRegexp.ReplaceAllString(query, ".$1"), ".")
+}
+
+func valueOrDefault(value string, defaultValue *string) string {
+	if defaultValue != nil {
+		return *defaultValue
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"strings"
+	gotemplate "text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/pkg/errors"
+)
+
+// pipelineFuncs defines functions available in placeholders' pipelines (e.g. `${git:branch | replace "/" "-"}`)
+var pipelineFuncs = func() gotemplate.FuncMap {
+	res := sprig.TxtFuncMap()
+	res["semverMajor"] = func(version string) (uint64, error) {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return 0, err
+		}
+		return v.Major(), nil
+	}
+	res["semverMinor"] = func(version string) (uint64, error) {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return 0, err
+		}
+		return v.Minor(), nil
+	}
+	res["semverPatch"] = func(version string) (uint64, error) {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return 0, err
+		}
+		return v.Patch(), nil
+	}
+	return res
+}()
+
+// splitPipeline splits tag into the value part and the pipeline (e.g. `arg:branch | lower | trunc 40`)
+// pipeline is recognized only when it starts with a known function, so that values containing `|` are kept intact
+func (tpl *Template) splitPipeline(tag string) (string, string) {
+	inQuotes := false
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '"' && (i == 0 || tag[i-1] != '\\'):
+			inQuotes = !inQuotes
+		case tag[i] != '|' || inQuotes:
+			continue
+		case i+1 < len(tag) && tag[i+1] == '|', i > 0 && tag[i-1] == '|':
+			continue
+		default:
+			pipeline := strings.TrimSpace(tag[i+1:])
+			fields := strings.Fields(pipeline)
+			if len(fields) == 0 {
+				return tag, ""
+			}
+			if _, ok := pipelineFuncs[fields[0]]; !ok {
+				return tag, ""
+			}
+			return strings.TrimSpace(tag[:i]), pipeline
+		}
+	}
+	return tag, ""
+}
+
+// applyPipeline passes value through the pipeline of functions
+func (tpl *Template) applyPipeline(value string, pipeline string) (string, error) {
+	t, err := gotemplate.New("pipeline").Funcs(pipelineFuncs).Parse("{{ .value | " + pipeline + " }}")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse pipeline %q", pipeline)
+	}
+	var res strings.Builder
+	if err := t.Execute(&res, map[string]string{"value": value}); err != nil {
+		return "", errors.Wrapf(err, "failed to apply pipeline %q", pipeline)
+	}
+	return res.String(), nil
+}
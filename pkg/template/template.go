@@ -5,6 +5,8 @@ import (
 	"io"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/simple-container-com/welder/pkg/git"
 	"github.com/simple-container-com/welder/pkg/util"
 	"github.com/valyala/fasttemplate"
@@ -13,11 +15,29 @@ import (
 // Extension allows to extend template engine
 type Extension func(source string, path string, defaultValue *string) (string, error)
 
+// ErrNotAvailable is returned by extensions when placeholder can't be resolved in the current context
+// (e.g. module placeholder outside of module), such placeholders are left as is and aren't considered as failed
+var ErrNotAvailable = errors.New("not available in the current context")
+
+// Failure describes placeholder that failed to resolve in strict mode
+type Failure struct {
+	Placeholder string // e.g. ${arg:build-image}
+	Context     string // e.g. arg
+	Path        string // e.g. build-image
+	Err         error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s: %s", f.Placeholder, f.Err.Error())
+}
+
 // Template defines structure for template engine
 type Template struct {
 	git               git.Git
 	data              util.Data
+	baseDir           string
 	strict            bool
+	failureHandler    func(Failure)
 	extensions        map[string]Extension
 	defaultExtensions map[string]Extension
 }
@@ -26,10 +46,14 @@ type Template struct {
 func NewTemplate() *Template {
 	res := &Template{}
 	res.defaultExtensions = map[string]Extension{
-		"git":  res.extGit,
-		"env":  res.extEnv,
-		"date": res.extDate,
-		"user": res.extUser,
+		"git":    res.extGit,
+		"env":    res.extEnv,
+		"date":   res.extDate,
+		"user":   res.extUser,
+		"file":   res.extFile,
+		"json":   res.extJSON,
+		"yaml":   res.extYAML,
+		"sha256": res.extSHA256,
 	}
 	return res
 }
@@ -40,6 +64,14 @@ func (tpl *Template) WithStrict(strictMode bool) *Template {
 	return tpl
 }
 
+// WithFailureHandler sets handler of the placeholders that failed to resolve in strict mode
+// when handler is set, failed placeholders are left as is instead of embedding errors into the result;
+// placeholders of extensions that are left unresolved (e.g. unknown ${arg:name} without default) are reported as well
+func (tpl *Template) WithFailureHandler(handler func(Failure)) *Template {
+	tpl.failureHandler = handler
+	return tpl
+}
+
 // WithExtensions sets extensions
 func (tpl *Template) WithExtensions(extensions map[string]Extension) *Template {
 	tpl.extensions = extensions
@@ -52,6 +84,12 @@ func (tpl *Template) WithGit(gitObject git.Git) *Template {
 	return tpl
 }
 
+// WithBaseDir sets directory relative paths of file placeholders are resolved against
+func (tpl *Template) WithBaseDir(baseDir string) *Template {
+	tpl.baseDir = baseDir
+	return tpl
+}
+
 // WithData sets extra data for templates
 func (tpl *Template) WithData(data util.Data) *Template {
 	tpl.data = data
@@ -67,6 +105,51 @@ func (tpl *Template) Exec(tplString string) string {
 
 func (tpl *Template) calcValue(tag string) string {
 	noSubstitution := fmt.Sprintf("${%s}", tag)
+	valueTag, pipeline := tpl.splitPipeline(tag)
+	res := tpl.calcTagValue(valueTag, noSubstitution)
+	// apply pipeline only when value has been substituted
+	if pipeline == "" || strings.HasPrefix(res, noSubstitution) {
+		return res
+	}
+	piped, err := tpl.applyPipeline(res, pipeline)
+	if err != nil {
+		return tpl.failed(noSubstitution, valueTag, err)
+	}
+	return piped
+}
+
+// failed returns value of the placeholder that failed to resolve reporting the failure in strict mode
+func (tpl *Template) failed(noSubstitution string, tag string, err error) string {
+	if !tpl.strict {
+		return noSubstitution
+	}
+	if tpl.failureHandler == nil {
+		return noSubstitution + "; error: " + err.Error()
+	}
+	parts := strings.SplitN(tag, ":", 3)
+	failure := Failure{Placeholder: noSubstitution, Context: parts[0], Err: err}
+	if len(parts) > 1 {
+		failure.Path = parts[1]
+	}
+	tpl.failureHandler(failure)
+	return noSubstitution
+}
+
+// calcExtensionValue calls extension and reports the failure if extension failed or couldn't resolve the placeholder
+func (tpl *Template) calcExtensionValue(extension Extension, tag string, noSubstitution string, path string, defaultValue *string) string {
+	res, err := extension(noSubstitution, path, defaultValue)
+	if errors.Is(err, ErrNotAvailable) {
+		return noSubstitution
+	} else if err != nil {
+		return tpl.failed(noSubstitution, tag, err)
+	}
+	if res == noSubstitution && tpl.failureHandler != nil {
+		return tpl.failed(noSubstitution, tag, errors.New("no value and no default specified"))
+	}
+	return res
+}
+
+func (tpl *Template) calcTagValue(tag string, noSubstitution string) string {
 	parts := strings.SplitN(tag, ":", 3)
 	context := parts[0]
 
@@ -88,23 +171,11 @@ func (tpl *Template) calcValue(tag string) string {
 	}
 	// check extra extensions first (if registered)
 	if extension, extraExtensionExists := tpl.extensions[context]; extraExtensionExists {
-		if res, err := extension(noSubstitution, path, defaultValue); err == nil {
-			return res
-		} else if tpl.strict {
-			return noSubstitution + "; error: " + err.Error()
-		} else {
-			return noSubstitution
-		}
+		return tpl.calcExtensionValue(extension, tag, noSubstitution, path, defaultValue)
 	}
 	// check default extensions
 	if extension, defaultExtensionExists := tpl.defaultExtensions[context]; defaultExtensionExists {
-		if res, err := extension(noSubstitution, path, defaultValue); err == nil {
-			return res
-		} else if tpl.strict {
-			return noSubstitution + "; error: " + err.Error()
-		} else {
-			return noSubstitution
-		}
+		return tpl.calcExtensionValue(extension, tag, noSubstitution, path, defaultValue)
 	}
 
 	// try to traverse path in different ways
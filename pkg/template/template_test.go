@@ -2,11 +2,14 @@ package template_test
 
 import (
 	"os"
+	"path"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 
+	"github.com/simple-container-com/welder/pkg/git"
 	"github.com/simple-container-com/welder/pkg/git/mock"
 	. "github.com/simple-container-com/welder/pkg/template"
 	"github.com/simple-container-com/welder/pkg/util"
@@ -120,3 +123,159 @@ func TestTemplating(t *testing.T) {
 	Expect(result).To(ContainSubstring("With deep field value: value."))
 	Expect(result).To(ContainSubstring("Another deep field with default: default."))
 }
+
+func TestPipelines(t *testing.T) {
+	RegisterTestingT(t)
+	gitMock := mock.GitMock{}
+	gitMock.On("Hash").Return("1234567890f", nil)
+	gitMock.On("Branch").Return("Feature/Very-Long-Branch-Name-That-Does-Not-Fit-Into-Docker-Tag", nil)
+
+	parsedTpl := NewTemplate().
+		WithData(util.Data{
+			"arg:branch":      "Feature/Some-Branch",
+			"arg:alternative": "a|b",
+			"project:version": "1.2.3",
+		}).
+		WithGit(&gitMock).
+		WithStrict(true)
+
+	result := parsedTpl.Exec(`
+		Lower: ${arg:branch | lower | trunc 10}.
+		Replace: ${git:branch.raw | replace "/" "-" | lower | trunc 20}.
+		Major: ${project:version | semverMajor}.
+		Next minor: ${project:version | semverMinor | add1}.
+		Default: ${arg:not-existing:Default-Value | lower}.
+		No pipeline: ${arg:not-existing:a|b}.
+		Not substituted: ${not-existing | lower}.
+		Invalid: ${arg:branch | trunc "abc"}.
+	`)
+	Expect(result).To(ContainSubstring("Lower: feature/so."))
+	Expect(result).To(ContainSubstring("Replace: feature-very-long-br."))
+	Expect(result).To(ContainSubstring("Major: 1."))
+	Expect(result).To(ContainSubstring("Next minor: 3."))
+	Expect(result).To(ContainSubstring("Default: default-value."))
+	Expect(result).To(ContainSubstring("No pipeline: a|b."))
+	Expect(result).To(ContainSubstring("Not substituted: ${not-existing | lower}."))
+	Expect(result).To(ContainSubstring(`Invalid: ${arg:branch | trunc "abc"}; error: failed to apply pipeline`))
+}
+
+func TestFailureHandler(t *testing.T) {
+	RegisterTestingT(t)
+	var failures []Failure
+	parsedTpl := NewTemplate().WithExtensions(map[string]Extension{
+		"ext": func(noSubs, path string, defaultVal *string) (string, error) {
+			if path == "error-path" {
+				return "", errors.New("expected error")
+			}
+			if defaultVal != nil {
+				return *defaultVal, nil
+			}
+			return noSubs, nil
+		},
+	}).WithStrict(true).WithFailureHandler(func(failure Failure) {
+		failures = append(failures, failure)
+	})
+
+	result := parsedTpl.Exec(`${ext:error-path} ${ext:typo-path} ${ext:typo-path:default} ${UNKNOWN}`)
+	Expect(result).To(Equal("${ext:error-path} ${ext:typo-path} default ${UNKNOWN}"))
+	Expect(failures).To(HaveLen(2))
+	Expect(failures[0].Error()).To(Equal("${ext:error-path}: expected error"))
+	Expect(failures[1].Context).To(Equal("ext"))
+	Expect(failures[1].Path).To(Equal("typo-path"))
+	Expect(failures[1].Error()).To(Equal("${ext:typo-path}: no value and no default specified"))
+}
+
+func TestGitPlaceholders(t *testing.T) {
+	RegisterTestingT(t)
+	gitMock := mock.GitMock{}
+	gitMock.On("Hash").Return("abc1234567890", nil)
+	gitMock.On("Tag").Return("", nil)
+	gitMock.On("Describe", "").Return(git.Description{Tag: "1.4.0", Distance: 12}, nil)
+	gitMock.On("IsWorkTreeClean").Return(false, "main.go\ngo.mod", nil)
+	gitMock.On("HeadCommit").Return(git.CommitInfo{
+		Hash:      "abc1234567890",
+		Author:    "Alice",
+		Email:     "alice@example.com",
+		Message:   "Fix build",
+		Timestamp: time.Unix(1700000000, 0),
+	}, nil)
+	gitMock.On("Remotes").Return([]git.Remote{
+		{Name: "fork", URLs: []string{"git@example.com:alice/project.git"}},
+		{Name: "origin", URLs: []string{"git@example.com:team/project.git"}},
+	}, nil)
+	gitMock.On("ChangedFiles", "1.4.0").Return([]string{"README.md", "main.go"}, nil)
+
+	result := NewTemplate().WithGit(&gitMock).Exec(`
+		Tag: ${git:tag}.
+		Describe: ${git:describe}.
+		Distance: ${git:distance}.
+		Dirty: ${git:dirty}.
+		Author: ${git:commit.author} <${git:commit.email}>.
+		Message: ${git:commit.message}.
+		SOURCE_DATE_EPOCH=${git:commit.timestamp}.
+		Remote: ${git:remote.url}.
+		Changed: ${git:changed}.
+		Changed since tag: ${git:changed.tag}.
+	`)
+	Expect(result).To(ContainSubstring("Tag: ."))
+	Expect(result).To(ContainSubstring("Describe: 1.4.0-12-gabc1234-dirty."))
+	Expect(result).To(ContainSubstring("Distance: 12."))
+	Expect(result).To(ContainSubstring("Dirty: true."))
+	Expect(result).To(ContainSubstring("Author: Alice <alice@example.com>."))
+	Expect(result).To(ContainSubstring("Message: Fix build."))
+	Expect(result).To(ContainSubstring("SOURCE_DATE_EPOCH=1700000000."))
+	Expect(result).To(ContainSubstring("Remote: git@example.com:team/project.git."))
+	Expect(result).To(ContainSubstring("Changed: main.go go.mod."))
+	Expect(result).To(ContainSubstring("Changed since tag: README.md main.go."))
+}
+
+func TestGitAndUserPlaceholdersWithDefaults(t *testing.T) {
+	RegisterTestingT(t)
+	gitMock := mock.GitMock{}
+	gitMock.On("Hash").Return("abc1234567890", nil)
+	gitMock.On("HeadCommit").Return(git.CommitInfo{Hash: "abc1234567890", Author: "Alice"}, nil)
+	gitMock.On("Branch").Return("", errors.New("HEAD is detached"))
+
+	var failures []Failure
+	result := NewTemplate().WithGit(&gitMock).WithStrict(true).WithFailureHandler(func(failure Failure) {
+		failures = append(failures, failure)
+	}).Exec(`
+		Git: ${git:commit.unknown:none} ${git:commit.author:nobody} ${git:branch:detached}.
+		User: ${user:unknown:nobody}.
+	`)
+	Expect(failures).To(BeEmpty())
+	Expect(result).To(ContainSubstring("Git: none Alice detached."))
+	Expect(result).To(ContainSubstring("User: nobody."))
+}
+
+func TestFilePlaceholders(t *testing.T) {
+	RegisterTestingT(t)
+	dir := t.TempDir()
+	Expect(os.WriteFile(path.Join(dir, "VERSION"), []byte("1.2.3\n"), 0o644)).To(BeNil())
+	Expect(os.WriteFile(path.Join(dir, "package.json"), []byte(`{"name": "app", "version": "2.0.1", "private": true,
+		"engines": {"node": ">=18"}, "files": ["dist", "lib"]}`), 0o644)).To(BeNil())
+	Expect(os.WriteFile(path.Join(dir, "Chart.yaml"), []byte("name: app\nversion: 0.3.0\ndependencies:\n"+
+		"  - name: redis\n    version: 17.1.0\n"), 0o644)).To(BeNil())
+
+	parsedTpl := NewTemplate().WithBaseDir(dir).WithStrict(true)
+	result := parsedTpl.Exec(`
+		File: ${file:VERSION}.
+		JSON: ${json:package.json#.version}.
+		JSON bool: ${json:package.json#.private}.
+		JSON object: ${json:package.json#.engines}.
+		JSON array item: ${json:package.json#.files[1]}.
+		YAML: ${yaml:Chart.yaml#.dependencies[0].version}.
+		SHA256: ${sha256:VERSION}.
+		Missing with default: ${file:MISSING:none} ${json:package.json#.missing:none}.
+		Missing: ${yaml:Chart.yaml#.missing}.
+	`)
+	Expect(result).To(ContainSubstring("File: 1.2.3."))
+	Expect(result).To(ContainSubstring("JSON: 2.0.1."))
+	Expect(result).To(ContainSubstring("JSON bool: true."))
+	Expect(result).To(ContainSubstring(`JSON object: {"node":">=18"}.`))
+	Expect(result).To(ContainSubstring("JSON array item: lib."))
+	Expect(result).To(ContainSubstring("YAML: 17.1.0."))
+	Expect(result).To(ContainSubstring("SHA256: d82f34ae9aa41bc4a0cb529a1ac0898fed09d6b479fb1cc44cb66c34f15ee84d."))
+	Expect(result).To(ContainSubstring("Missing with default: none none."))
+	Expect(result).To(ContainSubstring(`Missing: ${yaml:Chart.yaml#.missing}; error: value ".missing" is not found in Chart.yaml.`))
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	RegisterTestingT(t)
+
+	env, err := ParseDotEnv(strings.Join([]string{
+		"# comment",
+		"",
+		"PLAIN=value # inline comment",
+		"export EXPORTED=exported",
+		`DOUBLE="line1\nline2"`,
+		`SINGLE='literal\n'`,
+		"EMPTY=",
+		"WITH_EQ=a=b",
+	}, "\n"))
+	Expect(err).To(BeNil())
+	Expect(env).To(Equal(map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "exported",
+		"DOUBLE":   "line1\nline2",
+		"SINGLE":   `literal\n`,
+		"EMPTY":    "",
+		"WITH_EQ":  "a=b",
+	}))
+
+	_, err = ParseDotEnv("NOT A VALID LINE")
+	Expect(err).NotTo(BeNil())
+	_, err = ParseDotEnv(`UNTERMINATED="value`)
+	Expect(err).NotTo(BeNil())
+}
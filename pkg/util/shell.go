@@ -0,0 +1,112 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/pkg/errors"
+)
+
+// posixShells shells that support `trap`, `set -e` and (optionally) `set -o pipefail`
+var posixShells = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"ash":  true,
+	"dash": true,
+	"zsh":  true,
+	"ksh":  true,
+}
+
+// strictShellPrefix enables `set -eo pipefail` semantics (pipefail is enabled only if shell supports it)
+const strictShellPrefix = "set -e; (set -o pipefail) 2>/dev/null && set -o pipefail; "
+
+// inlineScriptFlags flags of interpreters that pass the script as an argument (e.g. `python3 -c` or `node -e`)
+var inlineScriptFlags = map[string]bool{
+	"-c":     true,
+	"-e":     true,
+	"--eval": true,
+}
+
+// Shell defines interpreter to execute scripts with
+type Shell struct {
+	Command []string // interpreter with its arguments (script is passed as the last argument), default shell if empty
+	Strict  bool     // enable `set -eo pipefail` semantics (POSIX shells only)
+}
+
+// ParseShell parses shell specification (e.g. `bash`, `python3 -c`, `node` or `/bin/zsh -c`)
+// `-c` is appended for POSIX shells to pass the script as an argument, other interpreters are given the path to the
+// script file unless the flag to pass the script inline is specified (e.g. `python3 -c`)
+func ParseShell(spec string, strict bool) (Shell, error) {
+	res := Shell{Strict: strict}
+	if strings.TrimSpace(spec) == "" {
+		return res, nil
+	}
+	command, err := shellquote.Split(spec)
+	if err != nil {
+		return res, errors.Wrapf(err, "failed to parse shell %q", spec)
+	}
+	res.Command = command
+	if res.IsPosix() && !SliceContains(command, "-c") {
+		res.Command = append(command, "-c")
+	}
+	return res, nil
+}
+
+// ShellFromShebang returns shell specification from the shebang line of the script (empty if there is no shebang)
+func ShellFromShebang(script string) string {
+	if !strings.HasPrefix(script, "#!") {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(strings.TrimPrefix(script, "#!"), "\n", 2)[0])
+}
+
+// IsDefault returns true if no interpreter was specified explicitly
+func (s Shell) IsDefault() bool {
+	return len(s.Command) == 0
+}
+
+// IsPosix returns true if interpreter is a POSIX-compatible shell
+func (s Shell) IsPosix() bool {
+	if s.IsDefault() {
+		return true
+	}
+	interpreter := path.Base(s.Command[0])
+	if interpreter == "env" && len(s.Command) > 1 {
+		interpreter = path.Base(s.Command[1])
+	}
+	return posixShells[interpreter]
+}
+
+// IsScriptFile returns true if interpreter expects path to the script file instead of the script itself
+func (s Shell) IsScriptFile() bool {
+	if s.IsDefault() || s.IsPosix() {
+		return false
+	}
+	for _, arg := range s.Command[1:] {
+		if inlineScriptFlags[arg] {
+			return false
+		}
+	}
+	return true
+}
+
+// Cmd returns command line to execute script with (script must be the path to the script file if IsScriptFile is true)
+// defaultShell is used when no interpreter was specified, environment is written to envFile on exit if it is not empty
+// (only POSIX shells support environment capturing)
+func (s Shell) Cmd(defaultShell string, script string, envFile string) []string {
+	command := s.Command
+	if s.IsDefault() {
+		command = []string{defaultShell, "-c"}
+	}
+	if s.IsPosix() {
+		if s.Strict {
+			script = strictShellPrefix + script
+		}
+		if envFile != "" {
+			script = fmt.Sprintf(`trap "env > %s" EXIT; %s`, envFile, script)
+		}
+	}
+	return append(append([]string{}, command...), script)
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseShell(t *testing.T) {
+	RegisterTestingT(t)
+
+	shell, err := ParseShell("", false)
+	Expect(err).To(BeNil())
+	Expect(shell.Cmd("/bin/sh", "echo", "/tmp/env")).To(Equal([]string{"/bin/sh", "-c", `trap "env > /tmp/env" EXIT; echo`}))
+
+	shell, err = ParseShell("bash", true)
+	Expect(err).To(BeNil())
+	Expect(shell.Cmd("/bin/sh", "echo", "")).To(Equal([]string{"bash", "-c", "set -e; (set -o pipefail) 2>/dev/null && set -o pipefail; echo"}))
+
+	shell, err = ParseShell("python3 -u -c", true)
+	Expect(err).To(BeNil())
+	Expect(shell.IsPosix()).To(BeFalse())
+	Expect(shell.Cmd("/bin/sh", "print(1)", "/tmp/env")).To(Equal([]string{"python3", "-u", "-c", "print(1)"}))
+
+	shell, err = ParseShell("node", false)
+	Expect(err).To(BeNil())
+	Expect(shell.IsScriptFile()).To(BeTrue())
+	Expect(shell.Cmd("/bin/sh", "/tmp/script", "/tmp/env")).To(Equal([]string{"node", "/tmp/script"}))
+
+	shell, err = ParseShell("node -e", false)
+	Expect(err).To(BeNil())
+	Expect(shell.IsScriptFile()).To(BeFalse())
+
+	shell, err = ParseShell(ShellFromShebang("#!/usr/bin/env bash\necho"), false)
+	Expect(err).To(BeNil())
+	Expect(shell.Command).To(Equal([]string{"/usr/bin/env", "bash", "-c"}))
+
+	shell, err = ParseShell(ShellFromShebang("#!/bin/bash -e\necho"), false)
+	Expect(err).To(BeNil())
+	Expect(shell.Command).To(Equal([]string{"/bin/bash", "-e", "-c"}))
+
+	shell, err = ParseShell(ShellFromShebang("#!/usr/bin/env ruby\nputs 1"), false)
+	Expect(err).To(BeNil())
+	Expect(shell.IsScriptFile()).To(BeTrue())
+
+	Expect(ShellFromShebang("#!/usr/bin/env bash\necho")).To(Equal("/usr/bin/env bash"))
+	Expect(ShellFromShebang("echo")).To(Equal(""))
+}
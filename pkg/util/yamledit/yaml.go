@@ -2,14 +2,17 @@ package yamledit
 
 import (
 	"bufio"
+	"bytes"
 	"io"
-	"io/ioutil"
 	"os"
-	"reflect"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
-	"gopkg.in/mikefarah/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 type YamlEdit struct {
@@ -17,200 +20,417 @@ type YamlEdit struct {
 	SkipNotExisting bool
 }
 
-func (y *YamlEdit) ModifyProperty(filePath string, yamlPath string, value string) error {
-	yaml.DefaultMapType = reflect.TypeOf(yaml.MapSlice{})
-	return y.readAndUpdate(os.Stdout, filePath, func(dataBucket dataBucketType, currentIndex int) (dataBucketType, error) {
-		return y.updatedChildValue(dataBucket, y.parsePath(yamlPath), value), nil
-	})
+// scalarEdit defines replacement of the scalar's text within the original content
+type scalarEdit struct {
+	start int
+	end   int
+	text  string
 }
 
-func (y *YamlEdit) updatedChildValue(child dataBucketType, remainingPaths []string, value dataBucketType) dataBucketType {
-	if len(remainingPaths) == 0 {
-		return value
+// ModifyProperty sets value of the property by path (e.g. `modules[0].version`) in every document of the file
+// existing values are replaced right in the original text, so that comments, blank lines, anchors, key order and
+// quoting style are preserved; the file is re-encoded only when new properties have to be added
+func (y *YamlEdit) ModifyProperty(filePath string, yamlPath string, value string) error {
+	content, err := y.readContent(filePath)
+	if err != nil {
+		return err
 	}
-
-	_, nextIndexErr := strconv.ParseInt(remainingPaths[0], 10, 64)
-	if nextIndexErr != nil && remainingPaths[0] != "+" {
-		// must be a map
-		return y.writeMap(child, remainingPaths, value)
+	docs, err := y.parseDocuments(content)
+	if err != nil {
+		return err
+	}
+	paths := y.parsePath(yamlPath)
+
+	var edits []scalarEdit
+	reencode := false
+	for _, doc := range docs {
+		node := y.lookupNode(doc, paths)
+		if node != nil && (node.Kind == yaml.ScalarNode || node.Kind == yaml.AliasNode) {
+			if edit, ok := y.scalarEditFor(content, node, value); ok {
+				edits = append(edits, edit)
+				y.setScalar(node, value)
+				continue
+			}
+		}
+		if node == nil && y.SkipNotExisting {
+			continue
+		}
+		y.setChildValue(doc, paths, value)
+		reencode = true
 	}
 
-	// must be an array
-	return y.writeArray(child, remainingPaths, value)
+	var res []byte
+	if reencode {
+		if res, err = y.encodeDocuments(docs, y.detectIndent(content)); err != nil {
+			return err
+		}
+	} else {
+		res = y.applyEdits(content, edits)
+	}
+	return y.writeContent(filePath, res)
 }
 
-func (y *YamlEdit) entryInSlice(context yaml.MapSlice, key interface{}) *yaml.MapItem {
-	for idx := range context {
-		entry := &context[idx]
-		if entry.Key == key {
-			return entry
+// ModifyDocuments applies modify function to every document of the file and writes the file if any of the documents
+// has been changed; the file is re-encoded, so comments, anchors and key order are preserved, but formatting may change
+func (y *YamlEdit) ModifyDocuments(filePath string, modify func(doc *yaml.Node) (bool, error)) (bool, error) {
+	content, err := y.readContent(filePath)
+	if err != nil {
+		return false, err
+	}
+	docs, err := y.parseDocuments(content)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, doc := range docs {
+		docChanged, err := modify(doc)
+		if err != nil {
+			return false, err
 		}
+		changed = changed || docChanged
 	}
-	return nil
+	if !changed {
+		return false, nil
+	}
+	res, err := y.encodeDocuments(docs, y.detectIndent(content))
+	if err != nil {
+		return false, err
+	}
+	return true, y.writeContent(filePath, res)
 }
 
-func (y *YamlEdit) writeArray(context interface{}, paths []string, value interface{}) []interface{} {
-	array, _ := y.getArray(context)
+func (y *YamlEdit) parseDocuments(content []byte) ([]*yaml.Node, error) {
+	var res []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for idx := 0; ; idx++ {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "Error reading document at index %v", idx)
+		}
+		res = append(res, &doc)
+	}
+	if len(res) == 0 {
+		res = append(res, &yaml.Node{Kind: yaml.DocumentNode})
+	}
+	return res, nil
+}
 
-	if len(paths) == 0 {
-		return array
+// lookupNode returns node by path (nil if it does not exist)
+// keys merged from anchors (`<<: *anchor`) are not considered, so that the anchor is not modified for all its users
+func (y *YamlEdit) lookupNode(node *yaml.Node, paths []string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	for _, path := range paths {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			child := y.mappingValue(node, path)
+			if child == nil {
+				return nil
+			}
+			node = child
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(path)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[index]
+		default:
+			return nil
+		}
 	}
+	return node
+}
 
-	rawIndex := paths[0]
-	var index int64
-	// the append array indicator
-	if rawIndex == "+" {
-		index = int64(len(array))
-	} else {
-		index, _ = strconv.ParseInt(rawIndex, 10, 64) // nolint
-		// writeArray is only called by updatedChildValue which handles parsing the
-		// index, as such this renders this dead code.
+func (y *YamlEdit) mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i].Tag != "!!merge" {
+			return node.Content[i+1]
+		}
 	}
+	return nil
+}
 
-	for index >= int64(len(array)) {
-		array = append(array, nil)
+// setChildValue sets value by path creating missing mappings, sequences and their items
+func (y *YamlEdit) setChildValue(node *yaml.Node, paths []string, value string) {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+		}
+		node = node.Content[0]
 	}
-	currentChild := array[index]
+	for _, path := range paths {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		_, indexErr := strconv.Atoi(path)
+		if indexErr != nil && path != "+" {
+			// must be a map
+			if node.Kind != yaml.MappingNode {
+				*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			child := y.mappingValue(node, path)
+			if child == nil {
+				child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path}, child)
+			}
+			node = child
+			continue
+		}
+		// must be an array
+		if node.Kind != yaml.SequenceNode {
+			*node = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		index := len(node.Content)
+		if path != "+" {
+			index, _ = strconv.Atoi(path)
+		}
+		for index >= len(node.Content) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+		}
+		node = node.Content[index]
+	}
+	y.setScalar(node, value)
+}
 
-	remainingPaths := paths[1:]
-	array[index] = y.updatedChildValue(currentChild, remainingPaths, value)
-	return array
+func (y *YamlEdit) setScalar(node *yaml.Node, value string) {
+	style := node.Style
+	if node.Kind != yaml.ScalarNode {
+		style = 0
+	}
+	*node = yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Tag:         "!!str",
+		Value:       value,
+		Style:       style &^ (yaml.LiteralStyle | yaml.FoldedStyle),
+		Anchor:      node.Anchor,
+		HeadComment: node.HeadComment,
+		LineComment: node.LineComment,
+		FootComment: node.FootComment,
+	}
 }
 
-func (y *YamlEdit) getMapSlice(context interface{}) yaml.MapSlice {
-	var mapSlice yaml.MapSlice
-	switch context.(type) {
-	case yaml.MapSlice:
-		mapSlice = context.(yaml.MapSlice)
-	default:
-		mapSlice = make(yaml.MapSlice, 0)
+// scalarEditFor calculates replacement of the scalar (or alias) text keeping quoting style of the original value
+// returns false if the text of the scalar can't be located reliably (e.g. multiline scalars)
+func (y *YamlEdit) scalarEditFor(content []byte, node *yaml.Node, value string) (scalarEdit, bool) {
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return scalarEdit{}, false
+	}
+	start, ok := y.offsetOf(content, node.Line, node.Column)
+	if !ok {
+		return scalarEdit{}, false
 	}
-	return mapSlice
+	if node.Kind == yaml.AliasNode {
+		end := start + 1 + len(node.Value)
+		if end > len(content) || string(content[start:end]) != "*"+node.Value {
+			return scalarEdit{}, false
+		}
+		return scalarEdit{start: start, end: end, text: y.formatScalar(value, 0)}, true
+	}
+	// skip anchor and tag preceding the value
+	for start < len(content) && (content[start] == '&' || content[start] == '!') {
+		for start < len(content) && !isSpace(content[start]) {
+			start++
+		}
+		for start < len(content) && (content[start] == ' ' || content[start] == '\t') {
+			start++
+		}
+	}
+	end, ok := y.scalarEnd(content, start, node.Style)
+	if !ok {
+		return scalarEdit{}, false
+	}
+	// make sure the located text is exactly the current value
+	var current string
+	if err := yaml.Unmarshal(content[start:end], &current); err != nil || current != node.Value {
+		return scalarEdit{}, false
+	}
+	return scalarEdit{start: start, end: end, text: y.formatScalar(value, node.Style)}, true
 }
 
-func (y *YamlEdit) getArray(context interface{}) (array []interface{}, ok bool) {
-	switch context.(type) {
-	case []interface{}:
-		array = context.([]interface{})
-		ok = true
+func (y *YamlEdit) scalarEnd(content []byte, start int, style yaml.Style) (int, bool) {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(content); i++ {
+			if content[i] == '\\' {
+				i++
+			} else if content[i] == '"' {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(content); i++ {
+			if content[i] == '\'' {
+				if i+1 < len(content) && content[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, true
+			}
+		}
+		return 0, false
 	default:
-		array = make([]interface{}, 0)
-		ok = false
+		end := start
+		for end < len(content) && content[end] != '\n' && content[end] != '\r' {
+			if content[end] == '#' && end > start && isSpace(content[end-1]) {
+				break
+			}
+			end++
+		}
+		for end > start && isSpace(content[end-1]) {
+			end--
+		}
+		return end, end > start
 	}
-	return
 }
 
-func (y *YamlEdit) writeMap(context dataBucketType, paths []string, value dataBucketType) yaml.MapSlice {
-	mapSlice := y.getMapSlice(context)
-
-	if len(paths) == 0 {
-		return mapSlice
+// formatScalar formats value keeping the quoting style (plain values are quoted only when necessary)
+func (y *YamlEdit) formatScalar(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.SingleQuotedStyle != 0 && !strings.ContainsAny(value, "\n\r"):
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
 	}
+	if encoded, err := yaml.Marshal(value); err == nil && string(encoded) == value+"\n" {
+		return value
+	}
+	return strconv.Quote(value)
+}
 
-	child := y.entryInSlice(mapSlice, paths[0])
-	newField := false
-	if child == nil {
-		newField = true
-		if !y.SkipNotExisting {
-			newChild := yaml.MapItem{Key: paths[0]}
-			mapSlice = append(mapSlice, newChild)
-			child = y.entryInSlice(mapSlice, paths[0])
+// offsetOf returns byte offset of the position defined by line and column (both 1-based, column in characters)
+func (y *YamlEdit) offsetOf(content []byte, line int, column int) (int, bool) {
+	offset := 0
+	for curLine := 1; curLine < line; curLine++ {
+		idx := bytes.IndexByte(content[offset:], '\n')
+		if idx < 0 {
+			return 0, false
 		}
+		offset += idx + 1
 	}
-
-	if !y.SkipNotExisting || !newField {
-		remainingPaths := paths[1:]
-		child.Value = y.updatedChildValue(child.Value, remainingPaths, value)
+	for col := 1; col < column; col++ {
+		if offset >= len(content) || content[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
 	}
-	return mapSlice
+	return offset, true
 }
 
-func (y *YamlEdit) safelyRenameFile(from string, to string) {
-	if renameError := os.Rename(from, to); renameError != nil {
-		// can't do this rename when running in docker to a file targeted in a mounted volume,
-		// so gracefully degrade to copying the entire contents.
-		if copyError := y.copyFileContents(from, to); copyError != nil {
-			panic(copyError)
-		}
+func (y *YamlEdit) applyEdits(content []byte, edits []scalarEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	res := append([]byte{}, content...)
+	for _, edit := range edits {
+		res = append(res[:edit.start], append([]byte(edit.text), res[edit.end:]...)...)
 	}
+	return res
 }
 
-func (y *YamlEdit) readAndUpdate(stdOut io.Writer, inputFile string, updateData updateDataFn) error {
-	var destination io.Writer
-	var destinationName string
-	if y.WriteInPlace {
-		info, err := os.Stat(inputFile)
-		if err != nil {
-			return err
+func (y *YamlEdit) encodeDocuments(docs []*yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	for idx, doc := range docs {
+		y.clearMergeTags(doc)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, errors.Wrapf(err, "Error writing document at index %v", idx)
 		}
-		tempFile, err := ioutil.TempFile("", "temp")
-		if err != nil {
-			return err
-		}
-		destinationName = tempFile.Name()
-		err = os.Chmod(destinationName, info.Mode())
-		if err != nil {
-			return err
-		}
-		destination = tempFile
-		defer func() {
-			y.safelyCloseFile(tempFile)
-			y.safelyRenameFile(tempFile.Name(), inputFile)
-		}()
-	} else {
-		writer := bufio.NewWriter(stdOut)
-		destination = writer
-		destinationName = "Stdout"
-		defer y.safelyFlush(writer)
 	}
-	encoder := yaml.NewEncoder(destination)
-	return y.readStream(inputFile, y.mapYamlDecoder(updateData, encoder))
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-func (y *YamlEdit) safelyFlush(writer *bufio.Writer) {
-	if err := writer.Flush(); err != nil {
-		panic(err)
+// clearMergeTags resets explicit tags of merge keys, so that they are written as `<<` rather than `!!merge <<`
+func (y *YamlEdit) clearMergeTags(node *yaml.Node) {
+	if node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		y.clearMergeTags(child)
 	}
 }
 
-func (y *YamlEdit) safelyCloseFile(file *os.File) {
-	err := file.Close()
-	if err != nil {
-		panic(err)
+// detectIndent returns indentation used by the content (2 spaces by default)
+func (y *YamlEdit) detectIndent(content []byte) int {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return indent
+		}
 	}
+	return 2
 }
 
-type yamlDecoderFn func(*yaml.Decoder) error
-
-func (y *YamlEdit) readStream(filename string, yamlDecoder yamlDecoderFn) error {
+func (y *YamlEdit) readContent(filename string) ([]byte, error) {
 	if filename == "" {
-		return errors.New("Must provide filename")
+		return nil, errors.New("Must provide filename")
 	}
-
-	var stream io.Reader
 	if filename == "-" {
-		stream = bufio.NewReader(os.Stdin)
-	} else {
-		file, err := os.Open(filename) // nolint gosec
+		return io.ReadAll(bufio.NewReader(os.Stdin))
+	}
+	return os.ReadFile(filename)
+}
+
+func (y *YamlEdit) writeContent(filename string, content []byte) error {
+	if !y.WriteInPlace {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		tempFile, err = os.CreateTemp("", "temp")
 		if err != nil {
 			return err
 		}
-		defer y.safelyCloseFile(file)
-		stream = file
 	}
-	return yamlDecoder(yaml.NewDecoder(stream))
+	if err := os.Chmod(tempFile.Name(), info.Mode()); err != nil {
+		return err
+	}
+	if _, err := tempFile.Write(content); err != nil {
+		y.safelyCloseFile(tempFile)
+		return err
+	}
+	y.safelyCloseFile(tempFile)
+	y.safelyRenameFile(tempFile.Name(), filename)
+	return nil
 }
 
-func (y *YamlEdit) readData(filename string, indexToRead int, parsedData interface{}) error {
-	return y.readStream(filename, func(decoder *yaml.Decoder) error {
-		for currentIndex := 0; currentIndex < indexToRead; currentIndex++ {
-			errorSkipping := decoder.Decode(parsedData)
-			if errorSkipping != nil {
-				return errors.Wrapf(errorSkipping, "Error processing document at index %v, %v", currentIndex, errorSkipping)
-			}
+func (y *YamlEdit) safelyRenameFile(from string, to string) {
+	if renameError := os.Rename(from, to); renameError != nil {
+		// can't do this rename when running in docker to a file targeted in a mounted volume,
+		// so gracefully degrade to copying the entire contents.
+		if copyError := y.copyFileContents(from, to); copyError != nil {
+			panic(copyError)
 		}
-		return decoder.Decode(parsedData)
-	})
+		_ = os.Remove(from)
+	}
+}
+
+func (y *YamlEdit) safelyCloseFile(file *os.File) {
+	err := file.Close()
+	if err != nil {
+		panic(err)
+	}
 }
 
 func (y *YamlEdit) parsePath(path string) []string {
@@ -267,42 +487,6 @@ func (y *YamlEdit) contains(matchingChars []uint8, candidate uint8) bool {
 	return false
 }
 
-type (
-	dataBucketType interface{}
-	updateDataFn   func(dataBucket dataBucketType, currentIndex int) (dataBucketType, error)
-)
-
-func (y *YamlEdit) mapYamlDecoder(updateData updateDataFn, encoder *yaml.Encoder) yamlDecoderFn {
-	return func(decoder *yaml.Decoder) error {
-		var dataBucket dataBucketType
-		var errorReading error
-		var errorWriting error
-		var errorUpdating error
-		currentIndex := 0
-
-		for {
-			errorReading = decoder.Decode(&dataBucket)
-
-			if errorReading == io.EOF {
-				return nil
-			} else if errorReading != nil {
-				return errors.Wrapf(errorReading, "Error reading document at index %v, %v", currentIndex, errorReading)
-			}
-			dataBucket, errorUpdating = updateData(dataBucket, currentIndex)
-			if errorUpdating != nil {
-				return errors.Wrapf(errorUpdating, "Error updating document at index %v", currentIndex)
-			}
-
-			errorWriting = encoder.Encode(dataBucket)
-
-			if errorWriting != nil {
-				return errors.Wrapf(errorWriting, "Error writing document at index %v, %v", currentIndex, errorWriting)
-			}
-			currentIndex = currentIndex + 1
-		}
-	}
-}
-
 func (y *YamlEdit) copyFileContents(src, dst string) (err error) {
 	in, err := os.Open(src) // nolint gosec
 	if err != nil {
@@ -319,3 +503,7 @@ func (y *YamlEdit) copyFileContents(src, dst string) (err error) {
 	}
 	return out.Sync()
 }
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
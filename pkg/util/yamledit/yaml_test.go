@@ -0,0 +1,82 @@
+package yamledit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const commentedYaml = `# project definition
+schemaVersion: 1.8.0
+version: "1.2.3" # released version
+
+default:
+  build:
+    image: &build-image golang:1.22
+modules:
+  # the main module
+  - name: api
+    version: 0.1.0 # api version
+  - name: web
+    version: '0.2.0'
+    image: *build-image
+profiles:
+  alpha:
+    build: &alpha-build
+      args:
+        suffix: "-alpha"
+  local:
+    build:
+      <<: *alpha-build
+`
+
+func modifyProperty(t *testing.T, edit YamlEdit, content string, path string, value string) string {
+	file := filepath.Join(t.TempDir(), "welder.yaml")
+	Expect(os.WriteFile(file, []byte(content), 0o644)).To(BeNil())
+	Expect(edit.ModifyProperty(file, path, value)).To(BeNil())
+	res, err := os.ReadFile(file)
+	Expect(err).To(BeNil())
+	return string(res)
+}
+
+func TestModifyPropertyPreservesFormatting(t *testing.T) {
+	RegisterTestingT(t)
+	edit := YamlEdit{WriteInPlace: true}
+
+	Expect(modifyProperty(t, edit, commentedYaml, "version", "1.2.4")).
+		To(Equal(replaceOnce(commentedYaml, `version: "1.2.3" # released`, `version: "1.2.4" # released`)))
+	Expect(modifyProperty(t, edit, commentedYaml, "modules[0].version", "0.1.1")).
+		To(Equal(replaceOnce(commentedYaml, `version: 0.1.0 # api`, `version: 0.1.1 # api`)))
+	Expect(modifyProperty(t, edit, commentedYaml, "modules[1].version", "0.3.0")).
+		To(Equal(replaceOnce(commentedYaml, `version: '0.2.0'`, `version: '0.3.0'`)))
+	Expect(modifyProperty(t, edit, commentedYaml, "default.build.image", "golang:1.23")).
+		To(Equal(replaceOnce(commentedYaml, `&build-image golang:1.22`, `&build-image golang:1.23`)))
+	Expect(modifyProperty(t, edit, commentedYaml, "modules[1].image", "node:20")).
+		To(Equal(replaceOnce(commentedYaml, `image: *build-image`, `image: node:20`)))
+	// plain values that would not be read as strings are quoted
+	Expect(modifyProperty(t, edit, commentedYaml, "modules[0].version", "1.0")).
+		To(Equal(replaceOnce(commentedYaml, `version: 0.1.0 # api`, `version: "1.0" # api`)))
+}
+
+func TestModifyPropertyAddsMissingProperty(t *testing.T) {
+	RegisterTestingT(t)
+
+	res := modifyProperty(t, YamlEdit{WriteInPlace: true}, commentedYaml, "profiles.local.build.args.suffix", "-local")
+	Expect(res).To(ContainSubstring("# the main module\n"))
+	Expect(res).To(ContainSubstring("version: 0.1.0 # api version\n"))
+	Expect(res).To(ContainSubstring("build: &alpha-build\n"))
+	Expect(res).To(ContainSubstring("    build:\n      <<: *alpha-build\n      args:\n        suffix: -local\n"))
+	// anchored definition is left intact
+	Expect(res).To(ContainSubstring("        suffix: \"-alpha\"\n"))
+
+	Expect(modifyProperty(t, YamlEdit{WriteInPlace: true, SkipNotExisting: true}, commentedYaml, "profiles.local.version", "1.0.0")).
+		To(Equal(commentedYaml))
+}
+
+func replaceOnce(s string, old string, new string) string {
+	Expect(s).To(ContainSubstring(old))
+	return strings.Replace(s, old, new, 1)
+}
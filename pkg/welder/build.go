@@ -15,6 +15,9 @@ import (
 
 // Deploy runs deploy steps of the project defined by the build context
 func (deployCtx *DeployContext) Deploy() error {
+	if err := deployCtx.checkImagesAvailableOffline(imagesScope{steps: true, deployCtx: deployCtx}); err != nil {
+		return err
+	}
 	return deployCtx.forEachModule("deploying project", func(root *types.RootBuildDefinition, modCtx *BuildContext, module string) error {
 		return modCtx.RunSteps("deploy", root, module, deployCtx)
 	})
@@ -22,6 +25,9 @@ func (deployCtx *DeployContext) Deploy() error {
 
 // Build builds project defined by the build context
 func (buildCtx *BuildContext) Build() error {
+	if err := buildCtx.checkImagesAvailableOffline(imagesScope{steps: true}); err != nil {
+		return err
+	}
 	return buildCtx.forEachModule("building project", func(root *types.RootBuildDefinition, modCtx *BuildContext, module string) error {
 		return modCtx.RunSteps("build", root, module, nil)
 	})
@@ -40,7 +46,10 @@ func (buildCtx *BuildContext) forEachModule(runDesc string, callback forModuleCa
 	if detectedModule != nil {
 		detectedModuleName = detectedModule.Name
 	}
-	activeProfiles := buildCtx.ActiveProfiles(&root, detectedModuleName)
+	activeProfiles, err := buildCtx.ResolveActiveProfiles(&root, detectedModuleName)
+	if err != nil {
+		return err
+	}
 	buildCtx.Logger().Logf(" - Starting %s...", runDesc)
 	buildCtx.Logger().Logf(" - Build tool version: %q", buildCtx.Version())
 	buildCtx.Logger().Logf(" - Active modules: ['%s']", strings.Join(activeModules, "', '"))
@@ -48,6 +57,9 @@ func (buildCtx *BuildContext) forEachModule(runDesc string, callback forModuleCa
 	if buildCtx.Parallel {
 		buildCtx.Logger().Logf(" - Running in parallel with max: %d", buildCtx.ParallelCount)
 	}
+	if buildCtx.Offline {
+		buildCtx.Logger().Logf(" - Running in offline mode (images are never pulled)")
+	}
 	for _, m := range activeModules {
 		module := m
 		buildCtx := NewBuildContext(buildCtx, buildCtx.Logger().SubLogger(module))
@@ -129,6 +141,16 @@ func NewBuildContext(ctx *BuildContext, logger util.Logger) *BuildContext {
 	return &res
 }
 
+// newDetachedBuildContext creates new build context that doesn't share Go context with the provided one
+func newDetachedBuildContext(ctx *BuildContext, logger util.Logger, modules []string) *BuildContext {
+	res := BuildContext{CommonCtx: types.NewDetachedCommonContext(ctx.CommonCtx, logger)}
+	res.Modules = modules
+	copy(res.Profiles, ctx.Profiles)
+	res.InitGitClientIfNeeded()
+	res.CancelOnSignal()
+	return &res
+}
+
 // ToBuildCtx creates build context out of Micros context
 func (m *MicrosCtx) ToBuildCtx() *BuildContext {
 	return NewBuildContext(&BuildContext{CommonCtx: types.NewCommonContext(&types.CommonCtx{
@@ -5,146 +5,105 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/kballard/go-shellquote"
 	"github.com/pkg/errors"
 
 	"github.com/simple-container-com/welder/pkg/docker"
-	"github.com/simple-container-com/welder/pkg/exec"
 	"github.com/simple-container-com/welder/pkg/util"
 	"github.com/simple-container-com/welder/pkg/welder/runner"
 	. "github.com/simple-container-com/welder/pkg/welder/types"
 )
 
-type KanikoOpts struct {
-	ExecutorPath string
-	CachePath    string
-	ExtraArgs    string
-	DockerImages []string
-}
-
 type dockerBuildParams struct {
 	dockerImage DockerImageDefinition
 	subject     string
 	allowReuse  bool
-	kanikoOpts  *KanikoOpts
+	builderOpts *ImageBuilderOpts
 	allowLabels bool
 }
 
-// KanikoBuild builds docker images using Kaniko executor
-func (buildCtx *BuildContext) KanikoBuild(opts KanikoOpts) error {
-	return buildCtx.forEachModule("building Docker images", func(root *RootBuildDefinition, modCtx *BuildContext, module string) error {
-		buildCtx.Logger().Logf(" - Building Docker images for module '%s'...", module)
-		dockerDefs, err := modCtx.ActualDockerImagesDefinitionFor(root, module)
-		if err != nil {
-			return errors.Wrapf(err, "failed to calc effective Docker images definition for module %s", module)
-		}
-		for _, dockerDef := range dockerDefs {
-			if len(opts.DockerImages) > 0 && !util.SliceContains(opts.DockerImages, dockerDef.Name) {
-				continue
-			}
-			subCtx := NewBuildContext(modCtx, modCtx.Logger().SubLogger(dockerDef.Name))
-			if _, err := subCtx.buildDockerImage(root, module, dockerBuildParams{
-				dockerImage: dockerDef,
-				subject:     dockerDef.Name,
-				allowReuse:  false,
-				kanikoOpts:  &opts,
-			}); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-}
+type forDockerImageCallback func(root *RootBuildDefinition, subCtx *BuildContext, module string, dockerDef DockerImageDefinition) (*OutDockerImageDefinition, error)
 
 // BuildDocker builds docker images defined by the build context
 func (buildCtx *BuildContext) BuildDocker(dockerImages []string) error {
-	return buildCtx.forEachModule("building Docker images", func(root *RootBuildDefinition, modCtx *BuildContext, module string) error {
-		buildCtx.Logger().Logf(" - Building Docker images for module '%s'...", module)
-		dockerDefs, err := modCtx.ActualDockerImagesDefinitionFor(root, module)
-		if err != nil {
-			return errors.Wrapf(err, "failed to calc effective Docker images definition for module %s", module)
+	return buildCtx.BuildDockerWithBuilder(dockerImages, ImageBuilderOpts{})
+}
+
+// BuildDockerWithBuilder builds docker images defined by the build context using the configured builder backend
+// and pushes them if requested
+func (buildCtx *BuildContext) BuildDockerWithBuilder(dockerImages []string, opts ImageBuilderOpts) error {
+	if err := buildCtx.checkImagesAvailableOffline(imagesScope{dockerImages: true, dockerImageNames: dockerImages}); err != nil {
+		return err
+	}
+	return buildCtx.forEachDockerImage("building", dockerImages, opts.Push, func(root *RootBuildDefinition, subCtx *BuildContext, module string, dockerDef DockerImageDefinition) (*OutDockerImageDefinition, error) {
+		buildParams := dockerBuildParams{
+			dockerImage: dockerDef,
+			subject:     dockerDef.Name,
+			allowReuse:  false,
+			builderOpts: &opts,
 		}
-		for _, dockerDef := range dockerDefs {
-			if len(dockerImages) > 0 && !util.SliceContains(dockerImages, dockerDef.Name) {
-				continue
-			}
-			subCtx := NewBuildContext(modCtx, modCtx.Logger().SubLogger(dockerDef.Name))
-			if _, err := subCtx.buildDockerImage(root, module, dockerBuildParams{
-				dockerImage: dockerDef,
-				subject:     dockerDef.Name,
-				allowReuse:  false,
-			}); err != nil {
-				return err
-			}
+		tags, err := subCtx.buildDockerImage(root, module, buildParams)
+		if err != nil || !opts.Push {
+			return nil, err
 		}
-		return nil
+		pushed, err := subCtx.pushDockerImage(root, module, buildParams, tags)
+		return &pushed, err
 	})
 }
 
 // PushDocker pushes built images to Docker registries
 func (buildCtx *BuildContext) PushDocker(dockerImages []string) error {
-	if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
-		return errors.Wrapf(err, "failed to create output dir")
+	return buildCtx.forEachDockerImage("pushing", dockerImages, true, func(root *RootBuildDefinition, subCtx *BuildContext, module string, dockerDef DockerImageDefinition) (*OutDockerImageDefinition, error) {
+		pushed, err := subCtx.pushDockerImage(root, module, dockerBuildParams{
+			dockerImage: dockerDef,
+			subject:     dockerDef.Name,
+		}, dockerDef.Tags)
+		return &pushed, err
+	})
+}
+
+// forEachDockerImage invokes callback for each selected Docker image of active modules
+// and writes output file with pushed images if requested
+func (buildCtx *BuildContext) forEachDockerImage(action string, dockerImages []string, writeOutput bool, callback forDockerImageCallback) error {
+	if writeOutput {
+		if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
+			return errors.Wrapf(err, "failed to create output dir")
+		}
 	}
+	var outMutex sync.Mutex
 	outDockerDef := OutDockerDefinition{SchemaVersion: OutDockerSchemaVersion}
-	err := buildCtx.forEachModule("pushing Docker images", func(root *RootBuildDefinition, modCtx *BuildContext, module string) error {
+	err := buildCtx.forEachModule(action+" Docker images", func(root *RootBuildDefinition, modCtx *BuildContext, module string) error {
 		outDockerModuleDef := OutDockerModuleDefinition{Name: module}
-		buildCtx.Logger().Logf(" - Pushing Docker images for module '%s'...", module)
+		buildCtx.Logger().Logf(" - %s Docker images for module '%s'...", strings.Title(action), module)
 		dockerDefs, err := modCtx.ActualDockerImagesDefinitionFor(root, module)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "failed to calc effective Docker images definition for module %s", module)
 		}
-		for _, d := range dockerDefs {
-			if len(dockerImages) > 0 && !util.SliceContains(dockerImages, d.Name) {
+		for _, dockerDef := range dockerDefs {
+			if len(dockerImages) > 0 && !util.SliceContains(dockerImages, dockerDef.Name) {
 				continue
 			}
-			dockerDef := d
 			subCtx := NewBuildContext(modCtx, modCtx.Logger().SubLogger(dockerDef.Name))
-			subCtx.Logger().Logf(" - Pushing Docker image '%s'...", dockerDef.Name)
-
-			pushedDockerImage := OutDockerImageDefinition{
-				Name:    dockerDef.Name,
-				Digests: make([]OutDockerDigestDefinition, 0),
-			}
-
-			for _, tag := range dockerDef.Tags {
-				dockerfile, err := docker.NewDockerfile(buildCtx.GoContext(), root.PathTo(buildCtx.RootDir(), dockerDef.DockerFile), tag)
-				if err != nil {
-					return err
-				}
-				dockerfile.Context = buildCtx.GoContext()
-				dockerfile.ContextPath = dockerDef.Build.ContextPath
-				reader, err := dockerfile.Push()
-				if err != nil {
-					return err
-				}
-				if err := reader.Listen(false, docker.MessageToLogFunc(subCtx.Logger(), module)); err != nil {
-					return err
-				}
-				for repoTag, digest := range dockerfile.TagDigests {
-					image, err := docker.ImageFromReference(repoTag)
-					if err != nil {
-						return errors.Wrapf(err, "failed to determine image name from tag: %s", repoTag)
-					}
-					pushedDockerImage.Digests = append(pushedDockerImage.Digests, OutDockerDigestDefinition{
-						Tag:    digest.Tag,
-						Digest: digest.Digest,
-						Image:  image,
-					})
-				}
+			pushed, err := callback(root, subCtx, module, dockerDef)
+			if err != nil {
+				return err
 			}
-			if err := modCtx.runAfterPushScripts(root, module, dockerDef, pushedDockerImage); err != nil {
-				return errors.Wrapf(err, "failed to run after push scripts")
+			if pushed != nil {
+				outDockerModuleDef.DockerImages = append(outDockerModuleDef.DockerImages, *pushed)
 			}
-			outDockerModuleDef.DockerImages = append(outDockerModuleDef.DockerImages, pushedDockerImage)
 		}
+		outMutex.Lock()
+		defer outMutex.Unlock()
 		outDockerDef.Modules = append(outDockerDef.Modules, outDockerModuleDef)
 		return nil
 	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to Docker push")
+		return errors.Wrapf(err, "failed to Docker %s", strings.TrimSuffix(action, "ing"))
+	}
+	if !writeOutput {
+		return nil
 	}
 	buildCtx.Logger().Logf(" - Writing output file...")
 	err = outDockerDef.WriteToOutputDir(buildCtx.RootDir())
@@ -154,6 +113,28 @@ func (buildCtx *BuildContext) PushDocker(dockerImages []string) error {
 	return nil
 }
 
+// pushDockerImage pushes image built by the builder backend and runs after push scripts
+func (buildCtx *BuildContext) pushDockerImage(root *RootBuildDefinition, module string, buildParams dockerBuildParams, tags []string) (OutDockerImageDefinition, error) {
+	builder, err := buildCtx.imageBuilderFor(buildParams)
+	if err != nil {
+		return OutDockerImageDefinition{}, err
+	}
+	buildCtx.Logger().Logf(" - Pushing Docker image '%s'...", buildParams.dockerImage.Name)
+	pushedDockerImage, err := builder.Push(imageBuildRequest{
+		root:        root,
+		module:      module,
+		tags:        tags,
+		buildParams: buildParams,
+	})
+	if err != nil {
+		return pushedDockerImage, errors.Wrapf(err, "failed to push Docker image %q using %s", buildParams.dockerImage.Name, builder.Name())
+	}
+	if err := buildCtx.runAfterPushScripts(root, module, buildParams.dockerImage, pushedDockerImage); err != nil {
+		return pushedDockerImage, errors.Wrapf(err, "failed to run after push scripts")
+	}
+	return pushedDockerImage, nil
+}
+
 func (buildCtx *BuildContext) buildDockerImage(root *RootBuildDefinition, moduleName string, buildParams dockerBuildParams) ([]string, error) {
 	dockerFilePath := buildParams.dockerImage.DockerFile
 	if dockerFilePath != "" && !path.IsAbs(dockerFilePath) {
@@ -182,14 +163,24 @@ func (buildCtx *BuildContext) buildDockerImage(root *RootBuildDefinition, module
 			return tags, errors.Wrapf(err, "failed to write Dockerfile to path %s", dockerFilePath)
 		}
 	}
-	buildCtx.Logger().Logf(" - Building Docker image '%s' from file '%s'...", buildParams.dockerImage.Name, dockerFilePath)
+	builder, err := buildCtx.imageBuilderFor(buildParams)
+	if err != nil {
+		return tags, err
+	}
+	buildCtx.Logger().Logf(" - Building Docker image '%s' from file '%s' using %s...", buildParams.dockerImage.Name, dockerFilePath, builder.Name())
 
-	if buildParams.kanikoOpts != nil {
-		if err := buildCtx.buildDockerImageWithKaniko(root, moduleName, dockerFilePath, tags, buildParams); err != nil {
-			return tags, errors.Wrapf(err, "failed to build Docker image using Kaniko")
-		}
-	} else if err := buildCtx.buildDockerImageWithDocker(root, moduleName, dockerFilePath, tags, buildParams); err != nil {
-		return tags, errors.Wrapf(err, "failed to build Docker image")
+	if err := builder.Build(imageBuildRequest{
+		root:           root,
+		module:         moduleName,
+		dockerFilePath: dockerFilePath,
+		tags:           tags,
+		buildParams:    buildParams,
+	}); err != nil {
+		return tags, errors.Wrapf(err, "failed to build Docker image using %s", builder.Name())
+	}
+
+	if err := buildCtx.runAfterBuildScripts(root, moduleName, buildParams, tags); err != nil {
+		return tags, errors.Wrapf(err, "failed to invoke scripts after build")
 	}
 
 	return tags, nil
@@ -200,7 +191,7 @@ func (buildCtx *BuildContext) runAfterPushScripts(root *RootBuildDefinition, mod
 	pushSubCtx.SetCurrentDockerImage(&pushedDockerImage)
 	runId := fmt.Sprintf("after-push-%s", dockerDef.Name)
 	tasks := dockerDef.RunAfterPush.Tasks
-	if len(dockerDef.RunAfterPush.Scripts) > 0 {
+	if dockerDef.RunAfterPush.HasScripts() {
 		buildCtx.Logger().Logf(" - Running after Docker push step...")
 		if err := pushSubCtx.RunScriptsOfSimpleStep(runId, dockerDef.RunAfterPush, root, module); err != nil {
 			return errors.Wrapf(err, "failed to run after push script for %q", dockerDef.Name)
@@ -213,14 +204,14 @@ func (buildCtx *BuildContext) runAfterPushScripts(root *RootBuildDefinition, mod
 
 func (buildCtx *BuildContext) runAfterTasks(runId string, root *RootBuildDefinition, module string, tasks []string) error {
 	for _, task := range tasks {
-		action, err := buildCtx.ActualTaskDefinitionFor(root, task, module, nil)
+		taskDef, err := buildCtx.ActualTaskDefinitionFor(root, task, module, nil)
 		if err != nil {
 			return errors.Wrapf(err, "failed to calcualate task definition for task %s of module %s", task, module)
 		}
 		runId = fmt.Sprintf("%s-%s", runId, task)
-		convRun := action.ToRunSpec(task)
+		convRun := taskDef.ToRunSpec(task)
 		buildCtx.ExecutingTask(task)
-		if err := buildCtx.RunScripts(fmt.Sprintf("%s step %q of module %s", action, task, module), runId, root, module, convRun); err != nil {
+		if err := buildCtx.RunScripts(fmt.Sprintf("task %q of module %s", task, module), runId, root, module, convRun); err != nil {
 			buildCtx.ExecutedTask(task)
 			return err
 		}
@@ -243,7 +234,7 @@ func (buildCtx *BuildContext) runAfterBuildScripts(root *RootBuildDefinition, mo
 	runId := fmt.Sprintf("after-build-%s", buildParams.dockerImage.Name)
 	runAfterBuild := buildParams.dockerImage.RunAfterBuild
 	tasks := runAfterBuild.Tasks
-	if len(runAfterBuild.Scripts) > 0 {
+	if runAfterBuild.HasScripts() {
 		buildCtx.Logger().Logf(" - Running after Docker build step...")
 		if err := afterDockerBuildCtx.RunScriptsOfSimpleStep(
 			runId,
@@ -256,126 +247,19 @@ func (buildCtx *BuildContext) runAfterBuildScripts(root *RootBuildDefinition, mo
 	return nil
 }
 
-func (buildCtx *BuildContext) buildDockerImageWithKaniko(root *RootBuildDefinition, module string, dockerFilePath string, tags []string, buildParams dockerBuildParams) error {
-	if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
-		return errors.Wrapf(err, "failed to create output dir")
-	}
-
-	kanikoExec := exec.NewExec(buildCtx.GoContext(), buildCtx.Logger())
-	args := []string{buildParams.kanikoOpts.ExecutorPath}
-
-	if !buildCtx.NoCache {
-		args = append(args, "--cache")
-	}
-	args = append(args, "--dockerfile", dockerFilePath)
-	contextPath := buildParams.dockerImage.Build.ContextPath
-	if contextPath == "" {
-		contextPath = filepath.Dir(dockerFilePath)
-	}
-
-	args = append(args, "--context", contextPath)
-
-	for _, tag := range tags {
-		args = append(args, "--destination", tag)
-	}
-
-	if argsMap, err := buildParams.dockerImage.Build.ArgsToMap(); err != nil {
-		return errors.Wrapf(err, "failed to convert build args")
-	} else {
-		for key, value := range argsMap {
-			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, *value))
-		}
-	}
-
-	digestFile := path.Join(buildCtx.RootDir(), BuildOutputDir, fmt.Sprintf("%s-%s-digest", module, buildParams.dockerImage.Name))
-	args = append(args, "--digest-file", digestFile)
-
-	extraArgs, err := shellquote.Split(buildParams.kanikoOpts.ExtraArgs)
-	if err != nil {
-		return errors.Wrapf(err, "failed to split extra args %q", buildParams.kanikoOpts.ExtraArgs)
-	}
-	args = append(args, extraArgs...)
-
-	if _, err := kanikoExec.ExecCommandAndLog("kaniko", shellquote.Join(args...), exec.Opts{}); err != nil {
-		return errors.Wrapf(err, "failed to invoke kaniko executor")
-	}
-
-	digest := ""
-	if bytes, err := ioutil.ReadFile(digestFile); err != nil {
-		return errors.Wrapf(err, "failed to read digest file %q", digestFile)
-	} else {
-		digest = string(bytes)
-	}
-
-	if err := buildCtx.runAfterBuildScripts(root, module, buildParams, tags); err != nil {
-		return errors.Wrapf(err, "failed to invoke scripts after push")
-	}
-
-	pushedDockerImage := OutDockerImageDefinition{
-		Name:    buildParams.dockerImage.Name,
-		Digests: make([]OutDockerDigestDefinition, 0),
-	}
-
-	for _, ref := range tags {
-		image, tag, err := docker.ImageAndTagFromFullReference(ref)
-		if err != nil {
-			return err
-		}
-		pushedDockerImage.Digests = append(pushedDockerImage.Digests, OutDockerDigestDefinition{
-			Tag:    tag,
-			Image:  image,
-			Digest: digest,
-		})
-	}
-
-	if err := buildCtx.runAfterPushScripts(root, module, buildParams.dockerImage, pushedDockerImage); err != nil {
-		return errors.Wrapf(err, "failed to invoke scripts after push")
-	}
-
-	// TODO: write output push data
-
-	return nil
-}
-
-func (buildCtx *BuildContext) buildDockerImageWithDocker(root *RootBuildDefinition, module string, dockerFilePath string, tags []string, buildParams dockerBuildParams) error {
-	dockerFile, err := docker.NewDockerfile(buildCtx.GoContext(), dockerFilePath, tags...)
-	if err != nil {
-		return errors.Wrapf(err, "failed to init Dockerfile object")
-	}
-	dockerFile.DisableNoCache = !buildCtx.NoCache
-	dockerFile.SkipHashLabel = !buildParams.allowLabels
-	dockerFile.Context = buildCtx.GoContext()
-	dockerFile.ContextPath = buildParams.dockerImage.Build.ContextPath
-	dockerFile.Args, err = buildParams.dockerImage.Build.ArgsToMap()
-	dockerFile.ReuseImagesWithSameCfg = buildParams.allowReuse
-	if err != nil {
-		return errors.Wrapf(err, "failed to convert docker args to map")
-	}
-	// docker build
-	reader, err := dockerFile.Build()
-	if err != nil {
-		return errors.Wrapf(err, "failed to build docker image")
-	}
-	if err := reader.Listen(false, docker.MessageToLogFunc(buildCtx.Logger(), buildParams.subject)); err != nil {
-		return err
-	}
-
-	if err := buildCtx.runAfterBuildScripts(root, module, buildParams, tags); err != nil {
-		return errors.Wrapf(err, "failed to invoke scripts after push")
-	}
-
-	return nil
-}
-
 func (buildCtx *BuildContext) runInCustomImageContainer(action string, runID string, root *RootBuildDefinition, moduleName string, spec RunSpec) error {
 	if spec.CustomImage.Name == "" {
 		spec.CustomImage.Name = runID
 	}
+	if spec.CustomImage.Pull == "" {
+		spec.CustomImage.Pull = spec.Pull
+	}
 	tags, err := buildCtx.buildDockerImage(root, moduleName, dockerBuildParams{
 		dockerImage: spec.CustomImage,
 		subject:     action,
 		allowReuse:  !buildCtx.NoCache,
 		allowLabels: true,
+		builderOpts: &runImageBuilderOpts,
 	})
 	if err != nil {
 		return err
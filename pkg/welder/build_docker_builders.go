@@ -0,0 +1,461 @@
+package welder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+	"github.com/simple-container-com/welder/pkg/exec"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+// ImageBuilderOpts defines builder backend to use for building Docker images and its options
+type ImageBuilderOpts struct {
+	Builder      ImageBuilderType // builder backend (overrides the one specified in Docker image definition)
+	ExecutorPath string           // path to the builder's executable (default depends on the builder)
+	CachePath    string           // path to the builder's cache directory
+	ExtraArgs    string           // extra arguments to pass to the builder's executable
+	Push         bool             // push images after building
+}
+
+// runImageBuilderOpts defines builder of images that are used to run steps
+// (such images are always built with local Docker daemon since containers are run by it)
+var runImageBuilderOpts = ImageBuilderOpts{Builder: ImageBuilderDocker}
+
+// imageBuilder builds and pushes Docker images using specific backend
+type imageBuilder interface {
+	// Name returns name of the builder backend
+	Name() ImageBuilderType
+	// Build builds Docker image (daemonless backends push image during build if push was requested)
+	Build(req imageBuildRequest) error
+	// Push pushes built image (if not pushed during build) and returns pushed digests
+	Push(req imageBuildRequest) (OutDockerImageDefinition, error)
+}
+
+type imageBuildRequest struct {
+	root           *RootBuildDefinition
+	module         string
+	dockerFilePath string
+	tags           []string
+	buildParams    dockerBuildParams
+}
+
+// imageBuilderFor returns builder backend to use for the Docker image
+// (the one specified in Docker image definition unless it's overridden by options)
+func (buildCtx *BuildContext) imageBuilderFor(buildParams dockerBuildParams) (imageBuilder, error) {
+	opts := ImageBuilderOpts{}
+	if buildParams.builderOpts != nil {
+		opts = *buildParams.builderOpts
+	}
+	if opts.Builder == "" {
+		opts.Builder = buildParams.dockerImage.Builder
+	}
+	switch opts.Builder {
+	case "", ImageBuilderDocker:
+		return &dockerImageBuilder{buildCtx: buildCtx}, nil
+	case ImageBuilderKaniko:
+		return &kanikoImageBuilder{buildCtx: buildCtx, opts: opts.withDefaultExecutor("/kaniko/executor")}, nil
+	case ImageBuilderBuildKit:
+		return &buildKitImageBuilder{buildCtx: buildCtx, opts: opts.withDefaultExecutor("buildctl")}, nil
+	case ImageBuilderBuildah:
+		return &buildahImageBuilder{buildCtx: buildCtx, opts: opts.withDefaultExecutor("buildah")}, nil
+	}
+	return nil, errors.Errorf("unknown image builder %q for Docker image %q", opts.Builder, buildParams.dockerImage.Name)
+}
+
+func (opts ImageBuilderOpts) withDefaultExecutor(executorPath string) ImageBuilderOpts {
+	if opts.ExecutorPath == "" {
+		opts.ExecutorPath = executorPath
+	}
+	return opts
+}
+
+// contextPath returns Docker build context path (directory of Dockerfile by default)
+func (req imageBuildRequest) contextPath() string {
+	if req.buildParams.dockerImage.Build.ContextPath != "" {
+		return req.buildParams.dockerImage.Build.ContextPath
+	}
+	return filepath.Dir(req.dockerFilePath)
+}
+
+// outputFilePath returns path to the builder's output file for the Docker image
+func (req imageBuildRequest) outputFilePath(buildCtx *BuildContext, suffix string) string {
+	return path.Join(buildCtx.RootDir(), BuildOutputDir, fmt.Sprintf("%s-%s-%s", req.module, req.buildParams.dockerImage.Name, suffix))
+}
+
+// pushedImageWithDigest returns pushed image definition with the same digest for all tags
+func (req imageBuildRequest) pushedImageWithDigest(digest string) (OutDockerImageDefinition, error) {
+	pushedDockerImage := OutDockerImageDefinition{
+		Name:    req.buildParams.dockerImage.Name,
+		Digests: make([]OutDockerDigestDefinition, 0),
+	}
+	for _, ref := range req.tags {
+		image, tag, err := docker.ImageAndTagFromFullReference(ref)
+		if err != nil {
+			return pushedDockerImage, err
+		}
+		pushedDockerImage.Digests = append(pushedDockerImage.Digests, OutDockerDigestDefinition{
+			Tag:    tag,
+			Image:  image,
+			Digest: digest,
+		})
+	}
+	return pushedDockerImage, nil
+}
+
+// rewrittenDockerfilePath returns path to the Dockerfile with base images rewritten according to registries config
+// (daemonless builders pull base images themselves), the original path is returned if nothing is rewritten;
+// cleanup function removes the rewritten Dockerfile
+func (req imageBuildRequest) rewrittenDockerfilePath(buildCtx *BuildContext) (string, func(), error) {
+	noCleanup := func() {}
+	content, err := os.ReadFile(req.dockerFilePath)
+	if err != nil {
+		return "", noCleanup, errors.Wrapf(err, "failed to read Dockerfile from %s", req.dockerFilePath)
+	}
+	rewritten, changed := buildCtx.Registries().RewriteDockerfile(string(content))
+	if !changed {
+		return req.dockerFilePath, noCleanup, nil
+	}
+	tmpDir, err := os.MkdirTemp("", "dockerfile")
+	if err != nil {
+		return "", noCleanup, errors.Wrapf(err, "failed to create temp directory")
+	}
+	cleanup := func() { _ = os.RemoveAll(tmpDir) }
+	rewrittenPath := path.Join(tmpDir, filepath.Base(req.dockerFilePath))
+	if err := os.WriteFile(rewrittenPath, []byte(rewritten), 0o644); err != nil {
+		cleanup()
+		return "", noCleanup, errors.Wrapf(err, "failed to write rewritten Dockerfile to %s", rewrittenPath)
+	}
+	return rewrittenPath, cleanup, nil
+}
+
+// removeOutputFile removes builder's output file left by the previous build (so that it's never read as a result of
+// the current one)
+func (req imageBuildRequest) removeOutputFile(buildCtx *BuildContext, suffix string) error {
+	if err := os.Remove(req.outputFilePath(buildCtx, suffix)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove output file of the previous build")
+	}
+	return nil
+}
+
+// pushedDuringBuildOnly returns error for builders that don't keep built images locally and push them during build
+func pushedDuringBuildOnly(builder ImageBuilderType) error {
+	return errors.Errorf("%s pushes images only during the build, use `welder docker build --builder %s --push` instead",
+		builder, builder)
+}
+
+func execBuilderCommand(buildCtx *BuildContext, subject string, args []string, extraArgs string) error {
+	splitExtraArgs, err := shellquote.Split(extraArgs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to split extra args %q", extraArgs)
+	}
+	args = append(args, splitExtraArgs...)
+	builderExec := exec.NewExec(buildCtx.GoContext(), buildCtx.Logger())
+	if _, err := builderExec.ExecCommandAndLog(subject, shellquote.Join(args...), exec.Opts{}); err != nil {
+		return errors.Wrapf(err, "failed to invoke %s", subject)
+	}
+	return nil
+}
+
+func readDigestFile(digestFile string) (string, error) {
+	bytes, err := os.ReadFile(digestFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read digest file %q", digestFile)
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// checkBaseImagesAvailableLocally fails if any of base images of the Dockerfile is not available locally
+func (buildCtx *BuildContext) checkBaseImagesAvailableLocally(dockerFilePath string) error {
+	content, err := os.ReadFile(dockerFilePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read Dockerfile from %s", dockerFilePath)
+	}
+	rewritten, _ := buildCtx.Registries().RewriteDockerfile(string(content))
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return errors.Wrapf(err, "failed to init docker util")
+	}
+	return errors.Wrapf(buildCtx.checkImagesAvailableLocally(dockerUtil, docker.ParseBaseImages(rewritten)),
+		"cannot build image with pull policy %q", docker.PullPolicyNever)
+}
+
+// dockerImageBuilder builds images using local Docker daemon
+type dockerImageBuilder struct {
+	buildCtx *BuildContext
+}
+
+func (b *dockerImageBuilder) Name() ImageBuilderType {
+	return ImageBuilderDocker
+}
+
+func (b *dockerImageBuilder) Build(req imageBuildRequest) error {
+	buildCtx := b.buildCtx
+	buildParams := req.buildParams
+	dockerFile, err := docker.NewDockerfile(buildCtx.GoContext(), req.dockerFilePath, req.tags...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to init Dockerfile object")
+	}
+	dockerFile.DisableNoCache = !buildCtx.NoCache
+	dockerFile.SkipHashLabel = !buildParams.allowLabels
+	dockerFile.Context = buildCtx.GoContext()
+	dockerFile.ContextPath = buildParams.dockerImage.Build.ContextPath
+	dockerFile.Args, err = buildParams.dockerImage.Build.ArgsToMap()
+	dockerFile.ReuseImagesWithSameCfg = buildParams.allowReuse
+	dockerFile.Registries = buildCtx.Registries()
+	pullPolicy := buildCtx.EffectivePullPolicy(buildParams.dockerImage.Pull)
+	if pullPolicy == docker.PullPolicyNever {
+		if err := buildCtx.checkBaseImagesAvailableLocally(req.dockerFilePath); err != nil {
+			return err
+		}
+	}
+	// Docker refreshes base images on each build by default, unless pulling is disabled
+	dockerFile.DisablePull = pullPolicy == docker.PullPolicyIfNotPresent || pullPolicy == docker.PullPolicyNever
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert docker args to map")
+	}
+	// docker build
+	reader, err := dockerFile.Build()
+	if err != nil {
+		return errors.Wrapf(err, "failed to build docker image")
+	}
+	return reader.Listen(false, docker.MessageToLogFunc(buildCtx.Logger(), buildParams.subject))
+}
+
+func (b *dockerImageBuilder) Push(req imageBuildRequest) (OutDockerImageDefinition, error) {
+	buildCtx := b.buildCtx
+	dockerDef := req.buildParams.dockerImage
+	pushedDockerImage := OutDockerImageDefinition{
+		Name:    dockerDef.Name,
+		Digests: make([]OutDockerDigestDefinition, 0),
+	}
+	for _, tag := range req.tags {
+		dockerfile, err := docker.NewDockerfile(buildCtx.GoContext(), req.root.PathTo(buildCtx.RootDir(), dockerDef.DockerFile), tag)
+		if err != nil {
+			return pushedDockerImage, err
+		}
+		dockerfile.Context = buildCtx.GoContext()
+		dockerfile.ContextPath = dockerDef.Build.ContextPath
+		reader, err := dockerfile.Push()
+		if err != nil {
+			return pushedDockerImage, err
+		}
+		if err := reader.Listen(false, docker.MessageToLogFunc(buildCtx.Logger(), req.module)); err != nil {
+			return pushedDockerImage, err
+		}
+		for repoTag, digest := range dockerfile.TagDigests {
+			image, err := docker.ImageFromReference(repoTag)
+			if err != nil {
+				return pushedDockerImage, errors.Wrapf(err, "failed to determine image name from tag: %s", repoTag)
+			}
+			pushedDockerImage.Digests = append(pushedDockerImage.Digests, OutDockerDigestDefinition{
+				Tag:    digest.Tag,
+				Digest: digest.Digest,
+				Image:  image,
+			})
+		}
+	}
+	return pushedDockerImage, nil
+}
+
+// kanikoImageBuilder builds and pushes images using Kaniko executor (no Docker daemon required)
+type kanikoImageBuilder struct {
+	buildCtx *BuildContext
+	opts     ImageBuilderOpts
+}
+
+func (b *kanikoImageBuilder) Name() ImageBuilderType {
+	return ImageBuilderKaniko
+}
+
+func (b *kanikoImageBuilder) Build(req imageBuildRequest) error {
+	buildCtx := b.buildCtx
+	if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
+		return errors.Wrapf(err, "failed to create output dir")
+	}
+
+	if err := req.removeOutputFile(buildCtx, "digest"); err != nil {
+		return err
+	}
+	dockerFilePath, cleanup, err := req.rewrittenDockerfilePath(buildCtx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := []string{b.opts.ExecutorPath}
+	if !buildCtx.NoCache {
+		args = append(args, "--cache")
+		if b.opts.CachePath != "" {
+			args = append(args, "--cache-dir", b.opts.CachePath)
+		}
+	}
+	args = append(args, "--dockerfile", dockerFilePath)
+	args = append(args, "--context", req.contextPath())
+	for _, tag := range req.tags {
+		args = append(args, "--destination", tag)
+	}
+	if argsMap, err := req.buildParams.dockerImage.Build.ArgsToMap(); err != nil {
+		return errors.Wrapf(err, "failed to convert build args")
+	} else {
+		for key, value := range argsMap {
+			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, *value))
+		}
+	}
+	if b.opts.Push {
+		args = append(args, "--digest-file", req.outputFilePath(buildCtx, "digest"))
+	} else {
+		args = append(args, "--no-push")
+	}
+	return execBuilderCommand(buildCtx, "kaniko", args, b.opts.ExtraArgs)
+}
+
+func (b *kanikoImageBuilder) Push(req imageBuildRequest) (OutDockerImageDefinition, error) {
+	// kaniko doesn't keep built images, they are pushed during the build (if requested)
+	if !b.opts.Push {
+		return OutDockerImageDefinition{}, pushedDuringBuildOnly(ImageBuilderKaniko)
+	}
+	digest, err := readDigestFile(req.outputFilePath(b.buildCtx, "digest"))
+	if err != nil {
+		return OutDockerImageDefinition{}, err
+	}
+	return req.pushedImageWithDigest(digest)
+}
+
+// buildKitImageBuilder builds and pushes images using BuildKit client (buildctl) against running buildkitd
+type buildKitImageBuilder struct {
+	buildCtx *BuildContext
+	opts     ImageBuilderOpts
+}
+
+func (b *buildKitImageBuilder) Name() ImageBuilderType {
+	return ImageBuilderBuildKit
+}
+
+func (b *buildKitImageBuilder) Build(req imageBuildRequest) error {
+	buildCtx := b.buildCtx
+	if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
+		return errors.Wrapf(err, "failed to create output dir")
+	}
+
+	if err := req.removeOutputFile(buildCtx, "metadata.json"); err != nil {
+		return err
+	}
+	dockerFilePath, cleanup, err := req.rewrittenDockerfilePath(buildCtx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := []string{b.opts.ExecutorPath, "build", "--frontend", "dockerfile.v0"}
+	args = append(args, "--local", "context="+req.contextPath())
+	args = append(args, "--local", "dockerfile="+filepath.Dir(dockerFilePath))
+	args = append(args, "--opt", "filename="+filepath.Base(dockerFilePath))
+	if buildCtx.NoCache {
+		args = append(args, "--no-cache")
+	} else if b.opts.CachePath != "" {
+		args = append(args, "--export-cache", "type=local,dest="+b.opts.CachePath)
+		args = append(args, "--import-cache", "type=local,src="+b.opts.CachePath)
+	}
+	if argsMap, err := req.buildParams.dockerImage.Build.ArgsToMap(); err != nil {
+		return errors.Wrapf(err, "failed to convert build args")
+	} else {
+		for key, value := range argsMap {
+			args = append(args, "--opt", fmt.Sprintf("build-arg:%s=%s", key, *value))
+		}
+	}
+	args = append(args, "--output", fmt.Sprintf("type=image,\"name=%s\",push=%t", strings.Join(req.tags, ","), b.opts.Push))
+	args = append(args, "--metadata-file", req.outputFilePath(buildCtx, "metadata.json"))
+	return execBuilderCommand(buildCtx, "buildkit", args, b.opts.ExtraArgs)
+}
+
+func (b *buildKitImageBuilder) Push(req imageBuildRequest) (OutDockerImageDefinition, error) {
+	// images built by buildkit are not exported locally, they are pushed during the build (if requested)
+	if !b.opts.Push {
+		return OutDockerImageDefinition{}, pushedDuringBuildOnly(ImageBuilderBuildKit)
+	}
+	metadataFile := req.outputFilePath(b.buildCtx, "metadata.json")
+	bytes, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return OutDockerImageDefinition{}, errors.Wrapf(err, "failed to read metadata file %q", metadataFile)
+	}
+	metadata := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &metadata); err != nil {
+		return OutDockerImageDefinition{}, errors.Wrapf(err, "failed to parse metadata file %q", metadataFile)
+	}
+	digest, _ := metadata["containerimage.digest"].(string)
+	return req.pushedImageWithDigest(digest)
+}
+
+// buildahImageBuilder builds and pushes images using Buildah (no Docker daemon required)
+type buildahImageBuilder struct {
+	buildCtx *BuildContext
+	opts     ImageBuilderOpts
+}
+
+func (b *buildahImageBuilder) Name() ImageBuilderType {
+	return ImageBuilderBuildah
+}
+
+func (b *buildahImageBuilder) Build(req imageBuildRequest) error {
+	buildCtx := b.buildCtx
+	dockerFilePath, cleanup, err := req.rewrittenDockerfilePath(buildCtx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := []string{b.opts.ExecutorPath, "bud", "--file", dockerFilePath}
+	if buildCtx.NoCache {
+		args = append(args, "--no-cache")
+	} else if b.opts.CachePath != "" {
+		// buildah caches layers within its local storage (--cache-from/--cache-to expect registry repository)
+		buildCtx.Logger().Logf("WARN: cache path is not supported by buildah and is ignored")
+	}
+	switch buildCtx.EffectivePullPolicy(req.buildParams.dockerImage.Pull) {
+	case docker.PullPolicyAlways:
+		args = append(args, "--pull=always")
+	case docker.PullPolicyNever:
+		args = append(args, "--pull=never")
+	}
+	for _, tag := range req.tags {
+		args = append(args, "--tag", tag)
+	}
+	if argsMap, err := req.buildParams.dockerImage.Build.ArgsToMap(); err != nil {
+		return errors.Wrapf(err, "failed to convert build args")
+	} else {
+		for key, value := range argsMap {
+			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, *value))
+		}
+	}
+	args = append(args, req.contextPath())
+	return execBuilderCommand(buildCtx, "buildah", args, b.opts.ExtraArgs)
+}
+
+func (b *buildahImageBuilder) Push(req imageBuildRequest) (OutDockerImageDefinition, error) {
+	buildCtx := b.buildCtx
+	if err := buildCtx.ensureOutputDirExists(buildCtx.RootDir()); err != nil {
+		return OutDockerImageDefinition{}, errors.Wrapf(err, "failed to create output dir")
+	}
+	digestFile := req.outputFilePath(buildCtx, "digest")
+	digest := ""
+	for _, tag := range req.tags {
+		args := []string{b.opts.ExecutorPath, "push", "--digestfile", digestFile, tag, "docker://" + tag}
+		if err := execBuilderCommand(buildCtx, "buildah", args, ""); err != nil {
+			return OutDockerImageDefinition{}, err
+		}
+		tagDigest, err := readDigestFile(digestFile)
+		if err != nil {
+			return OutDockerImageDefinition{}, err
+		}
+		digest = tagDigest
+	}
+	return req.pushedImageWithDigest(digest)
+}
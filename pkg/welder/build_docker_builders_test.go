@@ -0,0 +1,112 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestImageBuilderFor(t *testing.T) {
+	RegisterTestingT(t)
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewPrefixLogger("[build]", false))
+
+	testCases := []struct {
+		name         string
+		imageBuilder ImageBuilderType
+		opts         *ImageBuilderOpts
+		expected     ImageBuilderType
+		executor     string
+		expectErr    bool
+	}{
+		{name: "run images always use docker", imageBuilder: ImageBuilderKaniko, opts: &runImageBuilderOpts, expected: ImageBuilderDocker},
+		{name: "no options", imageBuilder: ImageBuilderKaniko, expected: ImageBuilderKaniko, executor: "/kaniko/executor"},
+		{name: "default", opts: &ImageBuilderOpts{}, expected: ImageBuilderDocker},
+		{name: "from definition", imageBuilder: ImageBuilderBuildah, opts: &ImageBuilderOpts{}, expected: ImageBuilderBuildah, executor: "buildah"},
+		{name: "cli overrides definition", imageBuilder: ImageBuilderBuildah, opts: &ImageBuilderOpts{Builder: ImageBuilderBuildKit}, expected: ImageBuilderBuildKit, executor: "buildctl"},
+		{name: "kaniko default executor", opts: &ImageBuilderOpts{Builder: ImageBuilderKaniko}, expected: ImageBuilderKaniko, executor: "/kaniko/executor"},
+		{name: "custom executor", opts: &ImageBuilderOpts{Builder: ImageBuilderKaniko, ExecutorPath: "/bin/kaniko"}, expected: ImageBuilderKaniko, executor: "/bin/kaniko"},
+		{name: "unknown", opts: &ImageBuilderOpts{Builder: "podman"}, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			RegisterTestingT(t)
+			builder, err := buildCtx.imageBuilderFor(dockerBuildParams{
+				dockerImage: DockerImageDefinition{Name: "image", Builder: tc.imageBuilder},
+				builderOpts: tc.opts,
+			})
+			if tc.expectErr {
+				Expect(err).NotTo(BeNil())
+				return
+			}
+			Expect(err).To(BeNil())
+			Expect(builder.Name()).To(Equal(tc.expected))
+			switch b := builder.(type) {
+			case *kanikoImageBuilder:
+				Expect(b.opts.ExecutorPath).To(Equal(tc.executor))
+			case *buildKitImageBuilder:
+				Expect(b.opts.ExecutorPath).To(Equal(tc.executor))
+			case *buildahImageBuilder:
+				Expect(b.opts.ExecutorPath).To(Equal(tc.executor))
+			}
+		})
+	}
+}
+
+func TestBuildDockerWithKanikoPushesDuringBuild(t *testing.T) {
+	RegisterTestingT(t)
+	projectDir := t.TempDir()
+	Expect(os.WriteFile(path.Join(projectDir, BuildConfigFileName), []byte(`schemaVersion: "1.9.0"
+registries:
+  rewrite:
+    - from: alpine
+      to: docker-proxy.example.com/library/alpine
+modules:
+  - name: app
+    dockerImages:
+      - name: app
+        builder: kaniko
+        dockerFile: ${project:root}/Dockerfile
+        tags:
+          - registry.example.com/app:1.0.0
+`), 0o644)).To(BeNil())
+	Expect(os.WriteFile(path.Join(projectDir, "Dockerfile"), []byte("FROM alpine:3.19\n"), 0o644)).To(BeNil())
+	// fake kaniko executor logs its arguments along with the Dockerfile and writes digest of the pushed image
+	executor := path.Join(projectDir, "executor")
+	Expect(os.WriteFile(executor, []byte(`#!/bin/sh
+echo "$@" > `+path.Join(projectDir, "kaniko.log")+`
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --dockerfile) cat "$2" >> `+path.Join(projectDir, "kaniko.log")+` ;;
+    --digest-file) echo sha256:1234 > "$2" ;;
+  esac
+  shift
+done
+`), 0o755)).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewPrefixLogger("[build]", false))
+	buildCtx.SetRootDir(projectDir)
+	Expect(buildCtx.BuildDockerWithBuilder(nil, ImageBuilderOpts{ExecutorPath: executor, Push: true})).To(BeNil())
+
+	kanikoLog, err := os.ReadFile(path.Join(projectDir, "kaniko.log"))
+	Expect(err).To(BeNil())
+	Expect(string(kanikoLog)).To(ContainSubstring("--destination registry.example.com/app:1.0.0"))
+	Expect(string(kanikoLog)).NotTo(ContainSubstring("--no-push"))
+	Expect(string(kanikoLog)).To(ContainSubstring("FROM docker-proxy.example.com/library/alpine:3.19"))
+	def, err := ReadOutDockerDefinition(path.Join(projectDir, BuildOutputDir, OutDockerFileName))
+	Expect(err).To(BeNil())
+	Expect(def.Modules[0].DockerImages[0].Digests).To(Equal([]OutDockerDigestDefinition{
+		{Image: "registry.example.com/app", Tag: "1.0.0", Digest: "sha256:1234"},
+	}))
+
+	// images built by kaniko are not stored anywhere, hence they can't be pushed separately
+	// (even if digest file of the previous build exists)
+	err = buildCtx.PushDocker(nil)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("kaniko pushes images only during the build"))
+}
@@ -0,0 +1,33 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestEnvFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, projectDir, cleanup := setupTempExampleProject(t, "testdata/env-file")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildCtx.SetRootDir(projectDir)
+
+	Expect(buildCtx.Run("print", 0, "print")).To(BeNil())
+
+	outputBytes, err := os.ReadFile(path.Join(projectDir, "output"))
+	Expect(err).To(BeNil())
+	Expect(strings.TrimSpace(string(outputBytes))).To(Equal("common=common;overridden=task;explicit=explicit;quoted=local;project=env-file"))
+
+	err = buildCtx.Run("missing", 0, "missing")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("failed to read env file"))
+}
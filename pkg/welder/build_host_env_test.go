@@ -0,0 +1,46 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestHostEnvModes(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Setenv("WELDER_TEST_STRAY", "stray")
+	t.Setenv("WELDER_TEST_INJECTED", "injected")
+
+	testCases := []struct {
+		task            string
+		expectedContent string
+	}{
+		{task: "inherit", expectedContent: "declared=declared;stray=stray;injected=injected;home=set"},
+		{task: "clean", expectedContent: "declared=declared;stray=;injected=injected;home="},
+		{task: "allowlist", expectedContent: "declared=declared;stray=;injected=;home=set"},
+	}
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.task, func(t *testing.T) {
+			RegisterTestingT(t)
+			_, projectDir, cleanup := setupTempExampleProject(t, "testdata/host-env")
+			defer cleanup()
+
+			buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+			buildCtx.SetRootDir(projectDir)
+
+			Expect(buildCtx.Run(tc.task, 0, tc.task)).To(BeNil())
+
+			outputBytes, err := os.ReadFile(path.Join(projectDir, "output"))
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(outputBytes))).To(Equal(tc.expectedContent))
+		})
+	}
+}
@@ -95,14 +95,19 @@ func (buildCtx *BuildContext) Run(envTask string, envStepIdx int, commandOrTask
 	}
 
 	// build custom image if specified
+	pullPolicy := docker.PullPolicyIfNotPresent
 	if runConfig.CustomImage.IsValid() {
 		if runConfig.CustomImage.Name == "" {
 			runConfig.CustomImage.Name = runConfig.Name
 		}
+		if runConfig.CustomImage.Pull == "" {
+			runConfig.CustomImage.Pull = runConfig.Pull
+		}
 		tags, err := buildCtx.buildDockerImage(&root, moduleName, dockerBuildParams{
 			dockerImage: runConfig.CustomImage,
 			allowReuse:  !buildCtx.NoCache,
 			allowLabels: true,
+			builderOpts: &runImageBuilderOpts,
 		})
 		if err != nil {
 			return err
@@ -111,6 +116,9 @@ func (buildCtx *BuildContext) Run(envTask string, envStepIdx int, commandOrTask
 			return errors.Errorf("image build didn't return any tags for an image")
 		}
 		runConfig.Image = tags[0]
+	} else {
+		runConfig.Image = buildCtx.Registries().RewriteImageReference(runConfig.Image)
+		pullPolicy = buildCtx.EffectivePullPolicy(runConfig.Pull)
 	}
 
 	run := runner.NewRun(buildCtx.CommonCtx)
@@ -143,8 +151,14 @@ func (buildCtx *BuildContext) Run(envTask string, envStepIdx int, commandOrTask
 	return dockerRun.
 		SetReuseContainers(buildCtx.ReuseContainers).
 		SetDisableCache(buildCtx.NoCache).
+		SetOffline(buildCtx.Offline).
+		SetPullPolicy(pullPolicy).
 		SetCleanupOrphans(buildCtx.RemoveOrphans).
 		SetContext(buildCtx.GoContext()).
+		AddLabels(map[string]string{
+			docker.LabelNameProject: root.ProjectNameOrDefault(),
+			docker.LabelNameRunName: runConfig.Name,
+		}).
 		MountDockerSocket().
 		KeepEnvironmentWithEachCommand().
 		Run(runCtx, commandOrTask)
@@ -165,7 +179,7 @@ func (buildCtx *BuildContext) RunScriptsOfSimpleStep(name string, rawStep RunAft
 	if err != nil {
 		return err
 	}
-	if len(step.Scripts) > 0 {
+	if step.HasScripts() {
 		convRun := step.ToRunSpec(name, buildRunCtx.buildDef.CommonRunDefinition)
 		run = &convRun
 	}
@@ -194,6 +208,13 @@ func (buildCtx *BuildContext) RunScripts(action string, runID string, root *Root
 		}
 	}
 
+	if err := runSpec.ReadScriptFile(root.ConfiguredRootPath()); err != nil {
+		return err
+	}
+	if err := buildCtx.resolveEnvFiles(root, moduleName, &runSpec.RunCfg.CommonSimpleRunDefinition); err != nil {
+		return err
+	}
+
 	stepBuildStartedAt := time.Now()
 	defer func() {
 		buildCtx.SetLastExecOutput(subCtx.LastExecOutput())
@@ -260,11 +281,11 @@ func (buildCtx *BuildContext) RunSteps(action string, root *RootBuildDefinition,
 			stepRunID = fmt.Sprintf("%s-%d", runID, stepIdx)
 		}
 
-		if len(step.Step.Scripts) > 0 {
+		if step.Step.HasScripts() {
 			convRun := step.Step.ToRunSpec(stepName, step.ToRunDefinition(buildRunCtx.buildDef.CommonRunDefinition))
 			run = &convRun
 		} else if step.Task != "" {
-			action, err := subCtx.ActualTaskDefinitionFor(root, step.Task, module, deployCtx)
+			action, err := subCtx.ActualTaskDefinitionWithParams(root, step.Task, step.With, module, deployCtx)
 			if err != nil {
 				return errors.Wrapf(err, "failed to calcualate task definition for task %s of module %s", step.Task, module)
 			}
@@ -0,0 +1,54 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestShellAndScriptFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	testCases := []struct {
+		task            string
+		expectedContent string
+		expectedError   bool
+	}{
+		{task: "default", expectedContent: "shell=default"},
+		{task: "bash", expectedContent: "shell=bash"},
+		{task: "not-strict", expectedContent: "reached"},
+		{task: "strict", expectedError: true},
+		{task: "script-file", expectedContent: "script file"},
+		{task: "interpreter", expectedContent: "shell=perl"},
+		{task: "interpreter-script-file", expectedContent: "perl script file"},
+	}
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.task, func(t *testing.T) {
+			RegisterTestingT(t)
+			_, projectDir, cleanup := setupTempExampleProject(t, "testdata/shell")
+			defer cleanup()
+
+			buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+			buildCtx.SetRootDir(projectDir)
+
+			err := buildCtx.Run(tc.task, 0, tc.task)
+			if tc.expectedError {
+				Expect(err).NotTo(BeNil())
+				Expect(path.Join(projectDir, "output")).NotTo(BeAnExistingFile())
+				return
+			}
+			Expect(err).To(BeNil())
+
+			outputBytes, err := os.ReadFile(path.Join(projectDir, "output"))
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(outputBytes))).To(Equal(tc.expectedContent))
+		})
+	}
+}
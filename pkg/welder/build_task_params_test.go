@@ -0,0 +1,48 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestTaskParams(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, projectDir, cleanup := setupTempExampleProject(t, "testdata/task-params")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildCtx.SetRootDir(projectDir)
+
+	Expect(buildCtx.Build()).To(BeNil())
+
+	outputBytes, err := os.ReadFile(path.Join(projectDir, "output"))
+	Expect(err).To(BeNil())
+	Expect(strings.TrimSpace(string(outputBytes))).To(Equal("api -> dev\nweb -> default"))
+}
+
+func TestResolveTaskParams(t *testing.T) {
+	RegisterTestingT(t)
+
+	task := TaskDefinition{Params: []TaskParamDefinition{
+		{Name: "chart", Required: true},
+		{Name: "namespace", Default: "default"},
+	}}
+
+	params, err := task.ResolveParams("deploy-chart", TaskParams{"chart": "api"})
+	Expect(err).To(BeNil())
+	Expect(params).To(Equal(TaskParams{"chart": "api", "namespace": "default"}))
+
+	_, err = task.ResolveParams("deploy-chart", TaskParams{"namespace": "dev"})
+	Expect(err).To(MatchError("required params of task deploy-chart are not specified: chart"))
+
+	_, err = task.ResolveParams("deploy-chart", TaskParams{"chart": "api", "replicas": "2"})
+	Expect(err).To(MatchError("task deploy-chart does not declare params: replicas"))
+}
@@ -0,0 +1,249 @@
+package welder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+	"github.com/simple-container-com/welder/pkg/mutagen"
+	"github.com/simple-container-com/welder/pkg/util"
+	"github.com/simple-container-com/welder/pkg/welder/runner"
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+const (
+	BundleFormatVersion  = "1"
+	bundleIndexFileName  = "index.json"
+	bundleImagesFileName = "images.tar"
+	bundleMutagenDir     = "mutagen/"
+)
+
+// BundleIndex describes contents of the offline bundle
+type BundleIndex struct {
+	FormatVersion string    `json:"formatVersion"`
+	WelderVersion string    `json:"welderVersion"`
+	ProjectName   string    `json:"projectName"`
+	Platform      string    `json:"platform"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Images        []string  `json:"images"`
+	Mutagen       []string  `json:"mutagen,omitempty"`
+}
+
+// BundleExportOpts defines options of exporting offline bundle
+type BundleExportOpts struct {
+	OutputPath  string   // path to the bundle archive to write
+	SkipMutagen bool     // do not include mutagen binary into the bundle
+	Pull        PullOpts // options of pulling images missing locally
+}
+
+// BundleImportOpts defines options of importing offline bundle
+type BundleImportOpts struct {
+	InputPath   string // path to the bundle archive to read
+	SkipMutagen bool   // do not install mutagen binary from the bundle
+}
+
+// ExportBundle writes archive with all images referenced by the project (and mutagen binary) to use in air-gapped environments
+func (buildCtx *BuildContext) ExportBundle(opts BundleExportOpts) (BundleIndex, error) {
+	_, root, err := types.ReadBuildModuleDefinition(buildCtx.RootDir())
+	if err != nil {
+		return BundleIndex{}, err
+	}
+	images, err := buildCtx.ReferencedImages()
+	if err != nil {
+		return BundleIndex{}, err
+	}
+	images = util.AddIfNotExist(images, buildCtx.Registries().RewriteImageReference(runner.VolumeSyncImage))
+	sort.Strings(images)
+
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return BundleIndex{}, errors.Wrapf(err, "failed to init docker util")
+	}
+	missing, err := dockerUtil.MissingImages(images)
+	if err != nil {
+		return BundleIndex{}, errors.Wrapf(err, "failed to check images available locally")
+	}
+	if len(missing) > 0 {
+		if buildCtx.Offline {
+			return BundleIndex{}, errors.Wrapf(buildCtx.checkImagesAvailableLocally(dockerUtil, missing), "cannot pull images in offline mode")
+		}
+		if err := buildCtx.pullImages(dockerUtil, missing, opts.Pull); err != nil {
+			return BundleIndex{}, err
+		}
+	}
+
+	index := BundleIndex{
+		FormatVersion: BundleFormatVersion,
+		WelderVersion: buildCtx.Version(),
+		ProjectName:   root.ProjectNameOrDefault(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		CreatedAt:     time.Now().UTC(),
+		Images:        images,
+	}
+	mutagenFiles := make(map[string][]byte)
+	if !opts.SkipMutagen {
+		if mutagenFiles, err = mutagen.DistributionFiles(); err != nil {
+			return BundleIndex{}, errors.Wrapf(err, "failed to read mutagen distribution")
+		}
+		for fileName := range mutagenFiles {
+			index.Mutagen = append(index.Mutagen, fileName)
+		}
+		sort.Strings(index.Mutagen)
+	}
+
+	// images tarball has to be saved to a temp file first, since size must be known to write tar header
+	imagesFile, err := os.CreateTemp("", "welder-bundle-images-*.tar")
+	if err != nil {
+		return BundleIndex{}, errors.Wrapf(err, "failed to create temp file")
+	}
+	defer func() {
+		_ = imagesFile.Close()
+		_ = os.Remove(imagesFile.Name())
+	}()
+	buildCtx.Logger().Logf(" - Saving %d images...", len(images))
+	if err := dockerUtil.SaveImages(images, imagesFile); err != nil {
+		return BundleIndex{}, err
+	}
+	if _, err := imagesFile.Seek(0, io.SeekStart); err != nil {
+		return BundleIndex{}, errors.Wrapf(err, "failed to rewind images file")
+	}
+
+	buildCtx.Logger().Logf(" - Writing bundle to %s...", opts.OutputPath)
+	if err := writeBundle(opts.OutputPath, index, imagesFile, mutagenFiles); err != nil {
+		return BundleIndex{}, errors.Wrapf(err, "failed to write bundle to %s", opts.OutputPath)
+	}
+	buildCtx.Logger().Logf(" - Exported %d images: ['%s']", len(images), strings.Join(images, "', '"))
+	return index, nil
+}
+
+// ImportBundle loads images from the offline bundle into the local Docker daemon (and installs mutagen binary)
+func (buildCtx *BuildContext) ImportBundle(opts BundleImportOpts) (BundleIndex, error) {
+	var index BundleIndex
+	file, err := os.Open(opts.InputPath)
+	if err != nil {
+		return index, errors.Wrapf(err, "failed to open bundle %s", opts.InputPath)
+	}
+	defer func() { _ = file.Close() }()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return index, errors.Wrapf(err, "failed to read bundle %s", opts.InputPath)
+	}
+	defer func() { _ = gzipReader.Close() }()
+
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return index, errors.Wrapf(err, "failed to init docker util")
+	}
+	mutagenFiles := make(map[string][]byte)
+	indexFound := false
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return index, errors.Wrapf(err, "failed to read bundle %s", opts.InputPath)
+		}
+		switch {
+		case header.Name == bundleIndexFileName:
+			if err := json.NewDecoder(tarReader).Decode(&index); err != nil {
+				return index, errors.Wrapf(err, "failed to read bundle index")
+			}
+			if index.FormatVersion != BundleFormatVersion {
+				return index, errors.Errorf("unsupported bundle format version %q (expected %q)", index.FormatVersion, BundleFormatVersion)
+			}
+			indexFound = true
+		case header.Name == bundleImagesFileName:
+			buildCtx.Logger().Logf(" - Loading images into Docker...")
+			if err := dockerUtil.LoadImages(tarReader, docker.MessageToLogFunc(buildCtx.Logger(), "")); err != nil {
+				return index, err
+			}
+		case strings.HasPrefix(header.Name, bundleMutagenDir) && !opts.SkipMutagen:
+			content, err := io.ReadAll(tarReader)
+			if err != nil {
+				return index, errors.Wrapf(err, "failed to read %s from bundle", header.Name)
+			}
+			mutagenFiles[path.Base(header.Name)] = content
+		}
+	}
+	if !indexFound {
+		return index, errors.Errorf("bundle %s does not contain %s", opts.InputPath, bundleIndexFileName)
+	}
+	if len(mutagenFiles) > 0 {
+		binaryPath, err := mutagen.InstallDistributionFiles(buildCtx.Logger(), mutagenFiles)
+		if err != nil {
+			return index, errors.Wrapf(err, "failed to install mutagen from bundle")
+		}
+		buildCtx.Logger().Logf(" - Installed mutagen to %s", binaryPath)
+	}
+	if err := buildCtx.checkImagesAvailableLocally(dockerUtil, index.Images); err != nil {
+		return index, errors.Wrapf(err, "bundle has not been imported completely")
+	}
+	buildCtx.Logger().Logf(" - Imported %d images of project %q: ['%s']", len(index.Images), index.ProjectName,
+		strings.Join(index.Images, "', '"))
+	return index, nil
+}
+
+func writeBundle(outputPath string, index BundleIndex, images *os.File, mutagenFiles map[string][]byte) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal bundle index")
+	}
+	if err := writeBundleEntry(tarWriter, bundleIndexFileName, int64(len(indexBytes)), bytes.NewReader(indexBytes)); err != nil {
+		return err
+	}
+	imagesInfo, err := images.Stat()
+	if err != nil {
+		return err
+	}
+	if err := writeBundleEntry(tarWriter, bundleImagesFileName, imagesInfo.Size(), images); err != nil {
+		return err
+	}
+	for _, fileName := range index.Mutagen {
+		content := mutagenFiles[fileName]
+		if err := writeBundleEntry(tarWriter, bundleMutagenDir+fileName, int64(len(content)), bytes.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeBundleEntry(tarWriter *tar.Writer, name string, size int64, content io.Reader) error {
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to write header of %s", name)
+	}
+	if _, err := io.Copy(tarWriter, content); err != nil {
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package welder
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteBundle(t *testing.T) {
+	RegisterTestingT(t)
+	tmpDir := t.TempDir()
+
+	imagesPath := path.Join(tmpDir, "images.tar")
+	Expect(os.WriteFile(imagesPath, []byte("images-tarball"), 0644)).To(BeNil())
+	images, err := os.Open(imagesPath)
+	Expect(err).To(BeNil())
+	defer images.Close()
+
+	index := BundleIndex{
+		FormatVersion: BundleFormatVersion,
+		ProjectName:   "test",
+		Images:        []string{"alpine:latest", "golang:1.22"},
+		Mutagen:       []string{"mutagen-linux-amd64.zip"},
+	}
+	bundlePath := path.Join(tmpDir, "bundle.tar.gz")
+	Expect(writeBundle(bundlePath, index, images, map[string][]byte{"mutagen-linux-amd64.zip": []byte("zip")})).To(BeNil())
+
+	file, err := os.Open(bundlePath)
+	Expect(err).To(BeNil())
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	Expect(err).To(BeNil())
+	tarReader := tar.NewReader(gzipReader)
+
+	entries := make(map[string]string)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).To(BeNil())
+		content, err := io.ReadAll(tarReader)
+		Expect(err).To(BeNil())
+		names = append(names, header.Name)
+		entries[header.Name] = string(content)
+	}
+	// index must go first, so that it is read before images are loaded
+	Expect(names).To(Equal([]string{"index.json", "images.tar", "mutagen/mutagen-linux-amd64.zip"}))
+	Expect(entries["images.tar"]).To(Equal("images-tarball"))
+	Expect(entries["mutagen/mutagen-linux-amd64.zip"]).To(Equal("zip"))
+
+	var readIndex BundleIndex
+	Expect(json.Unmarshal([]byte(entries["index.json"]), &readIndex)).To(BeNil())
+	Expect(readIndex.Images).To(Equal(index.Images))
+	Expect(readIndex.ProjectName).To(Equal("test"))
+}
@@ -0,0 +1,201 @@
+package welder
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/simple-container-com/welder/pkg/git"
+	"github.com/simple-container-com/welder/pkg/render"
+)
+
+const (
+	ChangelogFormatMarkdown = "markdown"
+
+	changelogMarkdownTemplate = "template://changelog/markdown.md.tpl"
+	changelogBreakingType     = "breaking"
+)
+
+// ChangelogFormats defines supported formats of the changelog
+var ChangelogFormats = []string{ChangelogFormatMarkdown, render.FormatJSON, render.FormatYAML}
+
+// changelogSectionTitles defines titles of changelog sections in the order they are rendered
+// (commits of other types are not included into the changelog)
+var changelogSectionTitles = []struct {
+	Type  string
+	Title string
+}{
+	{changelogBreakingType, "BREAKING CHANGES"},
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"revert", "Reverts"},
+	{"refactor", "Code Refactoring"},
+	{"docs", "Documentation"},
+}
+
+// Changelog defines changes made between two revisions grouped by Conventional Commit type and scope
+type Changelog struct {
+	Version  string             `json:"version"`
+	From     string             `json:"from,omitempty"` // empty if changelog starts from the first commit
+	To       string             `json:"to"`
+	Date     string             `json:"date"`
+	Sections []ChangelogSection `json:"sections"`
+}
+
+// ChangelogSection defines changes of the same type (e.g. features)
+type ChangelogSection struct {
+	Type   string           `json:"type"`
+	Title  string           `json:"title"`
+	Scopes []ChangelogScope `json:"scopes"`
+}
+
+// ChangelogScope defines changes of the same scope (changes without scope have empty name)
+type ChangelogScope struct {
+	Name    string           `json:"name,omitempty"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// ChangelogEntry defines single change
+type ChangelogEntry struct {
+	Hash        string `json:"hash"`
+	ShortHash   string `json:"shortHash"`
+	Description string `json:"description"`
+}
+
+// Changelog returns changes made between revisions (from the latest tag matching the pattern reachable from the
+// `to` revision, HEAD by default),
+// only commits changing files of the active module are considered when module is active
+func (ctx *VersionCtx) Changelog(from string, to string, tagPattern string) (Changelog, error) {
+	res := Changelog{From: from, To: to, Sections: []ChangelogSection{}}
+	gitClient := ctx.buildCtx.GitClient()
+	if gitClient == nil {
+		return res, errors.New("git repository is not found")
+	}
+	if res.To == "" {
+		res.To = "HEAD"
+	}
+	if res.From == "" {
+		description, err := gitClient.DescribeRevision(res.To, tagPattern)
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to find the latest version tag of %s", res.To)
+		}
+		// revision is tagged itself (e.g. changelog of the released version), so changes since the previous tag are listed
+		if description.Tag != "" && description.Distance == 0 {
+			if description, err = gitClient.DescribeRevision(res.To+"~1", tagPattern); err != nil {
+				// tagged root commit has no parent, hence there is no previous tag and all commits are listed
+				if commits, cErr := gitClient.Commits("", res.To); cErr != nil || len(commits) != 1 {
+					return res, errors.Wrapf(err, "failed to find the previous version tag of %s", res.To)
+				}
+				description = git.Description{}
+			}
+		}
+		res.From = description.Tag
+	}
+	paths, err := ctx.gitPaths(gitClient)
+	if err != nil {
+		return res, err
+	}
+	commits, err := gitClient.Commits(res.From, res.To, paths...)
+	if err != nil {
+		return res, errors.Wrapf(err, "failed to read commits between %q and %q", res.From, res.To)
+	}
+
+	res.Version = res.To
+	res.Date = time.Now().Format("2006-01-02")
+	if res.To == "HEAD" {
+		if version, err := ctx.Version(); err != nil {
+			ctx.buildCtx.Logger().Debugf("failed to calculate version for changelog: %s", err.Error())
+			res.Version = "Unreleased"
+		} else {
+			res.Version = version
+		}
+	} else if len(commits) > 0 {
+		res.Date = commits[0].Timestamp.Format("2006-01-02")
+	}
+
+	// commits are returned newest first, changelog lists them in the same order
+	sections := make(map[string]*ChangelogSection)
+	for _, commit := range commits {
+		parsed, ok := parseConventionalCommit(commit.Message)
+		if !ok {
+			continue
+		}
+		entry := newChangelogEntry(commit, parsed.Description)
+		addChangelogEntry(sections, parsed.Type, parsed.Scope, entry)
+		if parsed.Breaking {
+			if parsed.BreakingNote != "" {
+				entry.Description = parsed.BreakingNote
+			}
+			addChangelogEntry(sections, changelogBreakingType, parsed.Scope, entry)
+		}
+	}
+	for _, section := range changelogSectionTitles {
+		if s, ok := sections[section.Type]; ok {
+			s.Title = section.Title
+			res.Sections = append(res.Sections, *s)
+		}
+	}
+	return res, nil
+}
+
+// Write renders changelog in the format (markdown, json or yaml)
+func (c Changelog) Write(w io.Writer, format string) error {
+	if format == ChangelogFormatMarkdown || format == "" {
+		format = changelogMarkdownTemplate
+	}
+	return render.Write(w, format, c)
+}
+
+// PrependChangelog prepends rendered changelog to the file keeping its top-level heading (e.g. `# Changelog`) on top,
+// file is created if it doesn't exist
+func PrependChangelog(filePath string, changelog []byte) error {
+	existing, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %s", filePath)
+	}
+	content := string(existing)
+	heading := ""
+	if strings.HasPrefix(content, "# ") {
+		lines := strings.SplitN(content, "\n", 2)
+		heading = lines[0] + "\n\n"
+		content = ""
+		if len(lines) > 1 {
+			content = strings.TrimLeft(lines[1], "\n")
+		}
+	}
+	res := heading + strings.TrimRight(string(changelog), "\n") + "\n"
+	if content != "" {
+		res += "\n" + content
+	}
+	if err := os.WriteFile(filePath, []byte(res), 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", filePath)
+	}
+	return nil
+}
+
+func newChangelogEntry(commit git.CommitInfo, description string) ChangelogEntry {
+	shortHash := commit.Hash
+	if len(shortHash) > 7 {
+		shortHash = shortHash[:7]
+	}
+	return ChangelogEntry{Hash: commit.Hash, ShortHash: shortHash, Description: description}
+}
+
+// addChangelogEntry adds entry to the section of the type grouping entries by scope (in order of their appearance)
+func addChangelogEntry(sections map[string]*ChangelogSection, commitType string, scope string, entry ChangelogEntry) {
+	section, ok := sections[commitType]
+	if !ok {
+		section = &ChangelogSection{Type: commitType}
+		sections[commitType] = section
+	}
+	for i := range section.Scopes {
+		if section.Scopes[i].Name == scope {
+			section.Scopes[i].Entries = append(section.Scopes[i].Entries, entry)
+			return
+		}
+	}
+	section.Scopes = append(section.Scopes, ChangelogScope{Name: scope, Entries: []ChangelogEntry{entry}})
+}
@@ -0,0 +1,91 @@
+package welder
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/git"
+	"github.com/simple-container-com/welder/pkg/git/mock"
+)
+
+func TestModuleChangelog(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/example")
+	defer cleanup()
+
+	versionCtx := readVersionContext(tmpProjectDir, "armory")
+	gitMock := versionCtx.buildCtx.GitClient().(*mock.GitMock)
+	gitMock.On("Root").Return(tmpProjectDir)
+	// HEAD is already past the newer armory-0.2.0, changelog of the older armory-0.1.0 starts from the tag preceding it
+	gitMock.On("Describe", "armory-*").Return(git.Description{Tag: "armory-0.2.0", Distance: 2}, nil)
+	gitMock.On("DescribeRevision", "armory-0.1.0", "armory-*").Return(git.Description{Tag: "armory-0.1.0"}, nil)
+	gitMock.On("DescribeRevision", "armory-0.1.0~1", "armory-*").Return(git.Description{Tag: "armory-0.0.2", Distance: 4}, nil)
+	gitMock.On("Commits", "armory-0.0.2", "armory-0.1.0", []string{"services/armory"}).Return([]git.CommitInfo{
+		{Hash: "5555555aaaa", Message: "fix(api): handle empty config", Timestamp: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{Hash: "4444444aaaa", Message: "chore: update dependencies"},
+		{Hash: "3333333aaaa", Message: "feat(api)!: drop v1 endpoints\n\nBREAKING CHANGE: v1 endpoints are removed"},
+		{Hash: "2222222aaaa", Message: "feat: add health check"},
+		{Hash: "1111111aaaa", Message: "feat(api): add v2 endpoints"},
+	}, nil)
+
+	changelog, err := versionCtx.Changelog("", "armory-0.1.0", "armory-*")
+	Expect(err).To(BeNil())
+	Expect(changelog.From).To(Equal("armory-0.0.2"))
+	Expect(changelog.Version).To(Equal("armory-0.1.0"))
+	Expect(changelog.Date).To(Equal("2026-10-18"))
+
+	var buf bytes.Buffer
+	Expect(changelog.Write(&buf, ChangelogFormatMarkdown)).To(BeNil())
+	Expect(buf.String()).To(Equal(`## armory-0.1.0 (2026-10-18)
+
+### BREAKING CHANGES
+
+* **api:** v1 endpoints are removed (3333333)
+
+### Features
+
+* **api:** drop v1 endpoints (3333333)
+* **api:** add v2 endpoints (1111111)
+* add health check (2222222)
+
+### Bug Fixes
+
+* **api:** handle empty config (5555555)
+
+`))
+
+	changelogFile := path.Join(tmpProjectDir, "CHANGELOG.md")
+	Expect(os.WriteFile(changelogFile, []byte("# Changelog\n\n## armory-0.0.2 (2026-09-01)\n"), 0o644)).To(BeNil())
+	Expect(PrependChangelog(changelogFile, []byte("## armory-0.1.0 (2026-10-18)\n\n"))).To(BeNil())
+	content, err := os.ReadFile(changelogFile)
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(Equal("# Changelog\n\n## armory-0.1.0 (2026-10-18)\n\n## armory-0.0.2 (2026-09-01)\n"))
+}
+
+func TestModuleChangelogOfTaggedRootCommit(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/example")
+	defer cleanup()
+
+	versionCtx := readVersionContext(tmpProjectDir, "armory")
+	gitMock := versionCtx.buildCtx.GitClient().(*mock.GitMock)
+	gitMock.On("Root").Return(tmpProjectDir)
+	gitMock.On("DescribeRevision", "armory-0.0.1", "armory-*").Return(git.Description{Tag: "armory-0.0.1"}, nil)
+	gitMock.On("DescribeRevision", "armory-0.0.1~1", "armory-*").Return(git.Description{}, errors.New("reference not found"))
+	rootCommit := git.CommitInfo{Hash: "1111111aaaa", Message: "feat: initial commit", Timestamp: time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)}
+	gitMock.On("Commits", "", "armory-0.0.1", []string(nil)).Return([]git.CommitInfo{rootCommit}, nil)
+	gitMock.On("Commits", "", "armory-0.0.1", []string{"services/armory"}).Return([]git.CommitInfo{rootCommit}, nil)
+
+	changelog, err := versionCtx.Changelog("", "armory-0.0.1", "armory-*")
+	Expect(err).To(BeNil())
+	Expect(changelog.From).To(Equal(""))
+	Expect(changelog.Version).To(Equal("armory-0.0.1"))
+	Expect(changelog.Date).To(Equal("2026-09-01"))
+	Expect(changelog.Sections).To(HaveLen(1))
+}
@@ -0,0 +1,103 @@
+package welder
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+// Containers returns containers created by Welder for the current project (or for all projects if requested)
+func (buildCtx *BuildContext) Containers(allProjects bool) ([]docker.ContainerInfo, error) {
+	project, err := buildCtx.containersProject(allProjects)
+	if err != nil {
+		return nil, err
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to init docker util")
+	}
+	return dockerUtil.ListWelderContainers(project)
+}
+
+// ExecInContainer executes command within existing container created by Welder with the provided run ID
+func (buildCtx *BuildContext) ExecInContainer(runID string, command string) error {
+	info, err := buildCtx.findContainer(runID)
+	if err != nil {
+		return err
+	}
+	if !info.IsRunning() {
+		return errors.Errorf("container %s (%s) is not running: %s", info.Name, info.RunID, info.Status)
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return errors.Wrapf(err, "failed to init docker util")
+	}
+	// command runs under the same user and with the same environment as commands of the step
+	user, env, err := dockerUtil.ContainerRunConfig(info.ID)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		user = buildCtx.Username
+	}
+	dockerRun, err := docker.NewRun(info.RunID, info.Image)
+	if err != nil {
+		return errors.Wrapf(err, "failed to init container run")
+	}
+	dockerRun.
+		SetContext(buildCtx.GoContext()).
+		AttachToContainer(info.ID)
+	_, err = dockerRun.ExecCommand(&docker.RunContext{
+		Stdout:          os.Stdout,
+		Stderr:          os.Stderr,
+		Stdin:           os.Stdin,
+		User:            user,
+		Env:             env,
+		Debug:           buildCtx.Verbose,
+		CurrentOS:       buildCtx.OS(),
+		Silent:          !buildCtx.Verbose,
+		Tty:             true,
+		ErrorOnExitCode: true,
+		Logger:          buildCtx.Logger(),
+	}, command)
+	return err
+}
+
+// ContainerLogs streams logs of the existing container created by Welder with the provided run ID
+func (buildCtx *BuildContext) ContainerLogs(runID string, follow bool) error {
+	info, err := buildCtx.findContainer(runID)
+	if err != nil {
+		return err
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return errors.Wrapf(err, "failed to init docker util")
+	}
+	return dockerUtil.StreamContainerLogs(info.ID, os.Stdout, os.Stderr, follow)
+}
+
+func (buildCtx *BuildContext) findContainer(runID string) (docker.ContainerInfo, error) {
+	project, err := buildCtx.containersProject(false)
+	if err != nil {
+		return docker.ContainerInfo{}, err
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return docker.ContainerInfo{}, errors.Wrapf(err, "failed to init docker util")
+	}
+	return dockerUtil.FindWelderContainer(project, runID)
+}
+
+func (buildCtx *BuildContext) containersProject(allProjects bool) (string, error) {
+	if allProjects {
+		return "", nil
+	}
+	_, root, err := ReadBuildModuleDefinition(buildCtx.RootDir())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read build definition")
+	}
+	return root.ProjectNameOrDefault(), nil
+}
@@ -0,0 +1,61 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+// optionalEnvFileSuffix marks env files that may be missing
+const optionalEnvFileSuffix = "?"
+
+// resolveEnvFiles reads env files of the run definition and adds variables that are not declared in `env` explicitly
+// (inherited files go first, hence variable from the later file takes precedence over earlier files)
+func (buildCtx *BuildContext) resolveEnvFiles(root *types.RootBuildDefinition, moduleName string, runCfg *types.CommonSimpleRunDefinition) error {
+	if len(runCfg.EnvFile) == 0 {
+		return nil
+	}
+	tpl := Tpl{buildCtx: buildCtx, root: root}
+	if moduleName != "" {
+		module, err := root.RawModuleConfig(moduleName)
+		if err != nil {
+			return err
+		}
+		tpl.module = &module
+	}
+	fileEnv := make(map[string]string)
+	for _, envFile := range runCfg.EnvFile {
+		optional := strings.HasSuffix(envFile, optionalEnvFileSuffix)
+		envFilePath := strings.TrimSuffix(envFile, optionalEnvFileSuffix)
+		if !path.IsAbs(envFilePath) {
+			envFilePath = path.Join(root.ConfiguredRootPath(), envFilePath)
+		}
+		content, err := os.ReadFile(envFilePath)
+		if os.IsNotExist(err) && optional {
+			buildCtx.Logger().Debugf("Skip reading optional env file %s: file does not exist", envFilePath)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read env file %s", envFilePath)
+		}
+		env, err := util.ParseDotEnv(string(content))
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse env file %s", envFilePath)
+		}
+		for key, value := range env {
+			fileEnv[key] = value
+		}
+	}
+	// env may be shared with the cached definition, hence should not be modified in place
+	runCfg.Env = runCfg.Env.Copy()
+	for key, value := range fileEnv {
+		if _, declared := runCfg.Env[key]; !declared {
+			runCfg.Env[key] = types.StringValue(tpl.applyFieldTemplate("env."+key, value))
+		}
+	}
+	return errors.Wrapf(tpl.unresolvedPlaceholdersError(), "invalid env files %s", strings.Join(runCfg.EnvFile, ", "))
+}
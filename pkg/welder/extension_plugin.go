@@ -0,0 +1,135 @@
+package welder
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/template"
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+// extensionRequest is written to stdin of the extension executable
+type extensionRequest struct {
+	Namespace string                  `json:"namespace"`
+	Path      string                  `json:"path"`
+	Default   *string                 `json:"default,omitempty"`
+	Context   extensionRequestContext `json:"context"`
+}
+
+type extensionRequestContext struct {
+	Project  string   `json:"project"`
+	Module   string   `json:"module,omitempty"`
+	Profiles []string `json:"profiles"`
+	Env      string   `json:"env,omitempty"` // deployment environment
+}
+
+// extensionResponse is read from stdout of the extension executable (null value means the value is not found)
+type extensionResponse struct {
+	Value *string `json:"value"`
+	Error string  `json:"error,omitempty"`
+}
+
+// pluginExtensions returns extensions for custom placeholder namespaces configured in welder.yaml
+func (tpl *Tpl) pluginExtensions(builtIn map[string]template.Extension) map[string]template.Extension {
+	res := make(map[string]template.Extension, len(builtIn)+len(tpl.root.Extensions))
+	for namespace, extension := range builtIn {
+		res[namespace] = extension
+	}
+	// context is the same for all placeholders of the template, hence it is calculated once (when first needed)
+	var requestCtx *extensionRequestContext
+	requestContext := func() extensionRequestContext {
+		if requestCtx == nil {
+			ctx := tpl.extensionRequestContext()
+			requestCtx = &ctx
+		}
+		return *requestCtx
+	}
+	for namespace, def := range tpl.root.Extensions {
+		// built-in namespaces (including the ones of the template engine, e.g. `env`) can't be overridden
+		if _, exists := builtIn[namespace]; exists || types.IsBuiltInPlaceholderNamespace(namespace) {
+			tpl.buildCtx.Logger().Debugf("extension %s is ignored since it has the same name as the built-in placeholder namespace", namespace)
+			continue
+		}
+		res[namespace] = tpl.extPlugin(namespace, def, requestContext)
+	}
+	return res
+}
+
+// extPlugin enables placeholders like ${vault:<path>} resolved by external executable
+// (responses are cached per run, including the ones of values not found)
+func (tpl *Tpl) extPlugin(namespace string, def types.ExtensionDefinition, requestContext func() extensionRequestContext) template.Extension {
+	return func(noSubstitution, path string, defaultValue *string) (string, error) {
+		request, err := json.Marshal(extensionRequest{
+			Namespace: namespace,
+			Path:      path,
+			Default:   defaultValue,
+			Context:   requestContext(),
+		})
+		if err != nil {
+			return noSubstitution, errors.Wrapf(err, "failed to marshal request to extension %s", namespace)
+		}
+		cacheKey := strings.Join(append([]string{def.Command}, def.Args...), " ") + ":" + string(request)
+		value, cached := tpl.buildCtx.CachedExtensionValue(cacheKey)
+		if !cached {
+			response, err := tpl.execExtension(namespace, def, request)
+			if err != nil {
+				return noSubstitution, err
+			}
+			value = response.Value
+			tpl.buildCtx.CacheExtensionValue(cacheKey, value)
+		}
+		if value == nil {
+			if defaultValue != nil {
+				return *defaultValue, nil
+			}
+			return noSubstitution, nil
+		}
+		return *value, nil
+	}
+}
+
+// extensionRequestContext returns context of the placeholders passed to the extension executables
+func (tpl *Tpl) extensionRequestContext() extensionRequestContext {
+	res := extensionRequestContext{
+		Project:  tpl.root.ProjectName,
+		Module:   tpl.ActiveModuleName(),
+		Profiles: append([]string{}, tpl.buildCtx.Profiles...),
+	}
+	// extensions may be used in activation conditions of profiles, hence only explicit profiles are passed in such case
+	if err := tpl.buildCtx.StartResolving("extensions"); err == nil {
+		res.Profiles = tpl.buildCtx.ActiveProfiles(tpl.root, res.Module)
+		tpl.buildCtx.FinishResolving("extensions")
+	}
+	sort.Strings(res.Profiles)
+	if tpl.deployCtx != nil && len(tpl.deployCtx.Envs) > 0 {
+		res.Env = tpl.deployCtx.Envs[0]
+	}
+	return res
+}
+
+// execExtension invokes extension executable with the request and reads its response
+func (tpl *Tpl) execExtension(namespace string, def types.ExtensionDefinition, request []byte) (extensionResponse, error) {
+	var res extensionResponse
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(tpl.buildCtx.GoContext(), def.Command, def.Args...)
+	cmd.Dir = tpl.root.ConfiguredRootPath()
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	tpl.buildCtx.Logger().Debugf("Invoking extension %s: %s", namespace, string(request))
+	if err := cmd.Run(); err != nil {
+		return res, errors.Wrapf(err, "extension %s failed: %s", namespace, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return res, errors.Wrapf(err, "invalid response of extension %s: %q", namespace, stdout.String())
+	}
+	if res.Error != "" {
+		return res, errors.Errorf("extension %s: %s", namespace, res.Error)
+	}
+	return res, nil
+}
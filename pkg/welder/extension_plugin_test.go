@@ -0,0 +1,55 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestExtensionPlugins(t *testing.T) {
+	RegisterTestingT(t)
+	t.Setenv("EXTENSION_PLUGINS_TEST", "from-env")
+	projectDir, cleanup := createTempExampleProject(t, "testdata/extension-plugins")
+	defer cleanup()
+	rootDef, err := ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildDef, _, err := buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).To(BeNil())
+	Expect(buildDef.Env["DB_PASSWORD"]).To(Equal(StringValue("s3cr3t")))
+	Expect(buildDef.Env["DB_PASSWORD_AGAIN"]).To(Equal(StringValue("s3cr3t")))
+	Expect(buildDef.Env["MISSING"]).To(Equal(StringValue("fallback")))
+	Expect(buildDef.Env["SECURE"]).To(Equal(StringValue("true")))
+	Expect(buildDef.Env["CONTEXT"]).To(Equal(StringValue(`{"namespace":"vault","path":"context",` +
+		`"context":{"project":"extension-plugins","module":"app","profiles":["secure"]}}`)))
+	Expect(buildDef.Env["FROM_ENV"]).To(Equal(StringValue("from-env")))
+
+	Expect(buildDef.Env["MISSING_AGAIN"]).To(Equal(StringValue("fallback")))
+
+	// values are cached by the context, so they are not resolved again when the definition is read again
+	rootDef, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).To(BeNil())
+
+	calls, err := os.ReadFile(path.Join(projectDir, "calls.log"))
+	Expect(err).To(BeNil())
+	Expect(strings.Count(string(calls), `"path":"db.password"`)).To(Equal(1))
+	Expect(strings.Count(string(calls), `"path":"missing"`)).To(Equal(1))
+	Expect(string(calls)).To(HavePrefix("--mount secret "))
+	Expect(string(calls)).NotTo(ContainSubstring(`"namespace":"env"`))
+
+	_, err = buildCtx.ActualTaskDefinitionFor(&rootDef, "broken", "", nil)
+	Expect(err).To(BeNil())
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{Strict: true}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	_, err = buildCtx.ActualTaskDefinitionFor(&rootDef, "broken", "", nil)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("image: ${vault:broken}: extension vault: access denied"))
+}
@@ -3,6 +3,8 @@ package welder
 import (
 	"fmt"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/simple-container-com/welder/pkg/util"
@@ -67,6 +69,9 @@ func (buildCtx *BuildContext) ActualDeployDefinitionFor(root *types.RootBuildDef
 	if err := tpl.applyTemplatesWithMarshalling(deploy); err != nil {
 		return *deploy, module, err
 	}
+	if err := tpl.unresolvedPlaceholdersError(); err != nil {
+		return *deploy, module, errors.Wrapf(err, "invalid deploy definition of module %s", moduleName)
+	}
 	deploy.Init()
 
 	root.CacheDeployDef(cacheKey, *deploy)
@@ -86,6 +91,9 @@ func ActualSimpleStepsDefinitionFor(root *types.RootBuildDefinition, ctx *BuildC
 	if err := tpl.applyTemplatesWithMarshalling(&res); err != nil {
 		return res, err
 	}
+	if err := tpl.unresolvedPlaceholdersError(); err != nil {
+		return res, errors.Wrapf(err, "invalid step definition")
+	}
 	return res, err
 }
 
@@ -108,7 +116,11 @@ func (buildCtx *BuildContext) ActualBuildDefinitionFor(root *types.RootBuildDefi
 
 	build := module.Build.Init()
 	tpl := Tpl{buildCtx: buildCtx, root: root, module: &module}
-	err = tpl.calcActualBuildDefinitionFor(build, false)
+	if err = tpl.calcActualBuildDefinitionFor(build, false); err == nil {
+		if err := tpl.unresolvedPlaceholdersError(); err != nil {
+			return *build, module, errors.Wrapf(err, "invalid build definition of module %s", moduleName)
+		}
+	}
 
 	// cache calculated version
 	root.CacheBuildDef(cacheKey, *build)
@@ -120,6 +132,16 @@ func (buildCtx *BuildContext) ActualBuildDefinitionFor(root *types.RootBuildDefi
 func (buildCtx *BuildContext) ActualTaskDefinitionForRawTask(root *types.RootBuildDefinition, taskName string, task types.TaskDefinition,
 	moduleName string, deployCtx *DeployContext,
 ) (types.TaskDefinition, error) {
+	return buildCtx.actualTaskDefinition(root, taskName, task, nil, moduleName, deployCtx)
+}
+
+func (buildCtx *BuildContext) actualTaskDefinition(root *types.RootBuildDefinition, taskName string, task types.TaskDefinition,
+	with types.TaskParams, moduleName string, deployCtx *DeployContext,
+) (types.TaskDefinition, error) {
+	params, err := task.ResolveParams(taskName, with)
+	if err != nil {
+		return types.TaskDefinition{}, err
+	}
 	// return cached version
 	buildHash, err := buildCtx.CalcHash()
 	if err != nil {
@@ -129,7 +151,7 @@ func (buildCtx *BuildContext) ActualTaskDefinitionForRawTask(root *types.RootBui
 	if err != nil {
 		return types.TaskDefinition{}, errors.Wrapf(err, "failed to calc deploy context hash for module %s and task %s", moduleName, taskName)
 	}
-	cacheKey := fmt.Sprintf("%s:%s:%s:%s", buildHash, deployHash, taskName, moduleName)
+	cacheKey := fmt.Sprintf("%s:%s:%s:%s:%s", buildHash, deployHash, taskName, moduleName, params.CacheKey())
 	if cachedDef, ok := root.GetCachedTaskDef(cacheKey); ok {
 		return cachedDef, nil
 	}
@@ -141,19 +163,19 @@ func (buildCtx *BuildContext) ActualTaskDefinitionForRawTask(root *types.RootBui
 		if buildRunCtx, err := buildCtx.calcModuleBuildRunContext(root, moduleName, deployCtx); err != nil {
 			return types.TaskDefinition{}, err
 		} else {
-			tpl = &Tpl{buildCtx: buildRunCtx.buildCtx, root: root, deployCtx: deployCtx, module: buildRunCtx.module}
+			tpl = &Tpl{buildCtx: buildRunCtx.buildCtx, root: root, deployCtx: deployCtx, module: buildRunCtx.module, params: params}
 			build = &buildRunCtx.buildDef
 		}
 	} else {
 		build = &types.BuildDefinition{CommonRunDefinition: task.CommonRunDefinition}
-		tpl = &Tpl{buildCtx: buildCtx, root: root, deployCtx: deployCtx}
+		tpl = &Tpl{buildCtx: buildCtx, root: root, deployCtx: deployCtx, params: params}
 		if err := tpl.calcActualBuildDefinitionFor(build, deployCtx != nil); err != nil {
 			return types.TaskDefinition{}, err
 		}
 	}
 	for argName, argVal := range buildCtx.BuildArgs {
 		if argVal != "" {
-			task.Args[argName] = types.StringValue(tpl.applyTemplate(string(argVal)))
+			task.Args[argName] = types.StringValue(tpl.applyFieldTemplate("args."+argName, string(argVal)))
 		}
 	}
 	types.MergeRunDefinitions(task.CommonRunDefinition, &build.CommonRunDefinition, false)
@@ -166,13 +188,16 @@ func (buildCtx *BuildContext) ActualTaskDefinitionForRawTask(root *types.RootBui
 	}
 	types.MergeRunDefinitions(build.CommonRunDefinition, &task.CommonRunDefinition, false)
 	for scriptIndex, script := range task.Scripts {
-		task.Scripts[scriptIndex] = tpl.applyTemplate(script)
+		task.Scripts[scriptIndex] = tpl.applyFieldTemplate(fmt.Sprintf("script[%d]", scriptIndex), script)
 	}
-	task.Image = tpl.applyTemplate(task.Image)
+	task.Image = tpl.applyFieldTemplate("image", task.Image)
 	types.MergeMapIfEmpty(task.Args, tpl.buildCtx.BuildArgs)
 	if err := tpl.applyTemplatesWithMarshalling(&task); err != nil {
 		return types.TaskDefinition{}, err
 	}
+	if err := tpl.unresolvedPlaceholdersError(); err != nil {
+		return types.TaskDefinition{}, errors.Wrapf(err, "invalid definition of task %s", taskName)
+	}
 	// cache calculated version
 	root.CacheTaskDef(cacheKey, task)
 	return task, err
@@ -187,6 +212,17 @@ func (buildCtx *BuildContext) ActualTaskDefinitionFor(root *types.RootBuildDefin
 	return buildCtx.ActualTaskDefinitionForRawTask(root, taskName, task, moduleName, deployCtx)
 }
 
+// ActualTaskDefinitionWithParams builds effective task definition for the task invoked with params (via `with`)
+func (buildCtx *BuildContext) ActualTaskDefinitionWithParams(root *types.RootBuildDefinition, taskName string, with types.TaskParams,
+	moduleName string, deployCtx *DeployContext,
+) (types.TaskDefinition, error) {
+	task, err := root.RawTaskConfig(taskName)
+	if err != nil {
+		return types.TaskDefinition{}, err
+	}
+	return buildCtx.actualTaskDefinition(root, taskName, task, with, moduleName, deployCtx)
+}
+
 // ActualDockerImagesDefinitionFor reads build config for a specific module and builds effective docker definitions list
 func (buildCtx *BuildContext) ActualDockerImagesDefinitionFor(root *types.RootBuildDefinition, moduleName string) ([]types.DockerImageDefinition, error) {
 	module, err := root.RawModuleConfig(moduleName)
@@ -236,8 +272,8 @@ func (buildCtx *BuildContext) ActualDockerImagesDefinitionFor(root *types.RootBu
 	tpl := Tpl{buildCtx: buildCtx, root: root, module: &module}
 	for i, dockerImage := range module.DockerImages {
 		for argIdx, arg := range dockerImage.Build.Args {
-			dockerImage.Build.Args[argIdx].Value = tpl.applyTemplate(arg.Value)
-			dockerImage.Build.Args[argIdx].File = tpl.applyTemplate(arg.File)
+			dockerImage.Build.Args[argIdx].Value = tpl.applyFieldTemplate(fmt.Sprintf("build.args[%d].value", argIdx), arg.Value)
+			dockerImage.Build.Args[argIdx].File = tpl.applyFieldTemplate(fmt.Sprintf("build.args[%d].file", argIdx), arg.File)
 		}
 		if err := tpl.applyTemplatesWithMarshalling(&dockerImage); err != nil {
 			return nil, err
@@ -247,6 +283,9 @@ func (buildCtx *BuildContext) ActualDockerImagesDefinitionFor(root *types.RootBu
 	if err := tpl.applyTemplatesWithMarshalling(&res); err != nil {
 		return nil, err
 	}
+	if err := tpl.unresolvedPlaceholdersError(); err != nil {
+		return nil, errors.Wrapf(err, "invalid docker images definition of module %s", moduleName)
+	}
 
 	// cache calculated version
 	root.CacheDockerDef(cacheKey, res)
@@ -255,43 +294,86 @@ func (buildCtx *BuildContext) ActualDockerImagesDefinitionFor(root *types.RootBu
 }
 
 // ActiveProfiles returns list of active profile names
+// (profiles which activation conditions failed to evaluate are not activated, see ResolveActiveProfiles)
 func (buildCtx *BuildContext) ActiveProfiles(root *types.RootBuildDefinition, moduleName string) []string {
+	activeProfiles, failures := buildCtx.activeProfiles(root, moduleName)
+	for _, failure := range failures {
+		buildCtx.Logger().Logf("WARN: failed to evaluate activation condition of %s", failure)
+	}
+	return activeProfiles
+}
+
+// ResolveActiveProfiles returns list of active profile names
+// in strict mode fails if activation condition of any profile failed to evaluate (e.g. due to cyclic reference)
+func (buildCtx *BuildContext) ResolveActiveProfiles(root *types.RootBuildDefinition, moduleName string) ([]string, error) {
+	if !buildCtx.Strict {
+		return buildCtx.ActiveProfiles(root, moduleName), nil
+	}
+	activeProfiles, failures := buildCtx.activeProfiles(root, moduleName)
+	if len(failures) > 0 {
+		return activeProfiles, errors.Errorf("failed to evaluate activation condition of %d profile(s):\n  %s",
+			len(failures), strings.Join(failures, "\n  "))
+	}
+	return activeProfiles, nil
+}
+
+// activeProfiles returns list of active profile names along with failures of evaluating activation conditions
+func (buildCtx *BuildContext) activeProfiles(root *types.RootBuildDefinition, moduleName string) ([]string, []string) {
 	activeProfiles := make([]string, 0)
 	for _, profileName := range buildCtx.Profiles {
 		if _, ok := root.Profiles[profileName]; ok {
 			activeProfiles = append(activeProfiles, profileName)
 		}
 	}
-	for profileName, profile := range root.Profiles {
-		sox := profile.Activation.Sox && buildCtx.SoxEnabled
-		skipTests := profile.Activation.SkipTests && buildCtx.SkipTestsEnabled
-		bamboo := profile.Activation.Bamboo && buildCtx.IsRunningInBamboo()
-		verbose := profile.Activation.Verbose && buildCtx.Verbose
-		strict := profile.Activation.Strict && buildCtx.Strict
-		parallel := profile.Activation.Parallel && buildCtx.Parallel
-		pipelines := profile.Activation.Pipelines && buildCtx.IsRunningInBitbucketPipelines()
-		linux := profile.Activation.Linux && (runtime.GOOS == "linux" || buildCtx.SimulateOS == "linux")
-		darwin := profile.Activation.Darwin && (runtime.GOOS == "darwin" || buildCtx.SimulateOS == "darwin")
-		windows := profile.Activation.Windows && (runtime.GOOS == "windows" || buildCtx.SimulateOS == "windows")
-		if sox || skipTests || bamboo || verbose || strict || parallel || pipelines || linux || darwin || windows {
+	profileNames := make([]string, 0, len(root.Profiles))
+	for profileName := range root.Profiles {
+		profileNames = append(profileNames, profileName)
+	}
+	sort.Strings(profileNames)
+	var failures []string
+	for _, profileName := range profileNames {
+		if activated, err := buildCtx.isProfileActivated(root, moduleName, profileName); err != nil {
+			failures = append(failures, fmt.Sprintf("profile %s: %s", profileName, err.Error()))
+		} else if activated {
 			activeProfiles = util.AddIfNotExist(activeProfiles, profileName)
 		}
-		if len(profile.Activation.If) > 0 && buildCtx.SubResolveContextDepth("profiles") < 5 {
-			buildCtx.IncrementSubResolveContextDepth("profiles")
-			var module *types.ModuleDefinition
-			if len(moduleName) > 0 {
-				if activeModule, err := root.RawModuleConfig(moduleName); err == nil {
-					module = &activeModule
-				}
-			}
-			tpl := Tpl{buildCtx: buildCtx, root: root, module: module}
-			if res, err := tpl.evalToBool(profile.Activation.If); err == nil && res {
-				activeProfiles = util.AddIfNotExist(activeProfiles, profileName)
-			}
-			buildCtx.DecrementSubResolveContextDepth("profiles")
+	}
+	return activeProfiles, failures
+}
+
+// isProfileActivated returns true if profile is activated automatically (by modes or by its condition)
+func (buildCtx *BuildContext) isProfileActivated(root *types.RootBuildDefinition, moduleName string, profileName string) (bool, error) {
+	profile := root.Profiles[profileName]
+	sox := profile.Activation.Sox && buildCtx.SoxEnabled
+	skipTests := profile.Activation.SkipTests && buildCtx.SkipTestsEnabled
+	bamboo := profile.Activation.Bamboo && buildCtx.IsRunningInBamboo()
+	verbose := profile.Activation.Verbose && buildCtx.Verbose
+	strict := profile.Activation.Strict && buildCtx.Strict
+	parallel := profile.Activation.Parallel && buildCtx.Parallel
+	pipelines := profile.Activation.Pipelines && buildCtx.IsRunningInBitbucketPipelines()
+	linux := profile.Activation.Linux && (runtime.GOOS == "linux" || buildCtx.SimulateOS == "linux")
+	darwin := profile.Activation.Darwin && (runtime.GOOS == "darwin" || buildCtx.SimulateOS == "darwin")
+	windows := profile.Activation.Windows && (runtime.GOOS == "windows" || buildCtx.SimulateOS == "windows")
+	if sox || skipTests || bamboo || verbose || strict || parallel || pipelines || linux || darwin || windows {
+		return true, nil
+	}
+	if len(profile.Activation.If) == 0 {
+		return false, nil
+	}
+	// condition may reference other profiles (e.g. ${profile:ci.active}), so make sure there are no cycles
+	ref := "profile:" + profileName
+	if err := buildCtx.StartResolving(ref); err != nil {
+		return false, err
+	}
+	defer buildCtx.FinishResolving(ref)
+	var module *types.ModuleDefinition
+	if len(moduleName) > 0 {
+		if activeModule, err := root.RawModuleConfig(moduleName); err == nil {
+			module = &activeModule
 		}
 	}
-	return activeProfiles
+	tpl := Tpl{buildCtx: buildCtx, root: root, module: module}
+	return tpl.evalCondition(profile.Activation.If)
 }
 
 // CheckRunCondition checks whether this task needs to run
@@ -342,11 +424,19 @@ func (buildCtx *BuildContext) MergeDeployEnvironments(root *types.RootBuildDefin
 
 // IsProfileActive returns true if profile was activated
 func (buildCtx *BuildContext) IsProfileActive(name string, root *types.RootBuildDefinition) bool {
-	profiles := buildCtx.ActiveProfiles(root, "")
-	for _, p := range profiles {
-		if name == p {
-			return true
-		}
+	active, err := buildCtx.isProfileActive(name, root)
+	if err != nil {
+		buildCtx.Logger().Logf("WARN: profile %s: %s", name, err.Error())
+	}
+	return active
+}
+
+func (buildCtx *BuildContext) isProfileActive(name string, root *types.RootBuildDefinition) (bool, error) {
+	if _, ok := root.Profiles[name]; !ok {
+		return false, nil
+	}
+	if util.SliceContains(buildCtx.Profiles, name) {
+		return true, nil
 	}
-	return false
+	return buildCtx.isProfileActivated(root, "", name)
 }
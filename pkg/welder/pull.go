@@ -0,0 +1,322 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+	"github.com/simple-container-com/welder/pkg/pipelines"
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+const (
+	defaultPullAttempts = 3
+	defaultPullBackoff  = 2 * time.Second
+)
+
+// PullOpts defines options of pulling images referenced by the project
+type PullOpts struct {
+	DryRun   bool          // only print images that would be pulled
+	Attempts int           // number of attempts to pull each image
+	Backoff  time.Duration // delay before the first retry (doubled after each attempt)
+}
+
+// PullImages pulls images referenced by active modules concurrently (in offline mode only checks they are available locally)
+func (buildCtx *BuildContext) PullImages(opts PullOpts) error {
+	images, err := buildCtx.ReferencedImages()
+	if err != nil {
+		return err
+	}
+	if opts.DryRun || len(images) == 0 {
+		buildCtx.Logger().Logf(" - Images referenced by the project: ['%s']", strings.Join(images, "', '"))
+		return nil
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return errors.Wrapf(err, "failed to init docker util")
+	}
+	if buildCtx.Offline {
+		return errors.Wrapf(buildCtx.checkImagesAvailableLocally(dockerUtil, images), "cannot pull images in offline mode")
+	}
+	return buildCtx.pullImages(dockerUtil, images, opts)
+}
+
+// checkImagesAvailableOffline fails if any of the images within the scope is not available locally
+// (does nothing when not running in offline mode)
+func (buildCtx *BuildContext) checkImagesAvailableOffline(scope imagesScope) error {
+	if !buildCtx.Offline {
+		return nil
+	}
+	images, err := buildCtx.referencedImages(scope)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	dockerUtil, err := docker.NewDefaultUtil(buildCtx.GoContext())
+	if err != nil {
+		return errors.Wrapf(err, "failed to init docker util")
+	}
+	return errors.Wrapf(buildCtx.checkImagesAvailableLocally(dockerUtil, images), "cannot run in offline mode")
+}
+
+func (buildCtx *BuildContext) checkImagesAvailableLocally(dockerUtil *docker.DockerUtil, images []string) error {
+	missing, err := dockerUtil.MissingImages(images)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check images available locally")
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("%d image(s) are not available locally: ['%s']",
+			len(missing), strings.Join(missing, "', '"))
+	}
+	return nil
+}
+
+// pullImages pulls provided images concurrently (respecting max parallel count)
+func (buildCtx *BuildContext) pullImages(dockerUtil *docker.DockerUtil, images []string, opts PullOpts) error {
+	if opts.Attempts <= 0 {
+		opts.Attempts = defaultPullAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultPullBackoff
+	}
+	parallelCount := buildCtx.ParallelCount
+	if parallelCount <= 0 || parallelCount > len(images) {
+		parallelCount = len(images)
+	}
+	startedAt := time.Now()
+	buildCtx.Logger().Logf(" - Pulling %d images (max %d in parallel)...", len(images), parallelCount)
+
+	sem := semaphore.NewWeighted(int64(parallelCount))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var failed []string
+	completed := 0
+	for _, img := range images {
+		image := img
+		if err := sem.Acquire(buildCtx.GoContext(), 1); err != nil {
+			// wait for pulls in progress so that they don't outlive the call
+			wg.Wait()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+			imageStartedAt := time.Now()
+			err := buildCtx.pullImageWithRetries(dockerUtil, image, opts)
+			mutex.Lock()
+			defer mutex.Unlock()
+			completed++
+			if err != nil {
+				failed = append(failed, image)
+				buildCtx.Logger().Errf(" - [%d/%d] Failed to pull image '%s': %s", completed, len(images), image, err.Error())
+				return
+			}
+			buildCtx.Logger().Logf(" - [%d/%d] Pulled image '%s' in %s", completed, len(images), image,
+				util.FormatDuration(time.Since(imageStartedAt)))
+		}()
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return errors.Errorf("failed to pull %d image(s): ['%s']", len(failed), strings.Join(failed, "', '"))
+	}
+	buildCtx.Logger().Logf(" - Finished pulling %d images in %s", len(images), util.FormatDuration(time.Since(startedAt)))
+	return nil
+}
+
+// imagesScope defines which of the images referenced by active modules are collected
+type imagesScope struct {
+	steps            bool           // images of build steps (deploy steps if deploy context is set) and tasks they invoke
+	deployCtx        *DeployContext // deploy context to calculate deploy steps for
+	allTasks         bool           // images of all tasks
+	dockerImages     bool           // base images of Docker images and images of steps run after they are built
+	dockerImageNames []string       // names of Docker images to collect images for (all if empty)
+}
+
+// ReferencedImages returns images referenced by steps, tasks, pipes, custom images and Docker images of active modules
+// (with registry mirrors and rewrite rules applied)
+func (buildCtx *BuildContext) ReferencedImages() ([]string, error) {
+	// all tasks are included since they can be invoked via 'welder run'
+	return buildCtx.referencedImages(imagesScope{steps: true, allTasks: true, dockerImages: true})
+}
+
+// referencedImages returns images within the scope referenced by active modules
+// (with registry mirrors and rewrite rules applied)
+func (buildCtx *BuildContext) referencedImages(scope imagesScope) ([]string, error) {
+	detectedModule, root, err := ReadBuildModuleDefinition(buildCtx.RootDir())
+	if err != nil {
+		return nil, err
+	}
+	images := make([]string, 0)
+	addImages := func(refs ...string) {
+		for _, ref := range refs {
+			switch ref = strings.TrimSpace(ref); {
+			case ref == "":
+			case strings.Contains(ref, ParamPlaceholderPrefix):
+				buildCtx.Logger().Debugf("Skip image %s: it depends on params of the task", ref)
+			default:
+				images = util.AddIfNotExist(images, buildCtx.Registries().RewriteImageReference(ref))
+			}
+		}
+	}
+	addRunSpec := func(spec RunSpec) error {
+		if !spec.RunOn.IsContainer() {
+			return nil
+		}
+		if !spec.CustomImage.IsValid() {
+			addImages(spec.Image)
+			return nil
+		}
+		bases, err := buildCtx.dockerImageBaseImages(spec.CustomImage)
+		if err != nil {
+			return errors.Wrapf(err, "failed to detect base images of custom image of %q", spec.Name)
+		}
+		addImages(bases...)
+		return nil
+	}
+
+	for _, module := range buildCtx.ActiveModules(root, detectedModule) {
+		modCtx := NewBuildContext(buildCtx, buildCtx.Logger().SubLogger(module))
+		buildRunCtx, err := modCtx.calcModuleBuildRunContext(&root, module, scope.deployCtx)
+		if err != nil {
+			return nil, err
+		}
+		buildDef := buildRunCtx.buildDef
+		addTask := func(taskName string, params TaskParams) error {
+			task, err := modCtx.ActualTaskDefinitionWithParams(&root, taskName, params, module, scope.deployCtx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to calculate task definition for task %s of module %s", taskName, module)
+			}
+			return addRunSpec(task.ToRunSpec(taskName))
+		}
+		// tasks that are not invoked by steps are collected with placeholders as values of required params
+		addUninvokedTask := func(taskName string) error {
+			task, ok := root.Tasks[taskName]
+			if !ok {
+				return addTask(taskName, nil)
+			}
+			return addTask(taskName, task.PlaceholderParams())
+		}
+		for _, rawStep := range buildDef.Steps {
+			if !scope.steps {
+				break
+			}
+			step, err := root.ActualStepsDefinitionFor(&buildDef, &rawStep)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to calculate effective step definition of module %s", module)
+			}
+			if step.Step.HasScripts() {
+				if err := addRunSpec(step.Step.ToRunSpec(step.Name, step.ToRunDefinition(buildDef.CommonRunDefinition))); err != nil {
+					return nil, err
+				}
+			} else if step.Task != "" {
+				if err := addTask(step.Task, step.With); err != nil {
+					return nil, err
+				}
+			} else if step.Pipe != "" {
+				pipe := pipelines.NewPipe(step.Pipe, pipelines.NewBitbucketContext(modCtx.CommonCtx).
+					WithProjectRoot(root.ConfiguredRootPath()).
+					WithProjectName(root.ProjectNameOrDefault()))
+				image, err := pipe.DockerImage()
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to calculate docker image for pipe %s", step.Pipe)
+				}
+				addImages(image)
+			}
+		}
+		for _, taskName := range root.TaskNames() {
+			if !scope.allTasks {
+				break
+			}
+			if err := addUninvokedTask(taskName); err != nil {
+				return nil, err
+			}
+		}
+		if !scope.dockerImages {
+			continue
+		}
+		dockerDefs, err := modCtx.ActualDockerImagesDefinitionFor(&root, module)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to calc effective Docker images definition for module %s", module)
+		}
+		for _, dockerDef := range dockerDefs {
+			if len(scope.dockerImageNames) > 0 && !util.SliceContains(scope.dockerImageNames, dockerDef.Name) {
+				continue
+			}
+			bases, err := buildCtx.dockerImageBaseImages(dockerDef)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to detect base images of Docker image %q", dockerDef.Name)
+			}
+			addImages(bases...)
+			runAfterBuild := dockerDef.RunAfterBuild
+			if runAfterBuild.HasScripts() {
+				step, err := ActualSimpleStepsDefinitionFor(&root, modCtx, buildRunCtx.module, &runAfterBuild.SimpleStepDefinition)
+				if err != nil {
+					return nil, err
+				}
+				if err := addRunSpec(step.ToRunSpec(dockerDef.Name, buildDef.CommonRunDefinition)); err != nil {
+					return nil, err
+				}
+			}
+			for _, taskName := range runAfterBuild.Tasks {
+				if err := addUninvokedTask(taskName); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return images, nil
+}
+
+// dockerImageBaseImages returns base images of Docker image definition (either from Dockerfile or inline Dockerfile)
+func (buildCtx *BuildContext) dockerImageBaseImages(dockerDef DockerImageDefinition) ([]string, error) {
+	if dockerDef.InlineDockerfile != "" {
+		return docker.ParseBaseImages(dockerDef.InlineDockerfile), nil
+	}
+	dockerFilePath := dockerDef.DockerFile
+	if dockerFilePath != "" && !path.IsAbs(dockerFilePath) {
+		dockerFilePath = path.Join(buildCtx.RootDir(), dockerFilePath)
+	}
+	content, err := os.ReadFile(dockerFilePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read Dockerfile from %s", dockerFilePath)
+	}
+	return docker.ParseBaseImages(string(content)), nil
+}
+
+func (buildCtx *BuildContext) pullImageWithRetries(dockerUtil *docker.DockerUtil, image string, opts PullOpts) error {
+	callback := func(message *docker.ResponseMessage, err error) {}
+	if buildCtx.Verbose {
+		callback = docker.MessageToLogFunc(buildCtx.Logger(), image)
+	}
+	backoff := opts.Backoff
+	var err error
+	for attempt := 1; attempt <= opts.Attempts; attempt++ {
+		if err = dockerUtil.PullImage(image, runtime.GOARCH, callback); err != nil && runtime.GOARCH == "arm64" {
+			// there might be not many images for arm64, falling back to amd64
+			err = dockerUtil.PullImage(image, "amd64", callback)
+		}
+		if err == nil || attempt == opts.Attempts {
+			break
+		}
+		buildCtx.Logger().Logf(" - Failed to pull image '%s' (attempt %d of %d), retrying in %s: %s",
+			image, attempt, opts.Attempts, util.FormatDuration(backoff), err.Error())
+		select {
+		case <-time.After(backoff):
+		case <-buildCtx.GoContext().Done():
+			return buildCtx.GoContext().Err()
+		}
+		backoff *= 2
+	}
+	return err
+}
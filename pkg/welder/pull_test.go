@@ -0,0 +1,73 @@
+package welder
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestReferencedImages(t *testing.T) {
+	RegisterTestingT(t)
+	_, projectDir, cleanup := setupTempExampleProject(t, "testdata/pull-images")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildCtx.SetRootDir(projectDir)
+	buildCtx.SetRegistries(docker.RegistriesConfig{
+		Rewrite: []docker.ImageRewriteRule{{From: "gcr.io/", To: "mirror.gcr.example.com/"}},
+	})
+
+	images, err := buildCtx.ReferencedImages()
+	Expect(err).To(BeNil())
+	Expect(images).To(ConsistOf(
+		"golang:1.22",
+		"node:20",
+		"ubuntu:22.04",
+		"golangci/golangci-lint:v1.59",
+		"mcr.microsoft.com/playwright:v1.44.0",
+		"mirror.gcr.example.com/distroless/static:latest",
+	))
+}
+
+func TestReferencedImagesWithinScope(t *testing.T) {
+	RegisterTestingT(t)
+	_, projectDir, cleanup := setupTempExampleProject(t, "testdata/pull-images")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildCtx.SetRootDir(projectDir)
+
+	images, err := buildCtx.referencedImages(imagesScope{steps: true})
+	Expect(err).To(BeNil())
+	Expect(images).To(ConsistOf(
+		"golang:1.22",
+		"node:20",
+		"ubuntu:22.04",
+		"mcr.microsoft.com/playwright:v1.44.0",
+	))
+
+	images, err = buildCtx.referencedImages(imagesScope{dockerImages: true, dockerImageNames: []string{"backend"}})
+	Expect(err).To(BeNil())
+	Expect(images).To(ConsistOf("golang:1.22", "gcr.io/distroless/static:latest"))
+
+	images, err = buildCtx.referencedImages(imagesScope{dockerImages: true, dockerImageNames: []string{"other"}})
+	Expect(err).To(BeNil())
+	Expect(images).To(BeEmpty())
+}
+
+func TestReferencedImagesOfTasksWithRequiredParams(t *testing.T) {
+	RegisterTestingT(t)
+	_, projectDir, cleanup := setupTempExampleProject(t, "testdata/task-params")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildCtx.SetRootDir(projectDir)
+
+	images, err := buildCtx.ReferencedImages()
+	Expect(err).To(BeNil())
+	Expect(images).To(ConsistOf("alpine/helm:3.14"))
+}
@@ -0,0 +1,226 @@
+package welder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/simple-container-com/welder/pkg/template"
+	"github.com/simple-container-com/welder/pkg/util"
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+const (
+	ReleaseBumpAuto  = "auto"
+	ReleaseBumpPatch = "patch"
+	ReleaseBumpMinor = "minor"
+	ReleaseBumpMajor = "major"
+
+	DefaultReleaseTagFormat = "${module}-v${version}"
+)
+
+// ReleaseBumps defines supported ways to calculate the next version of the release
+var ReleaseBumps = []string{ReleaseBumpAuto, ReleaseBumpPatch, ReleaseBumpMinor, ReleaseBumpMajor}
+
+// ReleaseOpts defines options of the release
+type ReleaseOpts struct {
+	Bump      string // part of the version to bump (auto bump is calculated from Conventional Commits)
+	TagFormat string // format of the release tag (e.g. `${module}-v${version}`)
+	Deploy    bool   // deploy released modules to environments with autoDeploy enabled
+	Push      bool   // push release commit and tags to the remote
+}
+
+type releasedModule struct {
+	name    string
+	version string
+	tag     string
+}
+
+// Release releases active modules: bumps their versions, commits and tags the change, builds modules,
+// pushes their Docker images, deploys them (if requested) and pushes the commit along with the tags;
+// if bumping, committing, tagging or building fails, the tags are deleted and the commit is reset along with bumped
+// versions, later failures keep the commit and the tags (since Docker images may be already pushed)
+func (buildCtx *BuildContext) Release(opts ReleaseOpts) error {
+	gitClient := buildCtx.GitClient()
+	if gitClient == nil {
+		return errors.New("git repository is not found")
+	}
+	if clean, status, err := gitClient.IsWorkTreeClean(); err != nil {
+		return errors.Wrapf(err, "failed to check git worktree")
+	} else if !clean {
+		return errors.Errorf("git worktree is not clean:\n%s", status)
+	}
+	branch, err := gitClient.Branch()
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect current git branch")
+	}
+	head, err := gitClient.Hash()
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect current git commit")
+	}
+	detectedModule, root, err := types.ReadBuildModuleDefinition(buildCtx.RootDir())
+	if err != nil {
+		return err
+	}
+	modules := buildCtx.ActiveModules(root, detectedModule)
+	if len(modules) == 0 {
+		return errors.New("there are no modules to release")
+	}
+
+	var released []releasedModule
+	for _, module := range modules {
+		res, err := buildCtx.bumpReleaseVersion(module, opts)
+		if err != nil {
+			return buildCtx.rollbackRelease(head, nil, errors.Wrapf(err, "failed to bump version of module %s", module))
+		} else if res != nil {
+			released = append(released, *res)
+		}
+	}
+	if len(released) == 0 {
+		buildCtx.Logger().Logf(" - Nothing to release: there are no changes requiring version bump")
+		return nil
+	}
+
+	var releasedModules, tags []string
+	for _, module := range released {
+		releasedModules = append(releasedModules, module.name)
+		tags = append(tags, module.tag)
+	}
+	if _, err := gitClient.Commit("Release " + strings.Join(tags, ", ")); err != nil {
+		return buildCtx.rollbackRelease(head, nil, errors.Wrapf(err, "failed to commit release"))
+	}
+	var createdTags []string
+	for _, module := range released {
+		buildCtx.Logger().Logf(" - Releasing version %s of module '%s' (tag %s)", module.version, module.name, module.tag)
+		if err := gitClient.CreateTag(module.tag, fmt.Sprintf("Release %s %s", module.name, module.version)); err != nil {
+			return buildCtx.rollbackRelease(head, createdTags, err)
+		}
+		createdTags = append(createdTags, module.tag)
+	}
+
+	// every phase runs within its own context since context is cancelled once parallel executions are finished
+	if err := newDetachedBuildContext(buildCtx, buildCtx.Logger(), releasedModules).Build(); err != nil {
+		return buildCtx.rollbackRelease(head, tags, errors.Wrapf(err, "failed to build release"))
+	}
+	// once Docker images are being pushed the release is published, hence it is not rolled back anymore:
+	// the release commit and tags are kept, so that the release can be resumed manually
+	modulesFlags := "-m " + strings.Join(releasedModules, " -m ")
+	gitPush := fmt.Sprintf("`git push --atomic origin %s %s`", branch, strings.Join(tags, " "))
+	kept := fmt.Sprintf("release commit and tags %s are kept", strings.Join(tags, ", "))
+	dockerCtx := newDetachedBuildContext(buildCtx, buildCtx.Logger(), releasedModules)
+	if err := dockerCtx.BuildDockerWithBuilder(nil, ImageBuilderOpts{Push: true}); err != nil {
+		return errors.Wrapf(err, "failed to push Docker images of release (%s, resume with `welder docker push %s` and %s)",
+			kept, modulesFlags, gitPush)
+	}
+	if opts.Deploy {
+		if err := buildCtx.deployRelease(released); err != nil {
+			return errors.Wrapf(err, "failed to deploy release (%s, resume with `welder deploy %s` and %s)",
+				kept, modulesFlags, gitPush)
+		}
+	}
+	notPushed := fmt.Sprintf("release commit and tags %s are not pushed", strings.Join(tags, ", "))
+	if !opts.Push {
+		buildCtx.Logger().Logf(" - Release is done, %s", notPushed)
+		return nil
+	}
+	if err := gitClient.Push(branch, tags...); err != nil {
+		return errors.Wrapf(err, "failed to push release (%s)", notPushed)
+	}
+	return nil
+}
+
+// rollbackRelease deletes release tags and resets HEAD to the commit the release started from (discarding the release
+// commit along with bumped versions), returns the error the release failed with
+func (buildCtx *BuildContext) rollbackRelease(head string, tags []string, cause error) error {
+	gitClient := buildCtx.GitClient()
+	for _, tag := range tags {
+		if err := gitClient.DeleteTag(tag); err != nil {
+			return errors.Wrapf(cause, "failed to roll back release: %s", err.Error())
+		}
+	}
+	if err := gitClient.Reset(head); err != nil {
+		return errors.Wrapf(cause, "failed to roll back release: %s", err.Error())
+	}
+	buildCtx.Logger().Logf(" - Release is rolled back to commit %s", head)
+	return cause
+}
+
+// bumpReleaseVersion bumps version of the module, returns nil if there are no changes requiring the bump
+func (buildCtx *BuildContext) bumpReleaseVersion(module string, opts ReleaseOpts) (*releasedModule, error) {
+	modCtx := NewBuildContext(buildCtx, buildCtx.Logger())
+	modCtx.Modules = []string{module}
+	versionCtx, err := NewVersionCtx(modCtx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	bump := opts.Bump
+	if bump == ReleaseBumpAuto || bump == "" {
+		autoBump, err := versionCtx.CalcAutoBump(releaseTag(opts.TagFormat, module, "*"))
+		if err != nil {
+			return nil, err
+		}
+		bump = autoBump.Bump.String()
+	}
+	switch bump {
+	case ReleaseBumpMajor:
+		err = versionCtx.BumpMajor()
+	case ReleaseBumpMinor:
+		err = versionCtx.BumpMinor()
+	case ReleaseBumpPatch:
+		err = versionCtx.BumpPatch()
+	default:
+		buildCtx.Logger().Logf(" - Module '%s' has no changes requiring version bump", module)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// version context is re-created to read the updated configuration
+	if versionCtx, err = NewVersionCtx(modCtx, nil, nil); err != nil {
+		return nil, err
+	}
+	version, err := versionCtx.Version()
+	if err != nil {
+		return nil, err
+	}
+	return &releasedModule{name: module, version: version, tag: releaseTag(opts.TagFormat, module, version)}, nil
+}
+
+// deployRelease deploys released modules to their environments with autoDeploy enabled
+func (buildCtx *BuildContext) deployRelease(released []releasedModule) error {
+	_, root, err := types.ReadBuildModuleDefinition(buildCtx.RootDir())
+	if err != nil {
+		return err
+	}
+	for _, module := range released {
+		deployDef, _, err := buildCtx.ActualDeployDefinitionFor(&root, module.name, nil)
+		if err != nil {
+			return errors.Wrapf(err, "failed to calculate deploy definition for module %s", module.name)
+		}
+		var envs []string
+		for name, env := range deployDef.Environments {
+			if env.AutoDeploy {
+				envs = append(envs, name)
+			}
+		}
+		if len(envs) == 0 {
+			continue
+		}
+		sort.Strings(envs)
+		modCtx := newDetachedBuildContext(buildCtx, buildCtx.Logger(), []string{module.name})
+		if err := NewDeployContext(modCtx, envs).Deploy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseTag returns name of the release tag for the module and version according to the format
+func releaseTag(format string, module string, version string) string {
+	if format == "" {
+		format = DefaultReleaseTagFormat
+	}
+	return template.NewTemplate().WithData(util.Data{"module": module, "version": version}).Exec(format)
+}
@@ -0,0 +1,124 @@
+package welder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/git"
+	"github.com/simple-container-com/welder/pkg/git/mock"
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestRelease(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/release")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildCtx.SetRootDir(tmpProjectDir)
+	gitMock := releaseGitMock(tmpProjectDir)
+	gitMock.On("Push", "main", []string{"api-v1.1.0"}).Return(nil)
+	buildCtx.SetGitClient(gitMock)
+
+	Expect(buildCtx.Release(ReleaseOpts{TagFormat: DefaultReleaseTagFormat, Deploy: true, Push: true})).To(BeNil())
+	gitMock.AssertExpectations(t)
+	gitMock.AssertNotCalled(t, "Reset", "0123456789")
+
+	config, err := os.ReadFile(path.Join(tmpProjectDir, BuildConfigFileName))
+	Expect(err).To(BeNil())
+	Expect(string(config)).To(ContainSubstring("version: 1.1.0"))
+	Expect(string(config)).To(ContainSubstring("version: 2.3.1"))
+
+	buildLog, err := os.ReadFile(path.Join(tmpProjectDir, "build.log"))
+	Expect(err).To(BeNil())
+	Expect(strings.TrimSpace(string(buildLog))).To(Equal("built api 1.1.0"))
+	deployLog, err := os.ReadFile(path.Join(tmpProjectDir, "deploy-staging.log"))
+	Expect(err).To(BeNil())
+	Expect(strings.TrimSpace(string(deployLog))).To(Equal("deployed 1.1.0"))
+	_, err = os.Stat(path.Join(tmpProjectDir, "deploy-production.log"))
+	Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestReleaseRollsBackOnFailure(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/release")
+	defer cleanup()
+	Expect(os.WriteFile(path.Join(tmpProjectDir, "fail-build"), []byte{}, 0o644)).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildCtx.SetRootDir(tmpProjectDir)
+	gitMock := releaseGitMock(tmpProjectDir)
+	gitMock.On("DeleteTag", "api-v1.1.0").Return(nil)
+	gitMock.On("Reset", "0123456789").Return(nil)
+	buildCtx.SetGitClient(gitMock)
+
+	err := buildCtx.Release(ReleaseOpts{TagFormat: DefaultReleaseTagFormat, Deploy: true, Push: true})
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(HavePrefix("failed to build release"))
+	gitMock.AssertExpectations(t)
+	gitMock.AssertNotCalled(t, "Push", "main", []string{"api-v1.1.0"})
+	_, err = os.Stat(path.Join(tmpProjectDir, "deploy-staging.log"))
+	Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestReleaseKeepsCommitAndTagsOnDeployFailure(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/release")
+	defer cleanup()
+	Expect(os.WriteFile(path.Join(tmpProjectDir, "fail-deploy"), []byte{}, 0o644)).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildCtx.SetRootDir(tmpProjectDir)
+	gitMock := releaseGitMock(tmpProjectDir)
+	buildCtx.SetGitClient(gitMock)
+
+	err := buildCtx.Release(ReleaseOpts{TagFormat: DefaultReleaseTagFormat, Deploy: true, Push: true})
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(HavePrefix("failed to deploy release (release commit and tags api-v1.1.0 are kept, " +
+		"resume with `welder deploy -m api` and `git push --atomic origin main api-v1.1.0`)"))
+	gitMock.AssertExpectations(t)
+	gitMock.AssertNotCalled(t, "DeleteTag", "api-v1.1.0")
+	gitMock.AssertNotCalled(t, "Reset", "0123456789")
+	gitMock.AssertNotCalled(t, "Push", "main", []string{"api-v1.1.0"})
+}
+
+// releaseGitMock returns git mock of the release testdata project where only module api has changes to release
+func releaseGitMock(projectDir string) *mock.GitMock {
+	gitMock := &mock.GitMock{}
+	gitMock.On("IsWorkTreeClean").Return(true, "", nil)
+	gitMock.On("Branch").Return("main", nil)
+	gitMock.On("Hash").Return("0123456789", nil)
+	gitMock.On("Root").Return(projectDir)
+	gitMock.On("Describe", "api-v*").Return(git.Description{Tag: "api-v1.0.0", Distance: 2}, nil)
+	gitMock.On("Commits", "api-v1.0.0", "", []string{"services/api"}).Return([]git.CommitInfo{
+		{Message: "feat(api): add endpoint"},
+	}, nil)
+	gitMock.On("Describe", "web-v*").Return(git.Description{Tag: "web-v2.3.1", Distance: 2}, nil)
+	gitMock.On("Commits", "web-v2.3.1", "", []string{"services/web"}).Return([]git.CommitInfo{
+		{Message: "chore(api): update dependencies"},
+	}, nil)
+	gitMock.On("Commit", "Release api-v1.1.0").Return("1234567890", nil)
+	gitMock.On("CreateTag", "api-v1.1.0", "Release api 1.1.0").Return(nil)
+	return gitMock
+}
+
+func TestReleaseRequiresCleanWorkTree(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/release")
+	defer cleanup()
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildCtx.SetRootDir(tmpProjectDir)
+	gitMock := mock.GitMock{}
+	gitMock.On("IsWorkTreeClean").Return(false, "M welder.yaml", nil)
+	buildCtx.SetGitClient(&gitMock)
+
+	err := buildCtx.Release(ReleaseOpts{Push: true})
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("git worktree is not clean:\nM welder.yaml"))
+}
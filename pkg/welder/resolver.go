@@ -0,0 +1,169 @@
+package welder
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/simple-container-com/welder/pkg/util"
+	"github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+var (
+	argReferenceRegex     = regexp.MustCompile(`\$\{arg:([^:}|\s]+)(:)?`)
+	versionReferenceRegex = regexp.MustCompile(`\$\{project:version[:}|\s]`)
+)
+
+// resolver resolves args, env and version of the build definition in the order of their dependencies
+// (e.g. arg referencing another arg or version is resolved after them); every value is resolved only once
+type resolver struct {
+	tpl            *Tpl
+	def            *types.BuildDefinition
+	resolved       types.BuildArgs
+	version        *string
+	stack          []string // references being resolved (e.g. `arg:image -> version -> arg:build-number`)
+	unresolved     []string // chains of references to args that are not defined and have no default
+	unresolvedArgs []string // names of args that are not defined (each is reported once with the first chain)
+}
+
+func newResolver(tpl *Tpl, def *types.BuildDefinition) *resolver {
+	return &resolver{tpl: tpl, def: def, resolved: make(types.BuildArgs), version: tpl.version}
+}
+
+// resolve resolves all args and env of the definition
+func (r *resolver) resolve() error {
+	for _, name := range sortedKeys(r.def.Args) {
+		if _, err := r.resolveArg(name); err != nil {
+			return err
+		}
+	}
+	for name, value := range r.resolved {
+		r.def.Args[name] = value
+	}
+	r.tpl.buildCtx.BuildArgs = r.def.Args
+	for _, name := range sortedKeys(r.def.Env) {
+		value, err := r.resolveValue("env:"+name, string(r.def.Env[name]))
+		if err != nil {
+			return err
+		}
+		r.def.Env[name] = types.StringValue(value)
+	}
+	return r.unresolvedError()
+}
+
+// unresolvedError returns error listing all unresolved references with their chains (in strict mode only)
+func (r *resolver) unresolvedError() error {
+	if len(r.unresolved) == 0 || !r.tpl.buildCtx.Strict {
+		return nil
+	}
+	return errors.Errorf("failed to resolve %d reference(s):\n  %s", len(r.unresolved), strings.Join(r.unresolved, "\n  "))
+}
+
+// resolveArg resolves arg by its name
+func (r *resolver) resolveArg(name string) (string, error) {
+	if value, ok := r.resolved[name]; ok {
+		return string(value), nil
+	}
+	value, err := r.resolveValue("arg:"+name, string(r.def.Args[name]))
+	if err != nil {
+		return "", err
+	}
+	r.resolved[name] = types.StringValue(value)
+	return value, nil
+}
+
+// resolveVersion resolves version of the current module (or project)
+func (r *resolver) resolveVersion(unresolvedVersion string) (string, error) {
+	if r.version != nil {
+		return *r.version, nil
+	}
+	value, err := r.resolveValue("version", unresolvedVersion)
+	if err != nil {
+		return "", err
+	}
+	r.version = &value
+	return value, nil
+}
+
+// resolveValue resolves all references of the value first and then applies templates to it
+func (r *resolver) resolveValue(ref string, value string) (string, error) {
+	if err := r.push(ref); err != nil {
+		return "", err
+	}
+	defer r.pop()
+	for _, match := range argReferenceRegex.FindAllStringSubmatch(value, -1) {
+		argName, hasDefault := match[1], match[2] != ""
+		if _, ok := r.def.Args[argName]; ok {
+			if _, err := r.resolveArg(argName); err != nil {
+				return "", err
+			}
+		} else if !hasDefault {
+			chain := r.chain("arg:" + argName)
+			r.tpl.buildCtx.Logger().Debugf("unresolved reference: %s", chain)
+			if !util.SliceContains(r.unresolvedArgs, argName) {
+				r.unresolvedArgs = append(r.unresolvedArgs, argName)
+				r.unresolved = append(r.unresolved, chain)
+			}
+		}
+	}
+	if versionReferenceRegex.MatchString(value) {
+		if _, err := r.resolveVersion(r.unresolvedVersion()); err != nil {
+			return "", err
+		}
+	}
+	tpl := r.tpl.copyNonStrict()
+	tpl.buildCtx.BuildArgs = r.currentArgs()
+	tpl.version = r.version
+	return tpl.applyTemplate(value), nil
+}
+
+// unresolvedVersion returns raw version of the current module (or project)
+func (r *resolver) unresolvedVersion() string {
+	if r.tpl.module != nil && r.tpl.module.Version != "" {
+		return r.tpl.module.Version
+	}
+	return r.tpl.root.Version
+}
+
+// currentArgs returns args with all values resolved so far
+func (r *resolver) currentArgs() types.BuildArgs {
+	res := r.def.Args.Copy()
+	for name, value := range r.resolved {
+		res[name] = value
+	}
+	return res
+}
+
+func (r *resolver) push(ref string) error {
+	for _, existing := range r.stack {
+		if existing == ref {
+			return &types.CyclicReferenceError{Chain: r.chainRefs(ref)}
+		}
+	}
+	r.stack = append(r.stack, ref)
+	return nil
+}
+
+func (r *resolver) pop() {
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// chain returns full chain of references leading to the reference (including the ones resolved by the context)
+func (r *resolver) chain(ref string) string {
+	return strings.Join(r.chainRefs(ref), " -> ")
+}
+
+func (r *resolver) chainRefs(ref string) []string {
+	refs := append(r.tpl.buildCtx.ResolvingChain(), r.stack...)
+	return append(refs, ref)
+}
+
+func sortedKeys(values map[string]types.StringValue) []string {
+	res := make([]string, 0, len(values))
+	for k := range values {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
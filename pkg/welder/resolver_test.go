@@ -0,0 +1,90 @@
+package welder
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestResolveArgsInDependencyOrder(t *testing.T) {
+	RegisterTestingT(t)
+	rootDef, err := ReadBuildRootDefinition("testdata/resolve-order")
+	Expect(err).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildDef, _, err := buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).To(BeNil())
+	Expect(buildDef.Args["a"]).To(Equal(StringValue("e-d-c-b-a")))
+	Expect(buildDef.Args["image-tag"]).To(Equal(StringValue("1.2.7-e-d-c-b-a")))
+	Expect(buildDef.Env["A"]).To(Equal(StringValue("e-d-c-b-a")))
+	Expect(buildDef.Env["TAG"]).To(Equal(StringValue("1.2.7-e-d-c-b-a")))
+
+	versionCtx, err := NewVersionCtx(buildCtx, &rootDef, nil)
+	Expect(err).To(BeNil())
+	version, err := versionCtx.Version()
+	Expect(err).To(BeNil())
+	Expect(version).To(Equal("1.2.7"))
+}
+
+func TestResolveCycles(t *testing.T) {
+	RegisterTestingT(t)
+	rootDef, err := ReadBuildRootDefinition("testdata/resolve-order")
+	Expect(err).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{Profiles: []string{"args-cycle"}}}, &util.NoopLogger{})
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("cyclic reference: arg:x -> arg:y -> arg:x"))
+
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{Profiles: []string{"version-cycle"}}}, &util.NoopLogger{})
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("cyclic reference: arg:image-tag -> version -> arg:patch -> arg:image-tag"))
+
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{SoxEnabled: true}}, &util.NoopLogger{})
+	activeProfiles := buildCtx.ActiveProfiles(&rootDef, "")
+	Expect(activeProfiles).To(ContainElements("first", "second"))
+	Expect(activeProfiles).NotTo(ContainElement("cyclic-a"))
+	Expect(activeProfiles).NotTo(ContainElement("cyclic-b"))
+	Expect(buildCtx.ResolvingChain()).To(BeEmpty())
+
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{SoxEnabled: true, Strict: true}}, &util.NoopLogger{})
+	_, err = buildCtx.ResolveActiveProfiles(&rootDef, "")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`failed to evaluate activation condition of 2 profile(s):
+  profile cyclic-a: cyclic reference: profile:cyclic-a -> profile:cyclic-b -> profile:cyclic-a
+  profile cyclic-b: cyclic reference: profile:cyclic-b -> profile:cyclic-a -> profile:cyclic-b`))
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).NotTo(BeNil())
+	Expect(buildCtx.ResolvingChain()).To(BeEmpty())
+}
+
+func TestResolveErrorsInStrictMode(t *testing.T) {
+	RegisterTestingT(t)
+	rootDef, err := ReadBuildRootDefinition("testdata/resolve-errors")
+	Expect(err).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, &util.NoopLogger{})
+	buildDef, _, err := buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).To(BeNil())
+	Expect(buildDef.Env["REGISTRY"]).To(Equal(StringValue("${arg:registry}")))
+
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{Strict: true}}, &util.NoopLogger{})
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`failed to resolve 2 reference(s):
+  arg:image-tag -> version -> arg:patch -> arg:build-number
+  env:REGISTRY -> arg:registry`))
+
+	versionCtx, err := NewVersionCtx(buildCtx, &rootDef, nil)
+	Expect(err).To(BeNil())
+	_, err = versionCtx.Version()
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`failed to calculate actual build definition: failed to resolve 2 reference(s):
+  version -> arg:image-tag -> arg:build-number
+  version -> env:REGISTRY -> arg:registry`))
+}
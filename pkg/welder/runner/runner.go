@@ -84,7 +84,17 @@ func (ctx *Run) CalcRunInContainerParams(projectName string, projectRoot string,
 }
 
 func (ctx *Run) RunInContainer(action string, runID string, containerRunParams *RunParams, spec types.RunSpec) error {
+	pullPolicy := docker.PullPolicyIfNotPresent
+	if !spec.CustomImage.IsValid() {
+		// custom images are built locally, hence should not be rewritten (nor pulled)
+		spec.Image = ctx.Registries().RewriteImageReference(spec.Image)
+		pullPolicy = ctx.EffectivePullPolicy(spec.Pull)
+	}
 	ctx.Logger().Logf(" - Running %d scripts in container '%s'...", len(spec.Scripts), spec.Image)
+	shell, err := spec.ShellToRun()
+	if err != nil {
+		return err
+	}
 	var eg errgroup.Group
 	dockerRun, err := docker.NewRun(runID, spec.Image)
 	if err != nil {
@@ -96,8 +106,14 @@ func (ctx *Run) RunInContainer(action string, runID string, containerRunParams *
 	dockerRun.
 		SetReuseContainers(ctx.ReuseContainers).
 		SetDisableCache(ctx.NoCache).
+		SetOffline(ctx.Offline).
+		SetPullPolicy(pullPolicy).
 		SetCleanupOrphans(ctx.RemoveOrphans).
 		SetContext(ctx.GoContext()).
+		AddLabels(map[string]string{
+			docker.LabelNameProject: containerRunParams.ProjectName,
+			docker.LabelNameRunName: spec.Name,
+		}).
 		MountDockerSocket().
 		KeepEnvironmentWithEachCommand()
 
@@ -119,6 +135,7 @@ func (ctx *Run) RunInContainer(action string, runID string, containerRunParams *
 	scripts := spec.Scripts
 	runCfg := docker.RunContext{
 		Env:             spec.RunCfg.Env.ToBuildEnv(spec.RunCfg.InjectEnvRegex(ctx.CommonCtx)...),
+		Shell:           shell,
 		Stdout:          stdout,
 		Stderr:          stderr,
 		User:            ctx.Username,
@@ -170,14 +187,28 @@ func (ctx *Run) RunOnHost(action string, runID string, containerRunParams *RunPa
 	}()
 
 	executor := exec.NewExecWithOutput(ctx.GoContext(), ctx.Logger(), &captBuf)
+	shell, err := spec.ShellToRun()
+	if err != nil {
+		return err
+	}
 
 	for _, script := range spec.Scripts {
-		ctx.Logger().Logf(" - Executing script: '%s'", script)
+		if spec.ScriptFile != "" {
+			ctx.Logger().Logf(" - Executing script file: '%s'", spec.ScriptFile)
+		} else {
+			ctx.Logger().Logf(" - Executing script: '%s'", script)
+		}
 		workDir := containerRunParams.WorkDir
-		env := spec.RunCfg.Env.ToBuildEnv(spec.RunCfg.InjectEnvRegex(ctx.CommonCtx)...)
+		injectEnv := spec.RunCfg.InjectEnvRegex(ctx.CommonCtx)
+		if spec.HostEnv == types.HostEnvAllowlist {
+			injectEnv = append(injectEnv, types.HostEnvAllowlistRegex)
+		}
+		env := spec.RunCfg.Env.ToBuildEnv(injectEnv...)
 		if execRes, err := executor.ExecCommandAndLog(action, script, exec.Opts{
-			Wd:  workDir,
-			Env: env,
+			Wd:       workDir,
+			Env:      env,
+			CleanEnv: spec.HostEnv.IsClean(),
+			Shell:    shell,
 		}); err != nil {
 			return errors.Wrapf(err, "failed to execute %q (%q)", action, script)
 		} else {
@@ -23,6 +23,7 @@ type SyncOpts struct {
 const (
 	WelderVolumeSrc    = "WelderVolumeSrc"
 	WelderVolumeTarget = "WelderVolumeTarget"
+	VolumeSyncImage    = "alpine:latest" // helper image used to sync volumes
 )
 
 func (ctx *Run) ConfigureVolumes(dockerRun *docker.Run, runParams *RunParams) error {
@@ -202,7 +203,7 @@ func (ctx *Run) SyncVolume(projectName string, volume docker.Volume, opts SyncOp
 	ctx.Logger().Debugf("Syncing volume %q -> %q", volume.HostPath, volume.ContPath)
 	volumeName := volume.NameOrPathToName(projectName)
 	ctx.Logger().Debugf("Volume name will be %q", volumeName)
-	run, err := docker.NewRun(volumeName, "alpine:latest")
+	run, err := docker.NewRun(volumeName, ctx.Registries().RewriteImageReference(VolumeSyncImage))
 
 	defer func(run *docker.Run) {
 		_ = run.Destroy()
@@ -226,7 +227,13 @@ func (ctx *Run) SyncVolume(projectName string, volume docker.Volume, opts SyncOp
 		SetContext(ctx.GoContext()).
 		SetCommand("-c", "while sleep 100000; do :; done").
 		SetContext(ctx.GoContext()).
-		SetEntrypoint("/bin/sh")
+		AddLabels(map[string]string{
+			docker.LabelNameProject: projectName,
+			docker.LabelNameRunName: "sync " + volume.ContPath,
+		}).
+		SetEntrypoint("/bin/sh").
+		SetOffline(ctx.Offline).
+		SetPullPolicy(ctx.EffectivePullPolicy(""))
 	if ctx.ReuseContainers {
 		run.AllowReuseContainers()
 	}
@@ -0,0 +1,37 @@
+package welder
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/simple-container-com/welder/pkg/util"
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestStrictModeReportsUnresolvedPlaceholders(t *testing.T) {
+	RegisterTestingT(t)
+	rootDef, err := ReadBuildRootDefinition("testdata/strict-placeholders")
+	Expect(err).To(BeNil())
+
+	buildCtx := NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	buildDef, _, err := buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).To(BeNil())
+	Expect(buildDef.Steps[0].Step.Image).To(Equal("${arg:buidl-image}"))
+
+	buildCtx = NewBuildContext(&BuildContext{CommonCtx: &CommonCtx{Strict: true}}, util.NewStdoutLogger(os.Stdout, os.Stderr))
+	_, _, err = buildCtx.ActualBuildDefinitionFor(&rootDef, "app")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`invalid build definition of module app: failed to resolve 3 placeholder(s):
+  steps[0].step.image: ${arg:buidl-image}: no value and no default specified
+  steps[0].step.script[0]: ${arg:build-image | trunc "abc"}: failed to apply pipeline "trunc \"abc\"": ` +
+		`template: pipeline:1:18: executing "pipeline" at <"abc">: expected integer; found "abc"
+  steps[0].step.script[1]: ${mode:unknown}: no value and no default specified`))
+
+	_, err = buildCtx.ActualTaskDefinitionFor(&rootDef, "build-image", "", nil)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`invalid definition of task build-image: failed to resolve 2 placeholder(s):
+  image: ${arg:buidl-image}: no value and no default specified
+  script[1]: ${date:unknown-format}: unknown date format: unknown-format`))
+}
@@ -21,6 +21,14 @@ type Tpl struct {
 	module    *types.ModuleDefinition
 	extraVars util.Data
 	version   *string
+	params    types.TaskParams // params of the task invoked via `with`
+	failures  []placeholderFailure
+}
+
+// placeholderFailure defines placeholder failed to resolve within the field of definition (in strict mode)
+type placeholderFailure struct {
+	field   string
+	failure template.Failure
 }
 
 func (tpl *Tpl) copyNonStrict() *Tpl {
@@ -32,6 +40,7 @@ func (tpl *Tpl) copyNonStrict() *Tpl {
 		module:    tpl.module,
 		extraVars: tpl.extraVars,
 		version:   tpl.version,
+		params:    tpl.params,
 	}
 	if tpl.deployCtx != nil {
 		copyDepCtx := NewDeployContext(tpl.buildCtx, tpl.deployCtx.Envs)
@@ -47,12 +56,76 @@ func (tpl *Tpl) applyTemplate(value string) string {
 	return processedValue
 }
 
+// applyFieldTemplate applies templates to the value of the definition field
+// in strict mode placeholders that failed to resolve are left as is and collected to be reported at once
+func (tpl *Tpl) applyFieldTemplate(field string, value string) string {
+	engine := tpl.copyNonStrict().initTemplate()
+	if tpl.buildCtx.Strict {
+		engine.WithStrict(true).WithFailureHandler(func(failure template.Failure) {
+			for _, existing := range tpl.failures {
+				if existing.field == field && existing.failure.Placeholder == failure.Placeholder {
+					return
+				}
+			}
+			tpl.failures = append(tpl.failures, placeholderFailure{field: field, failure: failure})
+		})
+	}
+	processedValue := engine.Exec(value)
+	tpl.buildCtx.Logger().Debugf("Processing placeholders in %q, result: %q", value, processedValue)
+	return processedValue
+}
+
+// unresolvedPlaceholdersError returns error listing all placeholders that failed to resolve (if any)
+func (tpl *Tpl) unresolvedPlaceholdersError() error {
+	if len(tpl.failures) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(tpl.failures))
+	for _, f := range tpl.failures {
+		if f.field == "" {
+			lines = append(lines, f.failure.Error())
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", f.field, f.failure.Error()))
+		}
+	}
+	return errors.Errorf("failed to resolve %d placeholder(s):\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
 func (tpl *Tpl) evalToBool(value string) (bool, error) {
 	processedValue, err := tpl.initTemplate().EvalToBool(value)
 	tpl.buildCtx.Logger().Debugf("Processing placeholders in %q, result: %t", value, processedValue)
 	return processedValue, err
 }
 
+// evalCondition evaluates condition to bool, fails if condition references itself (e.g. via other profiles)
+// in strict mode fails if any of its placeholders failed to resolve
+func (tpl *Tpl) evalCondition(value string) (bool, error) {
+	var cyclicErr *types.CyclicReferenceError
+	var failures []string
+	engine := tpl.initTemplate().WithStrict(true).WithFailureHandler(func(failure template.Failure) {
+		var err *types.CyclicReferenceError
+		if errors.As(failure.Err, &err) {
+			if cyclicErr == nil {
+				cyclicErr = err
+			}
+			return
+		}
+		failures = append(failures, failure.Error())
+	})
+	res, err := engine.EvalToBool(value)
+	tpl.buildCtx.Logger().Debugf("Processing placeholders in %q, result: %t", value, res)
+	if cyclicErr != nil {
+		return false, cyclicErr
+	} else if tpl.buildCtx.Strict && len(failures) > 0 {
+		return false, errors.Errorf("failed to resolve %d placeholder(s) of condition %q:\n  %s",
+			len(failures), value, strings.Join(failures, "\n  "))
+	} else if err != nil {
+		tpl.buildCtx.Logger().Debugf("Failed to evaluate condition %q: %s", value, err.Error())
+		return false, nil
+	}
+	return res, nil
+}
+
 func (tpl *Tpl) initTemplate() *template.Template {
 	data := util.Data{}
 	data = tpl.addContainerTemplateVars(data)
@@ -62,15 +135,17 @@ func (tpl *Tpl) initTemplate() *template.Template {
 	return template.NewTemplate().
 		WithGit(tpl.buildCtx.GitClient()).
 		WithData(data).
+		WithBaseDir(tpl.root.ConfiguredRootPath()).
 		WithStrict(tpl.buildCtx.Strict).
-		WithExtensions(map[string]template.Extension{
+		WithExtensions(tpl.pluginExtensions(map[string]template.Extension{
 			"profile": tpl.extProfile,
 			"mode":    tpl.extMode,
 			"arg":     tpl.extArg,
 			"project": tpl.extProject,
 			"os":      tpl.extOS,
 			"task":    tpl.extTask,
-		})
+			"param":   tpl.extParam,
+		}))
 }
 
 // extTask enables placeholders like ${task:<name>.output} and ${task:<name>.success}
@@ -93,7 +168,6 @@ func (tpl *Tpl) extTask(noSubstitution, path string, defaultValue *string) (stri
 
 	ctx := NewBuildContext(tpl.buildCtx, &util.NoopLogger{})
 	action, err := ctx.ActualTaskDefinitionFor(tpl.root, taskName, moduleName, tpl.deployCtx)
-	ctx.DecrementSubResolveContextDepth("task")
 	if err != nil {
 		return noSubstitution, errors.Wrapf(err, "failed to calcualate task definition for task %s", taskName)
 	}
@@ -150,7 +224,7 @@ func (tpl *Tpl) extProject(noSubstitution, path string, defaultValue *string) (s
 		"name": tpl.root.ProjectName,
 		"root": tpl.root.ProjectRoot,
 	}
-	if tpl.version == nil {
+	if tpl.version == nil && !tpl.buildCtx.IsResolving("version") {
 		if verCtx, err := NewVersionCtx(tpl.buildCtx, tpl.root, tpl.module); err != nil {
 			tpl.version = &tpl.root.Version
 			if version, err := tpl.module.ActualVersion(*tpl.root); err == nil {
@@ -182,6 +256,9 @@ func (tpl *Tpl) extProject(noSubstitution, path string, defaultValue *string) (s
 		if defaultValue != nil {
 			return *defaultValue, nil
 		}
+		if (tpl.module == nil && strings.HasPrefix(path, "module.")) || (projectData["env"] == nil && path == "env") {
+			return noSubstitution, template.ErrNotAvailable
+		}
 		return noSubstitution, err
 	}
 	return res.(string), nil
@@ -200,6 +277,17 @@ func (tpl *Tpl) extArg(noSubstitution, path string, defaultValue *string) (strin
 	return noSubstitution, nil
 }
 
+// extParam enables placeholders like ${param:some-param} within tasks invoked with params
+func (tpl *Tpl) extParam(noSubstitution, path string, defaultValue *string) (string, error) {
+	if value, ok := tpl.params[path]; ok {
+		return string(value), nil
+	}
+	if defaultValue != nil {
+		return *defaultValue, nil
+	}
+	return noSubstitution, nil
+}
+
 // extMode enables placeholders like ${mode:sox}, {mode:bamboo} or ${mode:skip-tests}
 func (tpl *Tpl) extMode(noSubstitution, path string, defaultValue *string) (string, error) {
 	switch path {
@@ -239,7 +327,11 @@ func (tpl *Tpl) extProfile(noSubstitution, path string, defaultValue *string) (s
 	action := parts[1]
 	switch action {
 	case "active":
-		return strconv.FormatBool(tpl.buildCtx.IsProfileActive(profile, tpl.root)), nil
+		active, err := tpl.buildCtx.isProfileActive(profile, tpl.root)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(active), nil
 	}
 	if defaultValue != nil {
 		return *defaultValue, nil
@@ -303,49 +395,60 @@ func (tpl *Tpl) applyTemplateOnValues(target []string) {
 }
 
 func (tpl *Tpl) calcActualBuildDefinitionFor(res *types.BuildDefinition, isDeployment bool) error {
+	if _, err := tpl.resolveActualBuildDefinitionFor(res, isDeployment); err != nil {
+		return err
+	}
+	return tpl.applyTemplatesWithMarshalling(res)
+}
+
+// resolveActualBuildDefinitionFor merges definition with profiles and defaults and resolves its args and env
+func (tpl *Tpl) resolveActualBuildDefinitionFor(res *types.BuildDefinition, isDeployment bool) (*resolver, error) {
 	// args have priority
 	types.MergeMapIfEmpty(res.Args, tpl.buildCtx.BuildArgs)
 	if tpl.buildCtx.BuildArgs != nil {
 		res.Args = tpl.buildCtx.BuildArgs
 	}
 	// apply values from profiles if any
-	activeProfiles := tpl.buildCtx.ActiveProfiles(tpl.root, tpl.ActiveModuleName())
+	activeProfiles, err := tpl.buildCtx.ResolveActiveProfiles(tpl.root, tpl.ActiveModuleName())
+	if err != nil {
+		return nil, err
+	}
 	for _, profile := range activeProfiles {
 		if isDeployment {
 			types.MergeRunDefinitions(tpl.root.Profiles[profile].Deploy.BuildDefinition.CommonRunDefinition, &res.CommonRunDefinition, false)
 			if err := types.MergeSteps(tpl.root.Profiles[profile].Deploy.BuildDefinition, res); err != nil {
-				return err
+				return nil, err
 			}
 		}
 		types.MergeRunDefinitions(tpl.root.Profiles[profile].Build.CommonRunDefinition, &res.CommonRunDefinition, false)
 		if err := types.MergeSteps(tpl.root.Profiles[profile].Build, res); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	// inherit the rest from defaults
 	if isDeployment {
 		types.MergeRunDefinitions(*tpl.root.Default.Deploy.CommonRunDefinition.Init(), &res.CommonRunDefinition, false)
 		if err := types.MergeSteps(tpl.root.Default.Deploy.BuildDefinition, res); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	types.MergeRunDefinitions(*tpl.root.Default.Build.CommonRunDefinition.Init(), &res.CommonRunDefinition, false)
 	if err := types.MergeSteps(tpl.root.Default.Build, res); err != nil {
-		return err
+		return nil, err
 	}
 
-	// apply templates to all values
-	// hack: apply a few times so that cross-ref variables resolved (disabling strict mode)
-	// it'd be better if we check whether some placeholders are still unresolved instead
-	for i := 0; i < 3; i++ {
-		tpl.copyNonStrict().applyTemplateOnValuesMap(res.Args)
-		tpl.copyNonStrict().applyTemplateOnValuesMap(res.Env)
-		tpl.buildCtx.BuildArgs = res.Args
+	// resolve args and env in the order of their dependencies
+	r := newResolver(tpl, res)
+	if err := r.resolve(); err != nil {
+		return nil, err
 	}
-	return tpl.applyTemplatesWithMarshalling(res)
+	return r, nil
 }
 
+// applyTemplatesWithMarshalling applies templates to all string fields of the definition
+// placeholders failed to resolve in strict mode are collected for the latest definition only (see unresolvedPlaceholdersError)
 func (tpl *Tpl) applyTemplatesWithMarshalling(out interface{}) error {
+	tpl.failures = nil
 	reflectedVal := reflect.ValueOf(out).Elem()
 	appliedResult := tpl.applyTemplates(out)
 	val := reflect.ValueOf(appliedResult).Elem()
@@ -358,13 +461,13 @@ func (tpl *Tpl) applyTemplates(obj interface{}) interface{} {
 	original := reflect.ValueOf(obj)
 
 	res := reflect.New(original.Type()).Elem()
-	tpl.applyTemplatesRecursive(res, original)
+	tpl.applyTemplatesRecursive(res, original, "")
 
 	// Remove the reflection wrapper
 	return res.Interface()
 }
 
-func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
+func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value, field string) {
 	switch original.Kind() {
 	// The first cases handle nested structures and translate them recursively
 
@@ -381,7 +484,7 @@ func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
 		// Allocate a new object and set the pointer to it
 		copy.Set(reflect.New(originalValue.Type()))
 		// Unwrap the newly created pointer
-		tpl.applyTemplatesRecursive(copy.Elem(), originalValue)
+		tpl.applyTemplatesRecursive(copy.Elem(), originalValue, field)
 
 	// If it is an interface (which is very similar to a pointer), do basically the
 	// same as for the pointer. Though a pointer is not the same as an interface so
@@ -393,20 +496,20 @@ func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
 		// Create a new object. Now new gives us a pointer, but we want the value it
 		// points to, so we have to call Elem() to unwrap it
 		copyValue := reflect.New(originalValue.Type()).Elem()
-		tpl.applyTemplatesRecursive(copyValue, originalValue)
+		tpl.applyTemplatesRecursive(copyValue, originalValue, field)
 		copy.Set(copyValue)
 
 	// If it is a struct we translate each field
 	case reflect.Struct:
 		for i := 0; i < original.NumField(); i += 1 {
-			tpl.applyTemplatesRecursive(copy.Field(i), original.Field(i))
+			tpl.applyTemplatesRecursive(copy.Field(i), original.Field(i), subField(field, yamlFieldName(original.Type().Field(i))))
 		}
 
 	// If it is a slice we create a new slice and translate each element
 	case reflect.Slice:
 		copy.Set(reflect.MakeSlice(original.Type(), original.Len(), original.Cap()))
 		for i := 0; i < original.Len(); i += 1 {
-			tpl.applyTemplatesRecursive(copy.Index(i), original.Index(i))
+			tpl.applyTemplatesRecursive(copy.Index(i), original.Index(i), fmt.Sprintf("%s[%d]", field, i))
 		}
 
 	// If it is a map we create a new map and translate each value
@@ -416,7 +519,7 @@ func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
 			originalValue := original.MapIndex(key)
 			// New gives us a pointer, but again we want the value
 			copyValue := reflect.New(originalValue.Type()).Elem()
-			tpl.applyTemplatesRecursive(copyValue, originalValue)
+			tpl.applyTemplatesRecursive(copyValue, originalValue, subField(field, fmt.Sprint(key.Interface())))
 			copy.SetMapIndex(key, copyValue)
 		}
 
@@ -424,15 +527,8 @@ func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
 
 	// If it is a string translate it (yay finally we're doing what we came for)
 	case reflect.String:
-		var processed string
-		originalVal := original.Interface()
-		if _, ok := originalVal.(string); ok {
-			processed = tpl.copyNonStrict().applyTemplate(originalVal.(string))
-		} else if _, ok := originalVal.(types.StringValue); ok {
-			processed = tpl.copyNonStrict().applyTemplate(string(originalVal.(types.StringValue)))
-		} else {
-			processed = tpl.copyNonStrict().applyTemplate(string(originalVal.(types.RunOnType)))
-		}
+		// string-based types (e.g. StringValue, RunOnType, ImageBuilderType) are processed as plain strings
+		processed := tpl.applyFieldTemplate(field, original.String())
 		copy.SetString(processed)
 
 	// And everything else will simply be taken from the original
@@ -441,6 +537,25 @@ func (tpl *Tpl) applyTemplatesRecursive(copy, original reflect.Value) {
 	}
 }
 
+// yamlFieldName returns name of the struct field as it is specified in welder.yaml (empty for inlined fields)
+func yamlFieldName(field reflect.StructField) string {
+	name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if strings.Contains(opts, "inline") {
+		return ""
+	}
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func subField(field string, name string) string {
+	if field == "" || name == "" {
+		return field + name
+	}
+	return field + "." + name
+}
+
 func (tpl *Tpl) ActiveModuleName() string {
 	if tpl.module != nil {
 		return tpl.module.Name
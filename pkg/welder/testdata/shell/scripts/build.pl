@@ -0,0 +1,4 @@
+#!/usr/bin/env perl
+my @words = ('perl', 'script', 'file');
+open(my $fh, '>', 'output');
+print $fh join(' ', @words) . "\n";
@@ -0,0 +1,3 @@
+#!/bin/bash
+words=(script file)
+echo "${words[*]}" > output
@@ -44,10 +44,9 @@ func DetectBuildContext(rootDir string) (string, string, error) {
 	} else {
 		cwd = rootDir
 	}
-	_, err := os.Stat(filepath.Join(rootDir, BuildConfigFileName))
-	for os.IsNotExist(err) && filepath.Dir(rootDir) != "/" {
+	// welder.yaml files of modules (without schemaVersion) are skipped, since they are merged into the root one
+	for !isRootBuildConfig(rootDir) && filepath.Dir(rootDir) != "/" {
 		rootDir = filepath.Dir(rootDir)
-		_, err = os.Stat(filepath.Join(rootDir, BuildConfigFileName))
 	}
 	if filepath.Dir(rootDir) == "/" {
 		return "", "", fmt.Errorf("could not determine project root, make sure you're in the project context. Current dir: %s", cwd)
@@ -130,6 +129,10 @@ func ReadBuildRootDefinition(basePath string) (RootBuildDefinition, error) {
 		return rb, err
 	}
 
+	if err := rb.readIncludesAndFragments(basePath, yamlFile); err != nil {
+		return RootBuildDefinition{}, err
+	}
+
 	rb.rootDir = basePath
 	rb.initCaches()
 	return rb, nil
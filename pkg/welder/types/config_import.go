@@ -0,0 +1,292 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/simple-container-com/welder/pkg/git"
+)
+
+const (
+	ImportsLockFileName   = "welder.lock"
+	importsCacheDirName   = "git"
+	importNamespaceSep    = "/"
+	defaultImportFilePath = BuildConfigFileName
+)
+
+var (
+	// resolvedImports memoizes commits that refs of imports resolve to (by git and ref), so that repositories of
+	// imports that are not locked are fetched once per process however many times the definition is read
+	resolvedImports sync.Map
+	// importsOffline forbids fetching repositories of imports
+	importsOffline atomic.Bool
+)
+
+// SetImportsOffline forbids (or allows) fetching repositories of imports, so that reading definition fails if imports
+// are not locked or locked commits are not in the cache
+func SetImportsOffline(offline bool) {
+	importsOffline.Store(offline)
+}
+
+// ImportDefinition defines library of tasks and profiles imported from git repository
+type ImportDefinition struct {
+	Git  string `yaml:"git" json:"git" jsonschema:"title=URL of git repository,example=git@bitbucket.org:team/welder-tasks.git"`
+	Ref  string `yaml:"ref" json:"ref" jsonschema:"title=Tag, branch or commit to import,example=v1.0.0"`
+	Path string `yaml:"path,omitempty" json:"path,omitempty" jsonschema:"title=Path to the file within repository,default=welder.yaml"`
+	As   string `yaml:"as,omitempty" json:"as,omitempty" jsonschema:"title=Namespace of imported tasks and profiles (name of the repository by default),example=lib"`
+}
+
+// ImportsLockDefinition defines contents of the lock file recording resolved commits of imports
+type ImportsLockDefinition struct {
+	Imports []LockedImportDefinition `yaml:"imports" json:"imports"`
+}
+
+// LockedImportDefinition defines commit resolved for the ref of imported repository
+type LockedImportDefinition struct {
+	Git    string `yaml:"git" json:"git"`
+	Ref    string `yaml:"ref" json:"ref"`
+	Commit string `yaml:"commit" json:"commit"`
+}
+
+// Namespace returns prefix for the names of imported tasks and profiles
+func (imp ImportDefinition) Namespace() string {
+	if imp.As != "" {
+		return imp.As
+	}
+	return strings.TrimSuffix(filepath.Base(strings.TrimRight(imp.Git, "/")), ".git")
+}
+
+// FilePath returns path to the file to import within repository
+func (imp ImportDefinition) FilePath() string {
+	if imp.Path != "" {
+		return imp.Path
+	}
+	return defaultImportFilePath
+}
+
+// resolvedKey returns key of the commit resolved for the import in resolvedImports
+func (imp ImportDefinition) resolvedKey() string {
+	return imp.Git + "@" + imp.Ref
+}
+
+// lockedCommit returns commit recorded in the lock file for the import
+func (lock *ImportsLockDefinition) lockedCommit(imp ImportDefinition) (string, bool) {
+	for _, locked := range lock.Imports {
+		if locked.Git == imp.Git && locked.Ref == imp.Ref {
+			return locked.Commit, true
+		}
+	}
+	return "", false
+}
+
+// readImportsLock reads lock file of the project (returns empty lock if file does not exist)
+func readImportsLock(basePath string) (ImportsLockDefinition, error) {
+	var res ImportsLockDefinition
+	lockPath := filepath.Join(basePath, ImportsLockFileName)
+	content, err := os.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return res, errors.Wrapf(err, "failed to read %s", ImportsLockFileName)
+	}
+	if err := yaml.UnmarshalStrict(content, &res); err != nil {
+		return res, errors.Wrapf(err, "failed to read %s", ImportsLockFileName)
+	}
+	return res, nil
+}
+
+// readImports fetches imported repositories and merges namespaced tasks and profiles into the root definition
+// commits recorded in the lock file are used for locked imports, refs of other imports are resolved once per process
+// (lock file is only written by LockImports)
+func (root *RootBuildDefinition) readImports(basePath string, rootContent []byte, origins definitionOrigins) error {
+	if len(root.Imports) == 0 {
+		return nil
+	}
+	lock, err := readImportsLock(basePath)
+	if err != nil {
+		return err
+	}
+	cacheDir, err := CacheDirPath()
+	if err != nil {
+		return err
+	}
+	namespaces := make(map[string]string)
+	for idx, imp := range root.Imports {
+		origin := fmt.Sprintf("imports[%d] (%s)", idx, fileLine(BuildConfigFileName, rootContent, "imports"))
+		namespace := imp.Namespace()
+		if namespace == "" || strings.Contains(namespace, importNamespaceSep) {
+			return errors.Errorf("invalid namespace %q of %s", namespace, origin)
+		}
+		if prev, exists := namespaces[namespace]; exists {
+			return errors.Errorf("namespace %q of %s is already used by %s, specify another one with 'as'", namespace, origin, prev)
+		}
+		namespaces[namespace] = origin
+
+		if _, locked := lock.lockedCommit(imp); !locked {
+			if importsOffline.Load() {
+				return errors.Errorf("%s is not locked and cannot be resolved in offline mode, run `welder imports lock` to record its commit in %s",
+					origin, ImportsLockFileName)
+			}
+			if _, resolved := resolvedImports.Load(imp.resolvedKey()); !resolved {
+				_, _ = fmt.Fprintln(os.Stderr, color.YellowString("WARN: %s is not locked, run `welder imports lock` to record its commit in %s",
+					origin, ImportsLockFileName))
+			}
+		}
+		remote := git.NewRemoteCache(filepath.Join(cacheDir, importsCacheDirName), imp.Git)
+		commit, err := resolveImport(remote, imp, origin, lock, false)
+		if err != nil {
+			return err
+		}
+		content, err := remote.ReadFile(commit, imp.FilePath())
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", origin)
+		}
+		fileName := fmt.Sprintf("%s@%s:%s", imp.Git, imp.Ref, imp.FilePath())
+		var lib IncludeDefinition
+		if err := yaml.UnmarshalStrict(content, &lib); err != nil {
+			return errors.Wrapf(err, "failed to read %s", fileName)
+		}
+		if err := root.mergeIncludeDefinition(namespacedInclude(lib, namespace), fileName, content, origins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockImports records commits resolved for refs of imports into the lock file of the project
+// commits already recorded are kept unless update is true (refs of all imports are resolved again then)
+func LockImports(basePath string, update bool) (ImportsLockDefinition, error) {
+	newLock := ImportsLockDefinition{}
+	yamlFilePath := filepath.Join(basePath, BuildConfigFileName)
+	rootContent, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		return newLock, errors.Wrapf(err, "failed to read %s", yamlFilePath)
+	}
+	var root RootBuildDefinition
+	if err := yaml.Unmarshal(rootContent, &root); err != nil {
+		return newLock, errors.Wrapf(err, "failed to read %s", yamlFilePath)
+	}
+	lock, err := readImportsLock(basePath)
+	if err != nil {
+		return newLock, err
+	}
+	cacheDir, err := CacheDirPath()
+	if err != nil {
+		return newLock, err
+	}
+	newLock.Imports = make([]LockedImportDefinition, 0, len(root.Imports))
+	for idx, imp := range root.Imports {
+		origin := fmt.Sprintf("imports[%d] (%s)", idx, fileLine(BuildConfigFileName, rootContent, "imports"))
+		remote := git.NewRemoteCache(filepath.Join(cacheDir, importsCacheDirName), imp.Git)
+		commit, err := resolveImport(remote, imp, origin, lock, update)
+		if err != nil {
+			return newLock, err
+		}
+		newLock.Imports = append(newLock.Imports, LockedImportDefinition{Git: imp.Git, Ref: imp.Ref, Commit: commit})
+	}
+	if !reflect.DeepEqual(lock, newLock) {
+		if err := WriteYaml(newLock, filepath.Join(basePath, ImportsLockFileName)); err != nil {
+			return newLock, errors.Wrapf(err, "failed to write %s", ImportsLockFileName)
+		}
+	}
+	return newLock, nil
+}
+
+// resolveImport returns commit to import (either locked one or the one ref resolves to), fetches repository if needed
+func resolveImport(remote *git.RemoteCache, imp ImportDefinition, origin string, lock ImportsLockDefinition, ignoreLock bool) (string, error) {
+	if imp.Git == "" || imp.Ref == "" {
+		return "", errors.Errorf("git and ref must be specified for %s", origin)
+	}
+	fetch := func() error {
+		if importsOffline.Load() {
+			return errors.Errorf("cannot fetch %s in offline mode", origin)
+		}
+		return errors.Wrapf(remote.Fetch(), "failed to fetch %s", origin)
+	}
+	if commit, locked := lock.lockedCommit(imp); locked && !ignoreLock {
+		if !remote.HasCommit(commit) {
+			if err := fetch(); err != nil {
+				return "", err
+			}
+		}
+		return commit, nil
+	}
+	if commit, resolved := resolvedImports.Load(imp.resolvedKey()); resolved && !ignoreLock {
+		return commit.(string), nil
+	}
+	if err := fetch(); err != nil {
+		return "", err
+	}
+	commit, err := remote.Resolve(imp.Ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve ref of %s", origin)
+	}
+	resolvedImports.Store(imp.resolvedKey(), commit)
+	return commit, nil
+}
+
+// namespacedInclude prefixes names of tasks and profiles with the namespace,
+// references to the tasks of the library (steps invoking tasks and tasks run after Docker build) are prefixed as well
+func namespacedInclude(lib IncludeDefinition, namespace string) IncludeDefinition {
+	res := IncludeDefinition{Tasks: make(TasksDefinition), Profiles: make(ProfilesDefinition)}
+	taskRef := func(name string) string {
+		if _, ok := lib.Tasks[name]; ok {
+			return namespace + importNamespaceSep + name
+		}
+		return name
+	}
+	for name, task := range lib.Tasks {
+		task.CustomImage.RunAfterBuild = namespacedRunAfterBuild(task.CustomImage.RunAfterBuild, taskRef)
+		res.Tasks[namespace+importNamespaceSep+name] = task
+	}
+	for name, profile := range lib.Profiles {
+		profile.Build.Steps = namespacedSteps(profile.Build.Steps, taskRef)
+		profile.Deploy.Steps = namespacedSteps(profile.Deploy.Steps, taskRef)
+		dockerImages := make([]DockerImageDefinition, 0, len(profile.DockerImages))
+		for _, dockerImage := range profile.DockerImages {
+			dockerImage.RunAfterBuild = namespacedRunAfterBuild(dockerImage.RunAfterBuild, taskRef)
+			dockerImages = append(dockerImages, dockerImage)
+		}
+		if profile.DockerImages != nil {
+			profile.DockerImages = dockerImages
+		}
+		res.Profiles[namespace+importNamespaceSep+name] = profile
+	}
+	return res
+}
+
+func namespacedSteps(steps []StepsDefinition, taskRef func(string) string) []StepsDefinition {
+	if steps == nil {
+		return nil
+	}
+	res := make([]StepsDefinition, 0, len(steps))
+	for _, step := range steps {
+		if step.Task != "" {
+			step.Task = taskRef(step.Task)
+		}
+		step.Step.CustomImage.RunAfterBuild = namespacedRunAfterBuild(step.Step.CustomImage.RunAfterBuild, taskRef)
+		res = append(res, step)
+	}
+	return res
+}
+
+func namespacedRunAfterBuild(runAfterBuild RunAfterStepDefinition, taskRef func(string) string) RunAfterStepDefinition {
+	if runAfterBuild.Tasks == nil {
+		return runAfterBuild
+	}
+	tasks := make([]string, 0, len(runAfterBuild.Tasks))
+	for _, task := range runAfterBuild.Tasks {
+		tasks = append(tasks, taskRef(task))
+	}
+	runAfterBuild.Tasks = tasks
+	return runAfterBuild
+}
@@ -0,0 +1,151 @@
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestBuildRootSpecWithImports(t *testing.T) {
+	RegisterTestingT(t)
+
+	tmpDir := t.TempDir()
+	t.Setenv(CacheDirPathEnv, filepath.Join(tmpDir, "cache"))
+
+	// library repository is pushed into local bare repository acting as a remote
+	remoteDir := filepath.Join(tmpDir, "welder-tasks.git")
+	_, err := git.PlainInit(remoteDir, true)
+	Expect(err).To(BeNil())
+	libDir := filepath.Join(tmpDir, "lib")
+	lib, err := git.PlainInit(libDir, false)
+	Expect(err).To(BeNil())
+	_, err = lib.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}})
+	Expect(err).To(BeNil())
+	commitLib := func(greeting string) string {
+		Expect(os.WriteFile(filepath.Join(libDir, "tasks.yaml"), []byte(`
+tasks:
+  greet:
+    image: alpine:latest
+    script:
+      - echo `+greeting+`
+profiles:
+  ci:
+    build:
+      env:
+        CI: "true"
+      steps:
+        - task: greet
+        - task: lint
+    dockerImages:
+      - name: app
+        dockerFile: Dockerfile
+        runAfterBuild:
+          tasks:
+            - greet
+            - lint
+`), 0o644)).To(BeNil())
+		wt, err := lib.Worktree()
+		Expect(err).To(BeNil())
+		_, err = wt.Add("tasks.yaml")
+		Expect(err).To(BeNil())
+		hash, err := wt.Commit("update "+greeting, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		Expect(err).To(BeNil())
+		Expect(lib.Push(&git.PushOptions{RemoteName: "origin", RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"}})).To(BeNil())
+		return hash.String()
+	}
+	firstCommit := commitLib("hello")
+
+	projectDir := filepath.Join(tmpDir, "project")
+	Expect(os.MkdirAll(projectDir, 0o755)).To(BeNil())
+	Expect(os.WriteFile(filepath.Join(projectDir, BuildConfigFileName), []byte(`schemaVersion: "1.0.1"
+projectName: imports
+imports:
+  - git: `+remoteDir+`
+    ref: master
+    path: tasks.yaml
+    as: lib
+tasks:
+  greet:
+    image: alpine:latest
+    script:
+      - echo local
+`), 0o644)).To(BeNil())
+
+	sut, err := ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+	Expect(sut.Tasks).To(HaveKey("greet"))
+	Expect(sut.Tasks).To(HaveKey("lib/greet"))
+	Expect(sut.Tasks["lib/greet"].Scripts).To(Equal([]string{"echo hello"}))
+	Expect(sut.Profiles["lib/ci"].Build.Env["CI"]).To(Equal(StringValue("true")))
+
+	// references to the tasks of the library are namespaced, references to other tasks are kept as is
+	Expect(sut.Profiles["lib/ci"].Build.Steps[0].Task).To(Equal("lib/greet"))
+	Expect(sut.Profiles["lib/ci"].Build.Steps[1].Task).To(Equal("lint"))
+	Expect(sut.Profiles["lib/ci"].DockerImages[0].RunAfterBuild.Tasks).To(Equal([]string{"lib/greet", "lint"}))
+
+	// reading the definition doesn't write lock file
+	lockFile := filepath.Join(projectDir, ImportsLockFileName)
+	Expect(lockFile).NotTo(BeAnExistingFile())
+
+	lock, err := LockImports(projectDir, false)
+	Expect(err).To(BeNil())
+	Expect(lock.Imports).To(Equal([]LockedImportDefinition{{Git: remoteDir, Ref: "master", Commit: firstCommit}}))
+	Expect(readImportsLock(lockFile)).To(Equal(lock))
+
+	// locked commit is used even if ref has moved
+	secondCommit := commitLib("bye")
+	sut, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+	Expect(sut.Tasks["lib/greet"].Scripts).To(Equal([]string{"echo hello"}))
+	lock, err = LockImports(projectDir, false)
+	Expect(err).To(BeNil())
+	Expect(lock.Imports[0].Commit).To(Equal(firstCommit))
+
+	// ref is resolved again on update
+	lock, err = LockImports(projectDir, true)
+	Expect(err).To(BeNil())
+	Expect(lock.Imports[0].Commit).To(Equal(secondCommit))
+	Expect(readImportsLock(lockFile)).To(Equal(lock))
+	sut, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+	Expect(sut.Tasks["lib/greet"].Scripts).To(Equal([]string{"echo bye"}))
+
+	// locked imports are read in offline mode
+	SetImportsOffline(true)
+	defer SetImportsOffline(false)
+	_, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+
+	// import that is not locked can't be resolved in offline mode
+	Expect(os.Remove(lockFile)).To(BeNil())
+	_, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("run `welder imports lock`"))
+
+	// ref is resolved once per process if import is not locked
+	SetImportsOffline(false)
+	commitLib("hi")
+	sut, err = ReadBuildRootDefinition(projectDir)
+	Expect(err).To(BeNil())
+	Expect(sut.Tasks["lib/greet"].Scripts).To(Equal([]string{"echo bye"}))
+	Expect(lockFile).NotTo(BeAnExistingFile())
+}
+
+func readImportsLock(lockFile string) ImportsLockDefinition {
+	var res ImportsLockDefinition
+	content, err := os.ReadFile(lockFile)
+	Expect(err).To(BeNil())
+	Expect(yaml.Unmarshal(content, &res)).To(BeNil())
+	return res
+}
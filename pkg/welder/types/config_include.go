@@ -0,0 +1,240 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// IncludeDefinition defines contents of the file included into the root definition via `include`
+type IncludeDefinition struct {
+	Tasks    TasksDefinition    `yaml:"tasks,omitempty" json:"tasks,omitempty" jsonschema:"title=Tasks to add to the project"`
+	Profiles ProfilesDefinition `yaml:"profiles,omitempty" json:"profiles,omitempty" jsonschema:"title=Profiles to add to the project"`
+}
+
+// ModuleFragmentDefinition defines contents of welder.yaml within module directory (file without schemaVersion)
+type ModuleFragmentDefinition struct {
+	Version               string `yaml:"version,omitempty" json:"version,omitempty"`
+	BasicModuleDefinition `yaml:",inline"`
+	Tasks                 TasksDefinition `yaml:"tasks,omitempty" json:"tasks,omitempty" jsonschema:"title=Tasks to add to the project"`
+}
+
+// definitionOrigins keeps track of files (and lines) where named definitions came from
+type definitionOrigins map[string]string
+
+func (o definitionOrigins) add(kind string, name string, origin string) error {
+	key := kind + ":" + name
+	if prev, exists := o[key]; exists {
+		return errors.Errorf("%s %q defined in %s is already defined in %s", kind, name, origin, prev)
+	}
+	o[key] = origin
+	return nil
+}
+
+// isRootBuildConfig returns true if welder.yaml exists in the directory and it is not a module fragment
+func isRootBuildConfig(dir string) bool {
+	content, err := os.ReadFile(filepath.Join(dir, BuildConfigFileName))
+	if err != nil {
+		return false
+	}
+	var vd VersionedDefinition
+	if err := yaml.Unmarshal(content, &vd); err != nil {
+		return true // let reading the definition report the error
+	}
+	return vd.SchemaVersion != ""
+}
+
+// readIncludesAndFragments merges included files and module fragments into the root definition
+func (root *RootBuildDefinition) readIncludesAndFragments(basePath string, rootContent []byte) error {
+	origins := make(definitionOrigins)
+	rootFile := BuildConfigFileName
+	for name := range root.Tasks {
+		_ = origins.add("task", name, fileLine(rootFile, rootContent, "tasks", name))
+	}
+	for name := range root.Profiles {
+		_ = origins.add("profile", name, fileLine(rootFile, rootContent, "profiles", name))
+	}
+
+	if err := root.readImports(basePath, rootContent, origins); err != nil {
+		return err
+	}
+
+	for _, include := range root.Include {
+		files, err := filepath.Glob(filepath.Join(basePath, include))
+		if err != nil {
+			return errors.Wrapf(err, "invalid include pattern %q in %s", include, rootFile)
+		}
+		if len(files) == 0 {
+			return errors.Errorf("included file %q does not exist (%s)", include, fileLine(rootFile, rootContent, "include"))
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if err := root.mergeInclude(basePath, file, origins); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range root.Modules {
+		module := &root.Modules[i]
+		moduleDir := filepath.Join(basePath, module.Path)
+		if module.Path == "" || filepath.Clean(moduleDir) == filepath.Clean(basePath) {
+			continue
+		}
+		fragmentFile := filepath.Join(moduleDir, BuildConfigFileName)
+		if _, err := os.Stat(fragmentFile); os.IsNotExist(err) {
+			continue
+		}
+		if isRootBuildConfig(moduleDir) {
+			return errors.Errorf("%s of module %q must not specify schemaVersion to be merged into the root definition",
+				relPath(basePath, fragmentFile), module.Name)
+		}
+		if err := root.mergeModuleFragment(basePath, fragmentFile, module, origins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (root *RootBuildDefinition) mergeInclude(basePath string, file string, origins definitionOrigins) error {
+	fileName := relPath(basePath, file)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read included file %s", fileName)
+	}
+	var include IncludeDefinition
+	if err := yaml.UnmarshalStrict(content, &include); err != nil {
+		return errors.Wrapf(err, "failed to read included file %s", fileName)
+	}
+	return root.mergeIncludeDefinition(include, fileName, content, origins)
+}
+
+func (root *RootBuildDefinition) mergeIncludeDefinition(include IncludeDefinition, fileName string, content []byte, origins definitionOrigins) error {
+	if err := root.mergeTasks(include.Tasks, fileName, content, origins); err != nil {
+		return err
+	}
+	if root.Profiles == nil && len(include.Profiles) > 0 {
+		root.Profiles = make(ProfilesDefinition)
+	}
+	profileNames := make([]string, 0, len(include.Profiles))
+	for name := range include.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		if err := origins.add("profile", name, fileLine(fileName, content, "profiles", name)); err != nil {
+			return err
+		}
+		root.Profiles[name] = include.Profiles[name]
+	}
+	return nil
+}
+
+func (root *RootBuildDefinition) mergeModuleFragment(basePath string, file string, module *ModuleDefinition, origins definitionOrigins) error {
+	fileName := relPath(basePath, file)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read module file %s", fileName)
+	}
+	var fragment ModuleFragmentDefinition
+	if err := yaml.UnmarshalStrict(content, &fragment); err != nil {
+		return errors.Wrapf(err, "failed to read module file %s", fileName)
+	}
+	sections := []struct {
+		key       string
+		target    interface{}
+		value     interface{}
+		isDefined bool
+	}{
+		{"version", &module.Version, fragment.Version, fragment.Version != ""},
+		{"build", &module.Build, fragment.Build, !reflect.ValueOf(fragment.Build).IsZero()},
+		{"deploy", &module.Deploy, fragment.Deploy, !reflect.ValueOf(fragment.Deploy).IsZero()},
+		{"dockerImages", &module.DockerImages, fragment.DockerImages, len(fragment.DockerImages) > 0},
+	}
+	for _, section := range sections {
+		if !section.isDefined {
+			continue
+		}
+		target := reflect.ValueOf(section.target).Elem()
+		if !target.IsZero() {
+			return errors.Errorf("%s of module %q defined in %s is already defined in %s",
+				section.key, module.Name, fileLine(fileName, content, section.key), BuildConfigFileName)
+		}
+		target.Set(reflect.ValueOf(section.value))
+	}
+	if fragment.Version != "" {
+		if root.moduleVersionFiles == nil {
+			root.moduleVersionFiles = make(map[string]string)
+		}
+		root.moduleVersionFiles[module.Name] = file
+	}
+	return root.mergeTasks(fragment.Tasks, fileName, content, origins)
+}
+
+func (root *RootBuildDefinition) mergeTasks(tasks TasksDefinition, fileName string, content []byte, origins definitionOrigins) error {
+	if root.Tasks == nil && len(tasks) > 0 {
+		root.Tasks = make(TasksDefinition)
+	}
+	taskNames := make([]string, 0, len(tasks))
+	for name := range tasks {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+	for _, name := range taskNames {
+		if err := origins.add("task", name, fileLine(fileName, content, "tasks", name)); err != nil {
+			return err
+		}
+		root.Tasks[name] = tasks[name]
+	}
+	return nil
+}
+
+// fileLine returns reference to the file and line where the key by provided path is defined
+func fileLine(fileName string, content []byte, keyPath ...string) string {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return fileName
+	}
+	node := doc.Content[0]
+	line := 0
+	for _, key := range keyPath {
+		if node.Kind != yamlv3.MappingNode {
+			break
+		}
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line = node.Content[i].Line
+				node = node.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if line == 0 {
+		return fileName
+	}
+	return fmt.Sprintf("%s:%d", fileName, line)
+}
+
+func relPath(basePath string, file string) string {
+	if rel, err := filepath.Rel(basePath, file); err == nil {
+		return rel
+	}
+	return file
+}
+
+// ModuleVersionFile returns path to the module file defining version of the module (if version is not in the root file)
+func (root *RootBuildDefinition) ModuleVersionFile(moduleName string) (string, bool) {
+	file, ok := root.moduleVersionFiles[moduleName]
+	return file, ok
+}
@@ -118,3 +118,36 @@ func TestBuildRootSpecForMoreRecentVersion(t *testing.T) {
 	Expect(err.Error()).To(ContainSubstring("more recent version of welder"))
 	Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("welder (99.0.0), current version: %s", RootBuildDefinitionSchemaVersion)))
 }
+
+func TestBuildRootSpecWithIncludesAndModuleFiles(t *testing.T) {
+	RegisterTestingT(t)
+
+	cwd, err := os.Getwd()
+	Expect(err).To(BeNil())
+	apiDir := filepath.Join(cwd, "testdata", "include", "services", "api")
+
+	module, sut, err := ReadBuildModuleDefinition(apiDir)
+	Expect(err).To(BeNil())
+	Expect(module).NotTo(BeNil())
+	Expect(module.Name).To(Equal("api"))
+	Expect(module.Version).To(Equal("1.2.3"))
+	Expect(module.Build.Steps[0].Step.Image).To(Equal("golang:latest"))
+	Expect(sut.Tasks).To(HaveKey("hello"))
+	Expect(sut.Tasks).To(HaveKey("lint"))
+	Expect(sut.Tasks).To(HaveKey("api-migrate"))
+	Expect(sut.Profiles["skip-tests"].Build.Env["SKIP_TESTS"]).To(Equal(StringValue("true")))
+
+	versionFile, ok := sut.ModuleVersionFile("api")
+	Expect(ok).To(BeTrue())
+	Expect(versionFile).To(Equal(filepath.Join(apiDir, BuildConfigFileName)))
+	_, ok = sut.ModuleVersionFile("web")
+	Expect(ok).To(BeFalse())
+}
+
+func TestBuildRootSpecWithDuplicateTaskInModuleFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := ReadBuildRootDefinition("testdata/include-duplicate")
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal(`task "hello" defined in services/api/welder.yaml:8 is already defined in welder.yaml:7`))
+}
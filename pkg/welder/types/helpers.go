@@ -8,6 +8,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 
@@ -151,6 +152,9 @@ func (rd *CommonSimpleRunDefinition) Init() *CommonSimpleRunDefinition {
 	if rd.InjectEnv == nil {
 		rd.InjectEnv = make([]string, 0)
 	}
+	if rd.EnvFile == nil {
+		rd.EnvFile = make([]string, 0)
+	}
 	return rd
 }
 
@@ -217,6 +221,16 @@ func (root *RootBuildDefinition) ProfileNames() []string {
 	return names
 }
 
+// TaskNames returns sorted list of defined task names
+func (root *RootBuildDefinition) TaskNames() []string {
+	var names []string
+	for name := range root.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ToBuildEnv converts environment variables to array of strings
 func (envs *BuildEnv) ToBuildEnv(localEnvFilters ...*regexp.Regexp) []string {
 	res := make([]string, len(*envs))
@@ -256,6 +270,14 @@ func (args BuildArgs) Copy() BuildArgs {
 	return cp
 }
 
+func (envs BuildEnv) Copy() BuildEnv {
+	cp := make(BuildEnv)
+	for k, v := range envs {
+		cp[k] = v
+	}
+	return cp
+}
+
 // UnmarshalYAML fix marshalling
 func (envs *BuildEnv) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type BuildEnv_ BuildEnv // prevent recursion
@@ -316,12 +338,15 @@ func NewCommonContext(ctx *CommonCtx, logger util.Logger) *CommonCtx {
 		parallelEg, goCtx = errgroup.WithContext(context.Background())
 		goCtx, cancelFunc = context.WithCancel(goCtx)
 	}
-	if ctx.subResolveContextDepth == nil {
-		ctx.subResolveContextDepth = &sync.Map{}
-	}
 	if ctx.executingTasks == nil {
 		ctx.executingTasks = &sync.Map{}
 	}
+	if ctx.extensionValues == nil {
+		ctx.extensionValues = &sync.Map{}
+	}
+	if ctx.registries == nil {
+		ctx.registries = &registriesHolder{}
+	}
 	newCommonCtx := CommonCtx{
 		Parallel:               ctx.Parallel,
 		ParallelCount:          ctx.ParallelCount,
@@ -335,6 +360,8 @@ func NewCommonContext(ctx *CommonCtx, logger util.Logger) *CommonCtx {
 		ReuseContainers:        ctx.ReuseContainers,
 		RemoveOrphans:          ctx.RemoveOrphans,
 		ForceOnHost:            ctx.ForceOnHost,
+		Offline:                ctx.Offline,
+		PullPolicy:             ctx.PullPolicy,
 		Username:               ctx.Username,
 		Verbose:                ctx.Verbose,
 		Strict:                 ctx.Strict,
@@ -349,15 +376,26 @@ func NewCommonContext(ctx *CommonCtx, logger util.Logger) *CommonCtx {
 		version:                ctx.version,
 		rootDir:                ctx.rootDir,
 		dockerImage:            ctx.dockerImage,
-		subResolveContextDepth: ctx.subResolveContextDepth,
+		subResolveContextChain: ctx.ResolvingChain(),
 		lastExecOutput:         ctx.lastExecOutput,
 		executingTasks:         ctx.executingTasks,
+		extensionValues:        ctx.extensionValues,
 		gitClient:              ctx.gitClient,
+		registries:             ctx.registries,
 	}
 	copy(newCommonCtx.Modules, ctx.Modules)
 	return &newCommonCtx
 }
 
+// NewDetachedCommonContext initializes new common context (a copy of the one provided) with its own Go context,
+// so it can run after parallel executions of the provided one are finished (e.g. deploy after build)
+func NewDetachedCommonContext(ctx *CommonCtx, logger util.Logger) *CommonCtx {
+	res := NewCommonContext(ctx, logger)
+	res.parallelEg, res.context = errgroup.WithContext(context.Background())
+	res.context, res.cancelFunc = context.WithCancel(res.context)
+	return res
+}
+
 // SetGitClient overwrites default git client
 func (commonCtx *CommonCtx) SetGitClient(gitClient git.Git) {
 	commonCtx.gitClient = gitClient
@@ -457,6 +495,17 @@ func AppendToListIfNotExist(target []string, values []string) []string {
 	return target
 }
 
+// PrependToListIfNotExist adds values that are not in the target yet before the values of the target
+func PrependToListIfNotExist(target []string, values []string) []string {
+	res := make([]string, 0, len(target)+len(values))
+	for _, value := range values {
+		if !util.SliceContains(target, value) && !util.SliceContains(res, value) {
+			res = append(res, value)
+		}
+	}
+	return append(res, target...)
+}
+
 func MergeRunDefinitions(from CommonRunDefinition, to *CommonRunDefinition, override bool) {
 	MergeSimpleRunDefinitions(from.CommonSimpleRunDefinition, &to.CommonSimpleRunDefinition, override)
 	if to.Args == nil {
@@ -468,6 +517,7 @@ func MergeRunDefinitions(from CommonRunDefinition, to *CommonRunDefinition, over
 func MergeSimpleRunDefinitions(from CommonSimpleRunDefinition, to *CommonSimpleRunDefinition, override bool) {
 	to.Volumes = AppendToListIfNotExist(to.Volumes, from.Volumes)
 	to.InjectEnv = AppendToListIfNotExist(to.InjectEnv, from.InjectEnv)
+	to.EnvFile = PrependToListIfNotExist(to.EnvFile, from.EnvFile)
 	if override || (to.ContainerWorkDir == "" && from.ContainerWorkDir != "") {
 		to.ContainerWorkDir = from.ContainerWorkDir
 	}
@@ -0,0 +1,294 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/simple-container-com/welder/pkg/util/yamledit"
+)
+
+// SchemaMigration upgrades welder.yaml document made for the previous schema version to the Version
+type SchemaMigration struct {
+	Version     string
+	Description string
+	// Migrate modifies root mapping node of the document and returns descriptions of the changes made
+	Migrate func(root *yamlv3.Node) []string
+}
+
+// MigrationResult defines outcome of the migration of a single file
+type MigrationResult struct {
+	File        string
+	FromVersion string
+	ToVersion   string
+	Migrated    bool
+	Changes     []string
+}
+
+// schemaMigrations lists all registered migrations (applied in the order of their versions)
+var schemaMigrations = []SchemaMigration{
+	{
+		Version:     "1.9.0",
+		Description: "drop deprecated Bamboo mode",
+		Migrate: func(root *yamlv3.Node) []string {
+			return append(migrateBambooActivation(root), migrateBambooFlag(root)...)
+		},
+	},
+}
+
+var bambooFlagRegexp = regexp.MustCompile(`\s--bamboo(=\S*)?(\s|$)`)
+
+// MigrateBuildDefinition upgrades welder.yaml from the specified directory along with its included files and module
+// fragments to the current schema version, result of the root file goes first
+// changes are not written when dryRun is true
+func MigrateBuildDefinition(basePath string, dryRun bool) ([]MigrationResult, error) {
+	filePath := filepath.Join(basePath, BuildConfigFileName)
+	res := MigrationResult{File: filePath, ToVersion: RootBuildDefinitionSchemaVersion}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filePath)
+	}
+	if err := checkSupportedSchemaVersion(filePath, content); err != nil {
+		return nil, err
+	}
+	var root RootBuildDefinition
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	res.FromVersion = root.SchemaVersion
+	loadingVersion, err := semver.NewVersion(root.SchemaVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse schema version: %q", root.SchemaVersion)
+	}
+	currentVersion, err := semver.NewVersion(RootBuildDefinitionSchemaVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse root build schema version: %q", RootBuildDefinitionSchemaVersion)
+	}
+	if !loadingVersion.LessThan(currentVersion) {
+		return []MigrationResult{res}, nil
+	}
+	migrations, err := pendingMigrations(loadingVersion, currentVersion)
+	if err != nil {
+		return nil, err
+	}
+	res.Migrated = true
+
+	// included files and module fragments don't declare schema version, so they are migrated along with the root file
+	// (before it, so that failed migration can be retried)
+	files, err := root.configFragmentFiles(basePath)
+	if err != nil {
+		return nil, err
+	}
+	results := []MigrationResult{res}
+	for _, file := range files {
+		fileRes := MigrationResult{File: file, FromVersion: res.FromVersion, ToVersion: res.ToVersion}
+		if fileRes.Changes, err = migrateFile(file, migrations, false, dryRun); err != nil {
+			return nil, err
+		}
+		fileRes.Migrated = len(fileRes.Changes) > 0
+		results = append(results, fileRes)
+	}
+	if results[0].Changes, err = migrateFile(filePath, migrations, true, dryRun); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// configFragmentFiles returns included files and module fragments merged into the root definition
+func (root *RootBuildDefinition) configFragmentFiles(basePath string) ([]string, error) {
+	var res []string
+	for _, include := range root.Include {
+		files, err := filepath.Glob(filepath.Join(basePath, include))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid include pattern %q in %s", include, BuildConfigFileName)
+		}
+		sort.Strings(files)
+		res = append(res, files...)
+	}
+	for _, module := range root.Modules {
+		moduleDir := filepath.Join(basePath, module.Path)
+		if module.Path == "" || filepath.Clean(moduleDir) == filepath.Clean(basePath) {
+			continue
+		}
+		fragmentFile := filepath.Join(moduleDir, BuildConfigFileName)
+		if _, err := os.Stat(fragmentFile); err == nil && !isRootBuildConfig(moduleDir) {
+			res = append(res, fragmentFile)
+		}
+	}
+	return res, nil
+}
+
+// migrateFile applies migrations to every document of the file and returns descriptions of the changes made
+// schema version of documents declaring it is updated only if setVersion is true (even if nothing else has changed)
+func migrateFile(filePath string, migrations []SchemaMigration, setVersion bool, dryRun bool) ([]string, error) {
+	var changes []string
+	migrate := func(doc *yamlv3.Node) (bool, error) {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+			return false, nil
+		}
+		root := doc.Content[0]
+		docChanges := 0
+		for _, m := range migrations {
+			for _, change := range m.Migrate(root) {
+				changes = append(changes, fmt.Sprintf("%s: %s", m.Version, change))
+				docChanges++
+			}
+		}
+		if setVersion && mappingValue(root, "schemaVersion") != nil {
+			setMappingValue(root, "schemaVersion", RootBuildDefinitionSchemaVersion)
+		}
+		return docChanges > 0, nil
+	}
+
+	if dryRun {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filePath)
+		}
+		decoder := yamlv3.NewDecoder(bytes.NewReader(content))
+		for {
+			var doc yamlv3.Node
+			if err := decoder.Decode(&doc); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s", filePath)
+			}
+			if _, err := migrate(&doc); err != nil {
+				return nil, err
+			}
+		}
+		return changes, nil
+	}
+
+	edit := yamledit.YamlEdit{WriteInPlace: true, SkipNotExisting: true}
+	if changed, err := edit.ModifyDocuments(filePath, migrate); err != nil {
+		return nil, errors.Wrapf(err, "failed to migrate %s", filePath)
+	} else if !changed && setVersion {
+		// nothing but version has changed, so just update it in place keeping the formatting
+		if err := edit.ModifyProperty(filePath, "schemaVersion", RootBuildDefinitionSchemaVersion); err != nil {
+			return nil, errors.Wrapf(err, "failed to update schema version of %s", filePath)
+		}
+	}
+	return changes, nil
+}
+
+// pendingMigrations returns migrations that need to be applied to upgrade the file from one schema version to another
+func pendingMigrations(loadingVersion *semver.Version, currentVersion *semver.Version) ([]SchemaMigration, error) {
+	var res []SchemaMigration
+	for _, m := range schemaMigrations {
+		version, err := semver.NewVersion(m.Version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse version of migration %q", m.Description)
+		}
+		if version.GreaterThan(loadingVersion) && !version.GreaterThan(currentVersion) {
+			res = append(res, m)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return semver.MustParse(res[i].Version).LessThan(semver.MustParse(res[j].Version))
+	})
+	return res, nil
+}
+
+// migrateBambooActivation replaces deprecated `activation.bamboo` of profiles with the `if` condition
+func migrateBambooActivation(root *yamlv3.Node) []string {
+	var changes []string
+	profiles := mappingValue(root, "profiles")
+	if profiles == nil || profiles.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		profileName, profile := profiles.Content[i].Value, profiles.Content[i+1]
+		activation := mappingValue(profile, "activation")
+		bamboo := mappingValue(activation, "bamboo")
+		if bamboo == nil {
+			continue
+		}
+		removeMappingKey(activation, "bamboo")
+		if bamboo.Value != "true" {
+			changes = append(changes, fmt.Sprintf("line %d: removed deprecated 'activation.bamboo' of profile %q",
+				bamboo.Line, profileName))
+			continue
+		}
+		condition := "${mode:bamboo}"
+		if existing := mappingValue(activation, "if"); existing != nil && existing.Value != "" {
+			condition = fmt.Sprintf("%s || (%s)", condition, existing.Value)
+		}
+		setMappingValue(activation, "if", condition)
+		changes = append(changes, fmt.Sprintf("line %d: replaced deprecated 'activation.bamboo' of profile %q with 'if: %s'",
+			bamboo.Line, profileName, condition))
+	}
+	return changes
+}
+
+// migrateBambooFlag removes deprecated `--bamboo` flag from welder invocations in scripts
+func migrateBambooFlag(node *yamlv3.Node) []string {
+	var changes []string
+	if node.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != "script" || node.Content[i+1].Kind != yamlv3.SequenceNode {
+				continue
+			}
+			for _, line := range node.Content[i+1].Content {
+				if line.Kind != yamlv3.ScalarNode || !strings.Contains(line.Value, "welder") {
+					continue
+				}
+				if migrated := bambooFlagRegexp.ReplaceAllString(line.Value, "$2"); migrated != line.Value {
+					line.Value = migrated
+					changes = append(changes, fmt.Sprintf("line %d: removed deprecated '--bamboo' flag from script", line.Line))
+				}
+			}
+		}
+	}
+	for _, child := range node.Content {
+		changes = append(changes, migrateBambooFlag(child)...)
+	}
+	return changes
+}
+
+// mappingValue returns value node of the mapping by key (nil if mapping doesn't have the key)
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets scalar value of the mapping by key keeping style of the existing value
+func setMappingValue(node *yamlv3.Node, key string, value string) {
+	if existing := mappingValue(node, key); existing != nil && existing.Kind == yamlv3.ScalarNode {
+		existing.Tag = "!!str"
+		existing.Value = value
+		return
+	}
+	removeMappingKey(node, key)
+	node.Content = append(node.Content,
+		&yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key},
+		&yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: value})
+}
+
+// removeMappingKey removes key and its value from the mapping
+func removeMappingKey(node *yamlv3.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestMigrateBuildDefinition(t *testing.T) {
+	RegisterTestingT(t)
+
+	original, err := os.ReadFile("testdata/migrate/welder.yaml")
+	Expect(err).To(BeNil())
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, BuildConfigFileName)
+	Expect(os.WriteFile(filePath, original, 0o644)).To(BeNil())
+
+	results, err := MigrateBuildDefinition(dir, true)
+	Expect(err).To(BeNil())
+	Expect(results).To(HaveLen(1))
+	res := results[0]
+	Expect(res.Migrated).To(BeTrue())
+	Expect(res.FromVersion).To(Equal("1.8.0"))
+	Expect(res.ToVersion).To(Equal(RootBuildDefinitionSchemaVersion))
+	Expect(res.Changes).To(Equal([]string{
+		`1.9.0: line 7: replaced deprecated 'activation.bamboo' of profile "ci" with 'if: ${mode:bamboo} || (${mode:pipelines})'`,
+		`1.9.0: line 11: replaced deprecated 'activation.bamboo' of profile "bamboo-only" with 'if: ${mode:bamboo}'`,
+		`1.9.0: line 14: removed deprecated 'activation.bamboo' of profile "never"`,
+		`1.9.0: line 23: removed deprecated '--bamboo' flag from script`,
+	}))
+	content, err := os.ReadFile(filePath)
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(Equal(string(original)))
+
+	results, err = MigrateBuildDefinition(dir, false)
+	Expect(err).To(BeNil())
+	Expect(results[0].Changes).To(HaveLen(4))
+	content, err = os.ReadFile(filePath)
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(ContainSubstring("schemaVersion: " + RootBuildDefinitionSchemaVersion))
+	Expect(string(content)).To(ContainSubstring("# activated on CI servers"))
+	Expect(string(content)).To(ContainSubstring("- welder make -m api # build the api module"))
+	Expect(string(content)).To(ContainSubstring(`echo "--bamboo is not a welder flag here"`))
+	Expect(string(content)).NotTo(ContainSubstring("bamboo: "))
+
+	root, err := ReadBuildRootDefinition(dir)
+	Expect(err).To(BeNil())
+	Expect(root.Profiles["ci"].Activation.If).To(Equal("${mode:bamboo} || (${mode:pipelines})"))
+	Expect(root.Profiles["bamboo-only"].Activation.If).To(Equal("${mode:bamboo}"))
+	Expect(root.Profiles["never"].Activation.Sox).To(BeTrue())
+
+	results, err = MigrateBuildDefinition(dir, false)
+	Expect(err).To(BeNil())
+	Expect(results[0].Migrated).To(BeFalse())
+}
+
+func TestMigrateBuildDefinitionOnlyVersion(t *testing.T) {
+	RegisterTestingT(t)
+
+	original := "# project build\nschemaVersion: \"1.8.1\" # bumped by migrate\nprojectName: simple\n"
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, BuildConfigFileName)
+	Expect(os.WriteFile(filePath, []byte(original), 0o644)).To(BeNil())
+
+	results, err := MigrateBuildDefinition(dir, false)
+	Expect(err).To(BeNil())
+	Expect(results).To(HaveLen(1))
+	Expect(results[0].Migrated).To(BeTrue())
+	Expect(results[0].Changes).To(BeEmpty())
+	content, err := os.ReadFile(filePath)
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(Equal(strings.Replace(original, "1.8.1", RootBuildDefinitionSchemaVersion, 1)))
+}
+
+func TestMigrateBuildDefinitionWithIncludesAndFragments(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		BuildConfigFileName: `schemaVersion: "1.8.0"
+projectName: migrate
+include:
+  - profiles.yaml
+modules:
+  - name: api
+  - name: web
+    path: web
+`,
+		"profiles.yaml": `profiles:
+  ci:
+    activation:
+      bamboo: true
+---
+tasks:
+  release:
+    image: alpine
+    script:
+      - welder deploy --bamboo
+`,
+		filepath.Join("web", BuildConfigFileName): `build:
+  steps:
+    - step:
+        image: node
+        script:
+          - welder make --bamboo -m web
+`,
+	}
+	for name, content := range files {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)).To(BeNil())
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)).To(BeNil())
+	}
+
+	expectedChanges := map[string][]string{
+		filepath.Join(dir, BuildConfigFileName): nil,
+		filepath.Join(dir, "profiles.yaml"): {
+			`1.9.0: line 4: replaced deprecated 'activation.bamboo' of profile "ci" with 'if: ${mode:bamboo}'`,
+			`1.9.0: line 10: removed deprecated '--bamboo' flag from script`,
+		},
+		filepath.Join(dir, "web", BuildConfigFileName): {
+			`1.9.0: line 6: removed deprecated '--bamboo' flag from script`,
+		},
+	}
+	for _, dryRun := range []bool{true, false} {
+		results, err := MigrateBuildDefinition(dir, dryRun)
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].File).To(Equal(filepath.Join(dir, BuildConfigFileName)))
+		Expect(results[0].Migrated).To(BeTrue())
+		for _, res := range results {
+			Expect(res.Changes).To(Equal(expectedChanges[res.File]), res.File)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "profiles.yaml"))
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(ContainSubstring("- welder deploy\n"))
+	Expect(string(content)).NotTo(ContainSubstring("schemaVersion"))
+	content, err = os.ReadFile(filepath.Join(dir, "web", BuildConfigFileName))
+	Expect(err).To(BeNil())
+	Expect(string(content)).To(ContainSubstring("- welder make -m web\n"))
+	Expect(string(content)).NotTo(ContainSubstring("schemaVersion"))
+
+	root, err := ReadBuildRootDefinition(dir)
+	Expect(err).To(BeNil())
+	Expect(root.SchemaVersion).To(Equal(RootBuildDefinitionSchemaVersion))
+	Expect(root.Profiles["ci"].Activation.If).To(Equal("${mode:bamboo}"))
+}
@@ -0,0 +1,27 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/invopop/jsonschema"
+	"github.com/pkg/errors"
+)
+
+// JSONSchema returns JSON Schema of welder.yaml for the current schema version (generated from `jsonschema` tags)
+func JSONSchema() ([]byte, error) {
+	schemaBytes, err := json.Marshal(jsonschema.Reflect(&RootBuildDefinition{}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal JSON schema")
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemaBytes, &schema); err != nil {
+		return nil, errors.Wrapf(err, "failed to read generated JSON schema")
+	}
+	schema["title"] = fmt.Sprintf("Welder configuration (schema version %s)", RootBuildDefinitionSchemaVersion)
+	res, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal JSON schema")
+	}
+	return res, nil
+}
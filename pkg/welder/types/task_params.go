@@ -0,0 +1,78 @@
+package types
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParamPlaceholderPrefix is the prefix of placeholders referencing values of task params
+const ParamPlaceholderPrefix = "${param:"
+
+// TaskParamDefinition declares parameter of the task that can be passed via `with` when invoking the task from a step
+type TaskParamDefinition struct {
+	Name        string `yaml:"name" json:"name" jsonschema:"title=Name of the parameter (available as ${param:name}),example=namespace"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty" jsonschema:"title=Whether parameter must be specified"`
+	Default     string `yaml:"default,omitempty" json:"default,omitempty" jsonschema:"title=Default value of the parameter"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=Description of the parameter"`
+}
+
+// ResolveParams validates provided values against declared params and returns values of all params (with defaults)
+func (td *TaskDefinition) ResolveParams(taskName string, with TaskParams) (TaskParams, error) {
+	declared := make(map[string]TaskParamDefinition, len(td.Params))
+	for _, param := range td.Params {
+		declared[param.Name] = param
+	}
+	var unknown []string
+	for name := range with {
+		if _, ok := declared[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf("task %s does not declare params: %s", taskName, strings.Join(unknown, ", "))
+	}
+	res := make(TaskParams, len(td.Params))
+	var missing []string
+	for _, param := range td.Params {
+		if value, ok := with[param.Name]; ok {
+			res[param.Name] = value
+		} else if param.Required {
+			missing = append(missing, param.Name)
+		} else {
+			res[param.Name] = StringValue(param.Default)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.Errorf("required params of task %s are not specified: %s", taskName, strings.Join(missing, ", "))
+	}
+	return res, nil
+}
+
+// PlaceholderParams returns placeholders as values of required params of the task, so that definition of the task
+// can be calculated when it is not invoked by a step (e.g. to collect images referenced by all tasks)
+func (td *TaskDefinition) PlaceholderParams() TaskParams {
+	res := make(TaskParams)
+	for _, param := range td.Params {
+		if param.Required {
+			res[param.Name] = StringValue(ParamPlaceholderPrefix + param.Name + "}")
+		}
+	}
+	return res
+}
+
+// CacheKey returns string representation of params suitable to distinguish definitions calculated with them
+func (params TaskParams) CacheKey() string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		res[i] = key + "=" + string(params[key])
+	}
+	return strings.Join(res, ",")
+}
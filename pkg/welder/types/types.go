@@ -5,14 +5,18 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/gob"
+	"fmt"
 	"os"
 	"os/signal"
 	"path"
+	"regexp"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	"github.com/simple-container-com/welder/pkg/docker"
 	"github.com/simple-container-com/welder/pkg/git"
 	"github.com/simple-container-com/welder/pkg/util"
@@ -28,6 +32,7 @@ type (
 	StringValue string
 	BuildArgs   map[string]StringValue
 	BuildEnv    map[string]StringValue
+	TaskParams  map[string]StringValue
 )
 
 type (
@@ -71,6 +76,8 @@ type CommonCtx struct {
 	ReuseContainers  bool
 	RemoveOrphans    bool
 	ForceOnHost      bool
+	Offline          bool
+	PullPolicy       docker.PullPolicy // overrides pull policy of all steps, tasks and images
 	DockerImages     []string
 	Profiles         []string
 	BuildArgs        BuildArgs
@@ -85,10 +92,17 @@ type CommonCtx struct {
 	cancelled              *atomic.Bool
 	rootDir                string
 	dockerImage            *OutDockerImageDefinition
-	subResolveContextDepth *sync.Map // depth of resolving expressions or conditions that use each other
+	subResolveContextChain []string  // chain of references being resolved (e.g. version or profile conditions)
 	lastExecOutput         string    // last execution output
 	executingTasks         *sync.Map // currently executing task(s)
+	extensionValues        *sync.Map // values resolved by extensions (nil value if extension didn't find the value)
 	gitClient              git.Git
+	registries             *registriesHolder
+}
+
+type registriesHolder struct {
+	once   sync.Once
+	config docker.RegistriesConfig
 }
 
 // CancelOnSignal calls Cancel when interruption signal is caught
@@ -117,25 +131,48 @@ func (commonCtx *CommonCtx) Cancel(fmtReason string, args ...interface{}) {
 	commonCtx.cancelFunc()
 }
 
-func (commonCtx *CommonCtx) IncrementSubResolveContextDepth(name string) {
-	if val, ok := commonCtx.subResolveContextDepth.Load(name); !ok {
-		commonCtx.subResolveContextDepth.Store(name, 1)
-	} else {
-		commonCtx.subResolveContextDepth.Store(name, val.(int)+1)
+// CyclicReferenceError defines error of the reference that depends on itself (e.g. `arg:a -> arg:b -> arg:a`)
+type CyclicReferenceError struct {
+	Chain []string // full chain of references ending with the reference being resolved again
+}
+
+func (e *CyclicReferenceError) Error() string {
+	return fmt.Sprintf("cyclic reference: %s", strings.Join(e.Chain, " -> "))
+}
+
+// StartResolving marks reference (e.g. `version` or `profile:ci`) as being resolved within the context
+// returns CyclicReferenceError with the full chain of references if the reference is already being resolved
+func (commonCtx *CommonCtx) StartResolving(ref string) error {
+	if commonCtx.IsResolving(ref) {
+		return &CyclicReferenceError{Chain: append(commonCtx.ResolvingChain(), ref)}
 	}
+	commonCtx.subResolveContextChain = append(commonCtx.ResolvingChain(), ref)
+	return nil
 }
 
-func (commonCtx *CommonCtx) DecrementSubResolveContextDepth(name string) {
-	if val, ok := commonCtx.subResolveContextDepth.Load(name); ok {
-		commonCtx.subResolveContextDepth.Store(name, val.(int)-1)
+// FinishResolving removes reference from the chain of references being resolved
+func (commonCtx *CommonCtx) FinishResolving(ref string) {
+	for i := len(commonCtx.subResolveContextChain) - 1; i >= 0; i-- {
+		if commonCtx.subResolveContextChain[i] == ref {
+			commonCtx.subResolveContextChain = commonCtx.subResolveContextChain[:i]
+			return
+		}
 	}
 }
 
-func (commonCtx *CommonCtx) SubResolveContextDepth(name string) int {
-	if val, ok := commonCtx.subResolveContextDepth.Load(name); ok {
-		return val.(int)
+// IsResolving returns true if reference is being resolved within the context
+func (commonCtx *CommonCtx) IsResolving(ref string) bool {
+	for _, r := range commonCtx.subResolveContextChain {
+		if r == ref {
+			return true
+		}
 	}
-	return 0
+	return false
+}
+
+// ResolvingChain returns chain of references being resolved within the context
+func (commonCtx *CommonCtx) ResolvingChain() []string {
+	return append([]string{}, commonCtx.subResolveContextChain...)
 }
 
 func (commonCtx *CommonCtx) SetLastExecOutput(output string) {
@@ -161,6 +198,18 @@ func (commonCtx *CommonCtx) ExecutedTask(name string) {
 	commonCtx.executingTasks.Store(name, false)
 }
 
+// CachedExtensionValue returns value resolved by extension for the request (nil if extension didn't find the value)
+func (commonCtx *CommonCtx) CachedExtensionValue(cacheKey string) (*string, bool) {
+	if val, ok := commonCtx.extensionValues.Load(cacheKey); ok {
+		return val.(*string), true
+	}
+	return nil, false
+}
+
+func (commonCtx *CommonCtx) CacheExtensionValue(cacheKey string, value *string) {
+	commonCtx.extensionValues.Store(cacheKey, value)
+}
+
 func (commonCtx *CommonCtx) SetVersion(version string) {
 	commonCtx.version = version
 }
@@ -177,6 +226,44 @@ func (commonCtx *CommonCtx) RootDir() string {
 	return commonCtx.rootDir
 }
 
+// SetRegistries overrides registry mirrors and rewrite rules applied to references of pulled images
+func (commonCtx *CommonCtx) SetRegistries(registries docker.RegistriesConfig) {
+	holder := &registriesHolder{config: registries}
+	holder.once.Do(func() {})
+	commonCtx.registries = holder
+}
+
+// Registries returns registry mirrors and rewrite rules applied to references of pulled images
+// rules from user config take precedence over rules defined by the project, built-in rules are applied last
+func (commonCtx *CommonCtx) Registries() docker.RegistriesConfig {
+	if commonCtx.registries == nil {
+		commonCtx.registries = &registriesHolder{}
+	}
+	commonCtx.registries.once.Do(func() {
+		userConfig, err := ReadUserConfig()
+		if err != nil {
+			commonCtx.Logger().Logf("WARN: %s", err.Error())
+		}
+		registries := userConfig.Registries
+		if _, root, err := ReadBuildModuleDefinition(commonCtx.RootDir()); err == nil {
+			registries = registries.Merge(root.Registries)
+		}
+		commonCtx.registries.config = registries.Merge(docker.DefaultRegistries)
+	})
+	return commonCtx.registries.config
+}
+
+// EffectivePullPolicy returns pull policy to apply considering global override and offline mode
+func (commonCtx *CommonCtx) EffectivePullPolicy(policy docker.PullPolicy) docker.PullPolicy {
+	if commonCtx.Offline {
+		return docker.PullPolicyNever
+	}
+	if commonCtx.PullPolicy != "" {
+		return commonCtx.PullPolicy
+	}
+	return policy
+}
+
 func (commonCtx *CommonCtx) OS() string {
 	if commonCtx.SimulateOS != "" {
 		return commonCtx.SimulateOS
@@ -218,13 +305,7 @@ func (commonCtx *CommonCtx) CurrentDockerImage() *OutDockerImageDefinition {
 // CalcHash calculates hash sum of configuration
 func (commonCtx *CommonCtx) CalcHash() (string, error) {
 	var b bytes.Buffer
-	subResolveValues := make(map[string]bool, 0)
-	if commonCtx.subResolveContextDepth != nil {
-		commonCtx.subResolveContextDepth.Range(func(key, value interface{}) bool {
-			subResolveValues[key.(string)] = value.(int) > 0
-			return true
-		})
-	}
+	resolving := commonCtx.ResolvingChain()
 	executingTasks := make(map[string]bool, 0)
 	if commonCtx.executingTasks != nil {
 		commonCtx.executingTasks.Range(func(key, value interface{}) bool {
@@ -233,9 +314,9 @@ func (commonCtx *CommonCtx) CalcHash() (string, error) {
 		})
 	}
 	gob.Register(executingTasks)
-	gob.Register(subResolveValues)
+	gob.Register(resolving)
 	gob.Register(commonCtx)
-	values := []interface{}{commonCtx, subResolveValues, commonCtx.rootDir, executingTasks}
+	values := []interface{}{commonCtx, resolving, commonCtx.rootDir, executingTasks}
 	if commonCtx.dockerImage != nil {
 		gob.Register(commonCtx.dockerImage)
 		values = append(values, commonCtx.dockerImage)
@@ -244,7 +325,7 @@ func (commonCtx *CommonCtx) CalcHash() (string, error) {
 	return base64.StdEncoding.EncodeToString(b.Bytes()), err
 }
 
-const RootBuildDefinitionSchemaVersion = "1.8.1"
+const RootBuildDefinitionSchemaVersion = "1.9.0"
 
 type VersionedDefinition struct {
 	SchemaVersion string `yaml:"schemaVersion,omitempty" json:"schemaVersion,omitempty" jsonschema:"title=Schema version,description=Version of the schema,default=1.5.0"`
@@ -252,21 +333,35 @@ type VersionedDefinition struct {
 
 type RootBuildDefinition struct {
 	VersionedDefinition `yaml:",inline"`
-	Version             string             `yaml:"version,omitempty" json:"version,omitempty" jsonschema:"title=Version of the project,example=1.0.0,required=false"`
-	ProjectName         string             `yaml:"projectName,omitempty" json:"projectName,omitempty" jsonschema:"title=Name of the project,example=my-super-project"`
-	ProjectRoot         string             `yaml:"projectRoot,omitempty" json:"projectRoot,omitempty" jsonschema:"title=Root directory for the project ,default=."`
-	Default             DefaultDefinition  `yaml:"default,omitempty" json:"default,omitempty"`
-	Profiles            ProfilesDefinition `yaml:"profiles,omitempty" json:"profiles,omitempty"`
-	Modules             ModulesDefinition  `yaml:"modules,omitempty" json:"modules,omitempty"`
-	Tasks               TasksDefinition    `yaml:"tasks,omitempty" json:"tasks,omitempty"`
+	Version             string                  `yaml:"version,omitempty" json:"version,omitempty" jsonschema:"title=Version of the project,example=1.0.0,required=false"`
+	ProjectName         string                  `yaml:"projectName,omitempty" json:"projectName,omitempty" jsonschema:"title=Name of the project,example=my-super-project"`
+	ProjectRoot         string                  `yaml:"projectRoot,omitempty" json:"projectRoot,omitempty" jsonschema:"title=Root directory for the project ,default=."`
+	Default             DefaultDefinition       `yaml:"default,omitempty" json:"default,omitempty"`
+	Profiles            ProfilesDefinition      `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	Modules             ModulesDefinition       `yaml:"modules,omitempty" json:"modules,omitempty"`
+	Tasks               TasksDefinition         `yaml:"tasks,omitempty" json:"tasks,omitempty"`
+	Registries          docker.RegistriesConfig `yaml:"registries,omitempty" json:"registries,omitempty" jsonschema:"title=Registry mirrors and rules to rewrite references of pulled images"`
+	Include             []string                `yaml:"include,omitempty" json:"include,omitempty" jsonschema:"title=Files (or glob patterns) with tasks and profiles to include,example=welder/tasks/*.yaml"`
+	Imports             []ImportDefinition      `yaml:"imports,omitempty" json:"imports,omitempty" jsonschema:"title=Libraries of tasks and profiles to import from git repositories"`
+	Extensions          ExtensionsDefinition    `yaml:"extensions,omitempty" json:"extensions,omitempty" jsonschema:"title=Custom placeholder namespaces resolved by external executables"`
 
 	rootDir               string
+	moduleVersionFiles    map[string]string // module name -> module file defining its version (if not in the root file)
 	actualBuildDefsCache  sync.Map
 	actualDeployDefsCache sync.Map
 	actualDockerDefsCache sync.Map
 	actualTaskDefsCache   sync.Map
 }
 
+// ExtensionsDefinition defines custom placeholder namespaces by their names (e.g. `vault` for ${vault:<path>})
+type ExtensionsDefinition map[string]ExtensionDefinition
+
+// ExtensionDefinition defines executable resolving placeholders of a custom namespace
+type ExtensionDefinition struct {
+	Command string   `yaml:"command" json:"command" jsonschema:"title=Executable reading JSON request from stdin and writing JSON response to stdout,example=welder-ext-vault"`
+	Args    []string `yaml:"args,omitempty" json:"args,omitempty" jsonschema:"title=Arguments to pass to the executable"`
+}
+
 func (root *RootBuildDefinition) initCaches() {
 	// root.actualTaskDefsCache = make(map[string]TaskDefinition)
 	// root.actualDockerDefsCache = make(map[string][]DockerImageDefinition)
@@ -419,6 +514,7 @@ type ProfileActivationDefinition struct {
 
 type CommonSimpleRunDefinition struct {
 	Env              BuildEnv          `yaml:"env,omitempty" json:"env,omitempty" jsonschema:"title=Environment variables"`
+	EnvFile          []string          `yaml:"envFile,omitempty" json:"envFile,omitempty" jsonschema:"title=Files with environment variables in dotenv format (optional if path ends with ?),example=.env.local?"`
 	Volumes          VolumesDefinition `yaml:"volumes,omitempty" json:"volumes,omitempty" jsonschema:"title=Volumes to mount to container"`
 	WorkDir          string            `yaml:"workDir,omitempty" json:"workDir,omitempty" jsonschema:"title=Working directory (module dir by default)"`
 	ContainerWorkDir string            `yaml:"containerWorkDir,omitempty" json:"containerWorkDir,omitempty" jsonschema:"title=Working directory within container (same as host dir by default)"`
@@ -431,10 +527,20 @@ type CommonRunDefinition struct {
 }
 
 type SimpleStepDefinition struct {
-	Image   string    `yaml:"image,omitempty" json:"image,omitempty" jsonschema:"title=Docker image to use when running in container,oneof_required=image"`
-	Scripts []string  `yaml:"script,omitempty" json:"script,omitempty" jsonschema:"title=Commands to execute"`
-	RunOn   RunOnType `yaml:"runOn,omitempty" json:"runOn,omitempty" jsonschema:"enum=container,enum=host,title=Run mode (container || host),default=container,oneof_required=runOn"`
-	RunIf   string    `yaml:"runIf,omitempty" json:"runIf,omitempty" jsonschema:"title=Condition to execute step,example=${mode:bitbucket}"`
+	Image       string            `yaml:"image,omitempty" json:"image,omitempty" jsonschema:"title=Docker image to use when running in container,oneof_required=image"`
+	Scripts     []string          `yaml:"script,omitempty" json:"script,omitempty" jsonschema:"title=Commands to execute"`
+	RunOn       RunOnType         `yaml:"runOn,omitempty" json:"runOn,omitempty" jsonschema:"enum=container,enum=host,title=Run mode (container || host),default=container,oneof_required=runOn"`
+	RunIf       string            `yaml:"runIf,omitempty" json:"runIf,omitempty" jsonschema:"title=Condition to execute step,example=${mode:bitbucket}"`
+	Pull        docker.PullPolicy `yaml:"pull,omitempty" json:"pull,omitempty" jsonschema:"enum=always,enum=if-not-present,enum=never,title=When to pull the image (always || if-not-present || never),default=if-not-present"`
+	HostEnv     HostEnvMode       `yaml:"hostEnv,omitempty" json:"hostEnv,omitempty" jsonschema:"enum=inherit,enum=clean,enum=allowlist,title=Environment to start scripts running on host with (inherit || clean || allowlist),default=inherit"`
+	Shell       string            `yaml:"shell,omitempty" json:"shell,omitempty" jsonschema:"title=Interpreter to execute scripts with,default=sh,example=bash,example=python3 -c"`
+	StrictShell bool              `yaml:"strictShell,omitempty" json:"strictShell,omitempty" jsonschema:"title=Execute scripts with 'set -eo pipefail' semantics (POSIX shells only)"`
+	ScriptFile  string            `yaml:"scriptFile,omitempty" json:"scriptFile,omitempty" jsonschema:"title=Path to the script file to execute (relative to the project root)"`
+}
+
+// HasScripts returns true if step defines scripts to execute (either inline or within script file)
+func (sd *SimpleStepDefinition) HasScripts() bool {
+	return len(sd.Scripts) > 0 || sd.ScriptFile != ""
 }
 
 type RunAfterStepDefinition struct {
@@ -448,6 +554,7 @@ type StepsDefinition struct {
 	Step                      StepDefinition `yaml:"step,omitempty" json:"step,omitempty" jsonschema:"title=Definition of the step,oneof_required=step"`
 	Task                      string         `yaml:"task,omitempty" json:"task,omitempty" jsonschema:"title=Name of the task to invoke,oneof_required=task"`
 	Pipe                      string         `yaml:"pipe,omitempty" json:"pipe,omitempty" jsonschema:"title=Bitbucket Pipelines pipe to invoke,oneof_required=pipe"`
+	With                      TaskParams     `yaml:"with,omitempty" json:"with,omitempty" jsonschema:"title=Parameters to invoke the task with (available as ${param:name})"`
 }
 
 type StepDefinition struct {
@@ -458,7 +565,8 @@ type StepDefinition struct {
 type TaskDefinition struct {
 	CommonRunDefinition `yaml:",inline"`
 	StepDefinition      `yaml:",inline"`
-	Description         string `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=Description of the task"`
+	Description         string                `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=Description of the task"`
+	Params              []TaskParamDefinition `yaml:"params,omitempty" json:"params,omitempty" jsonschema:"title=Parameters the task accepts via 'with'"`
 }
 
 type BuildDefinition struct {
@@ -481,12 +589,17 @@ func (rd *CommonRunDefinition) ActualContainerWorkDir(root RootBuildDefinition)
 
 func (sd *SimpleStepDefinition) ToRunSpec(name string, run CommonRunDefinition) RunSpec {
 	return RunSpec{
-		Name:    name,
-		Image:   sd.Image,
-		Scripts: sd.Scripts,
-		RunOn:   sd.RunOn,
-		RunCfg:  run,
-		RunIf:   sd.RunIf,
+		Name:        name,
+		Image:       sd.Image,
+		Scripts:     sd.Scripts,
+		RunOn:       sd.RunOn,
+		RunCfg:      run,
+		RunIf:       sd.RunIf,
+		Pull:        sd.Pull,
+		HostEnv:     sd.HostEnv,
+		Shell:       sd.Shell,
+		StrictShell: sd.StrictShell,
+		ScriptFile:  sd.ScriptFile,
 	}
 }
 
@@ -519,6 +632,48 @@ const (
 	RunOnTypeContainer RunOnType = "container"
 )
 
+type HostEnvMode string
+
+// IsClean returns true if scripts running on host should not inherit the whole host environment
+func (m HostEnvMode) IsClean() bool {
+	return m == HostEnvClean || m == HostEnvAllowlist
+}
+
+func (HostEnvMode) Enum() []interface{} {
+	return []interface{}{
+		HostEnvInherit,
+		HostEnvClean,
+		HostEnvAllowlist,
+	}
+}
+
+const (
+	HostEnvInherit   HostEnvMode = "inherit"   // inherit the whole host environment (default)
+	HostEnvClean     HostEnvMode = "clean"     // start with declared env and injectEnv only
+	HostEnvAllowlist HostEnvMode = "allowlist" // same as clean, but also pass essential host variables (e.g. PATH, HOME)
+)
+
+// HostEnvAllowlistRegex matches essential host variables passed to scripts running with 'allowlist' host env
+var HostEnvAllowlistRegex = regexp.MustCompile(`^(PATH|HOME|USER|LOGNAME|SHELL|TMPDIR|TERM|LANG|LC_[A-Z]+|TZ)$`)
+
+type ImageBuilderType string
+
+func (ImageBuilderType) Enum() []interface{} {
+	return []interface{}{
+		ImageBuilderDocker,
+		ImageBuilderKaniko,
+		ImageBuilderBuildKit,
+		ImageBuilderBuildah,
+	}
+}
+
+const (
+	ImageBuilderDocker   ImageBuilderType = "docker"
+	ImageBuilderKaniko   ImageBuilderType = "kaniko"
+	ImageBuilderBuildKit ImageBuilderType = "buildkit"
+	ImageBuilderBuildah  ImageBuilderType = "buildah"
+)
+
 type RunSpec struct {
 	RunCfg      CommonRunDefinition
 	CustomImage DockerImageDefinition
@@ -526,7 +681,41 @@ type RunSpec struct {
 	Image       string
 	RunOn       RunOnType
 	RunIf       string
+	Pull        docker.PullPolicy
+	HostEnv     HostEnvMode
+	Shell       string
+	StrictShell bool
 	Scripts     []string
+	ScriptFile  string
+}
+
+// ShellToRun returns interpreter to execute scripts with
+func (spec *RunSpec) ShellToRun() (util.Shell, error) {
+	return util.ParseShell(spec.Shell, spec.StrictShell)
+}
+
+// ReadScriptFile reads script file (if specified) relative to the project root and uses it as the script to execute
+// (if shell is not specified, interpreter is detected from the shebang line of the file)
+func (spec *RunSpec) ReadScriptFile(rootDir string) error {
+	if spec.ScriptFile == "" {
+		return nil
+	}
+	if len(spec.Scripts) > 0 {
+		return errors.Errorf("script and scriptFile cannot be specified both for %q", spec.Name)
+	}
+	scriptPath := spec.ScriptFile
+	if !path.IsAbs(scriptPath) {
+		scriptPath = path.Join(rootDir, scriptPath)
+	}
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read script file for %q", spec.Name)
+	}
+	if spec.Shell == "" {
+		spec.Shell = util.ShellFromShebang(string(content))
+	}
+	spec.Scripts = []string{string(content)}
+	return nil
 }
 
 const OutDockerSchemaVersion = "1.0"
@@ -556,8 +745,10 @@ type DockerImageDefinition struct {
 	Name             string                 `yaml:"name,omitempty" json:"name,omitempty" jsonschema:"title=Name of the Docker image to build"`
 	DockerFile       string                 `yaml:"dockerFile,omitempty" json:"dockerFile,omitempty" jsonschema:"title=Dockerfile to use with the docker build,oneof_required=dockerfile"`
 	Tags             []string               `yaml:"tags,omitempty" json:"tags,omitempty" jsonschema:"title=Tags to apply to the built Docker image"`
+	Builder          ImageBuilderType       `yaml:"builder,omitempty" json:"builder,omitempty" jsonschema:"enum=docker,enum=kaniko,enum=buildkit,enum=buildah,title=Builder backend to build the Docker image with (docker || kaniko || buildkit || buildah),default=docker"`
 	Build            DockerBuildDefinition  `yaml:"build,omitempty" json:"build,omitempty" jsonschema:"title=Build definition of the Docker image"`
 	InlineDockerfile string                 `yaml:"inlineDockerFile,omitempty" json:"inlineDockerFile,omitempty" jsonschema:"title=Inline text of the Dockerfile to build,oneof_required=inlinedockerfile"`
+	Pull             docker.PullPolicy      `yaml:"pull,omitempty" json:"pull,omitempty" jsonschema:"enum=always,enum=if-not-present,enum=never,title=When to pull base images of the Dockerfile (always || if-not-present || never),default=always"`
 	RunAfterBuild    RunAfterStepDefinition `yaml:"runAfterBuild,omitempty" json:"runAfterBuild,omitempty" jsonschema:"title=Step to run after Docker image is built"`
 	RunAfterPush     RunAfterStepDefinition `yaml:"runAfterPush,omitempty" json:"runAfterPush,omitempty" jsonschema:"title=Step to run after Docker image is pushed"`
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	"github.com/simple-container-com/welder/pkg/docker"
 	"github.com/simple-container-com/welder/pkg/util"
 	dsl "github.com/simple-container-com/welder/pkg/welder/types"
 	"github.com/stretchr/testify/assert"
@@ -50,3 +51,31 @@ func TestBuildContextHash(t *testing.T) {
 	Expect(hash1).NotTo(Equal(hash2))
 	Expect(hash1).To(Equal(hash3))
 }
+
+func TestEffectivePullPolicy(t *testing.T) {
+	RegisterTestingT(t)
+
+	step := dsl.StepDefinition{SimpleStepDefinition: dsl.SimpleStepDefinition{Image: "alpine", Pull: docker.PullPolicyAlways}}
+	spec := step.ToRunSpec("step", dsl.CommonRunDefinition{})
+	Expect(spec.Pull).To(Equal(docker.PullPolicyAlways))
+
+	ctx := dsl.NewCommonContext(&dsl.CommonCtx{}, &util.NoopLogger{})
+	Expect(ctx.EffectivePullPolicy(spec.Pull)).To(Equal(docker.PullPolicyAlways))
+	Expect(ctx.EffectivePullPolicy("")).To(Equal(docker.PullPolicy("")))
+
+	// global override takes precedence
+	ctx = dsl.NewCommonContext(&dsl.CommonCtx{PullPolicy: docker.PullPolicyIfNotPresent}, &util.NoopLogger{})
+	Expect(ctx.EffectivePullPolicy(spec.Pull)).To(Equal(docker.PullPolicyIfNotPresent))
+
+	// offline mode never pulls
+	ctx = dsl.NewCommonContext(&dsl.CommonCtx{Offline: true, PullPolicy: docker.PullPolicyAlways}, &util.NoopLogger{})
+	Expect(ctx.EffectivePullPolicy(spec.Pull)).To(Equal(docker.PullPolicyNever))
+}
+
+func TestMergeEnvFiles(t *testing.T) {
+	RegisterTestingT(t)
+
+	to := dsl.CommonSimpleRunDefinition{EnvFile: []string{"task.env", "common.env"}}
+	dsl.MergeSimpleRunDefinitions(dsl.CommonSimpleRunDefinition{EnvFile: []string{"common.env", "module.env"}}, &to, false)
+	Expect(to.EnvFile).To(Equal([]string{"module.env", "task.env", "common.env"}))
+}
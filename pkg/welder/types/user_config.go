@@ -0,0 +1,68 @@
+package types
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/simple-container-com/welder/pkg/docker"
+)
+
+const (
+	UserConfigDirName  = ".welder"
+	UserConfigFileName = "config.yaml"
+	UserConfigPathEnv  = "WELDER_CONFIG"
+	CacheDirName       = "cache"
+	CacheDirPathEnv    = "WELDER_CACHE_DIR"
+)
+
+// UserConfig defines per-user (or per-agent) Welder configuration that applies to all projects
+type UserConfig struct {
+	Registries docker.RegistriesConfig `yaml:"registries,omitempty" json:"registries,omitempty"`
+}
+
+// UserConfigPath returns path to the user config file ($WELDER_CONFIG or ~/.welder/config.yaml)
+func UserConfigPath() (string, error) {
+	if configPath := os.Getenv(UserConfigPathEnv); configPath != "" {
+		return configPath, nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to detect current user")
+	}
+	return filepath.Join(usr.HomeDir, UserConfigDirName, UserConfigFileName), nil
+}
+
+// CacheDirPath returns path to the directory to cache downloaded content in ($WELDER_CACHE_DIR or ~/.welder/cache)
+func CacheDirPath() (string, error) {
+	if cacheDir := os.Getenv(CacheDirPathEnv); cacheDir != "" {
+		return cacheDir, nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to detect current user")
+	}
+	return filepath.Join(usr.HomeDir, UserConfigDirName, CacheDirName), nil
+}
+
+// ReadUserConfig reads user config file (returns empty config if file does not exist)
+func ReadUserConfig() (UserConfig, error) {
+	var res UserConfig
+	configPath, err := UserConfigPath()
+	if err != nil {
+		return res, err
+	}
+	configBytes, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return res, errors.Wrapf(err, "failed to read user config %q", configPath)
+	}
+	if err := yaml.UnmarshalStrict(configBytes, &res); err != nil {
+		return res, errors.Wrapf(err, "failed to parse user config %q", configPath)
+	}
+	return res, nil
+}
@@ -0,0 +1,476 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/simple-container-com/welder/pkg/util"
+)
+
+type DiagnosticSeverity string
+
+const (
+	DiagnosticError   DiagnosticSeverity = "error"
+	DiagnosticWarning DiagnosticSeverity = "warning"
+)
+
+// Diagnostic defines a problem found in the configuration
+type Diagnostic struct {
+	File     string
+	Line     int
+	Severity DiagnosticSeverity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+}
+
+type Diagnostics []Diagnostic
+
+// HasErrors returns true if any of diagnostics is an error
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == DiagnosticError {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	argPlaceholderRegex     = regexp.MustCompile(`\$\{arg:([^:}|\s]+)(:[^}|]*)?[^}]*}`)
+	profilePlaceholderRegex = regexp.MustCompile(`\$\{profile:([^.}]+)\.`)
+
+	// namespaces of placeholders that can't be overridden by extensions
+	builtInPlaceholderNamespaces = []string{
+		"arg", "param", "env", "git", "date", "user", "file", "json", "yaml", "sha256", "host", "container",
+		"docker", "profile", "mode", "project", "os", "task",
+	}
+
+	// types with oneof_required fields that can't be specified together
+	exclusiveOneOfTypes = map[reflect.Type]bool{
+		reflect.TypeOf(StepsDefinition{}):       true,
+		reflect.TypeOf(DockerImageDefinition{}): true,
+	}
+)
+
+// nodeRef refers to the name used at a specific line of a file
+type nodeRef struct {
+	file string
+	line int
+	name string
+}
+
+type validator struct {
+	diagnostics Diagnostics
+	taskRefs    []nodeRef
+	profileRefs []nodeRef
+	argRefs     []nodeRef
+	moduleNames []nodeRef
+}
+
+// ValidateBuildDefinition validates welder.yaml in basePath (along with included and module files) against the schema
+// and semantic rules, returning diagnostics referencing file and line of each problem
+func ValidateBuildDefinition(basePath string) Diagnostics {
+	v := &validator{}
+	rootFile := filepath.Join(basePath, BuildConfigFileName)
+	if _, err := os.Stat(rootFile); err != nil {
+		v.addf(BuildConfigFileName, 0, DiagnosticError, "%s", err.Error())
+		return v.diagnostics
+	}
+	v.validateFile(basePath, rootFile, reflect.TypeOf(RootBuildDefinition{}))
+
+	root, err := v.readRootDefinition(basePath)
+	if err != nil {
+		return v.sorted()
+	}
+	for _, include := range root.Include {
+		files, _ := filepath.Glob(filepath.Join(basePath, include))
+		sort.Strings(files)
+		for _, file := range files {
+			v.validateFile(basePath, file, reflect.TypeOf(IncludeDefinition{}))
+		}
+	}
+	for _, module := range root.Modules {
+		fragmentFile := filepath.Join(basePath, module.Path, BuildConfigFileName)
+		if module.Path == "" || filepath.Clean(fragmentFile) == filepath.Clean(rootFile) {
+			continue
+		}
+		if _, err := os.Stat(fragmentFile); err == nil {
+			v.validateFile(basePath, fragmentFile, reflect.TypeOf(ModuleFragmentDefinition{}))
+		}
+	}
+	v.validateReferences(root)
+	return v.sorted()
+}
+
+// readRootDefinition reads merged definition to validate references against
+// (leniently if the definition has structural errors, since they are already reported more precisely)
+func (v *validator) readRootDefinition(basePath string) (*RootBuildDefinition, error) {
+	root, err := ReadBuildRootDefinition(basePath)
+	if err == nil {
+		return &root, nil
+	}
+	if !v.diagnostics.HasErrors() {
+		v.addf(BuildConfigFileName, 0, DiagnosticError, "%s", err.Error())
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(basePath, BuildConfigFileName))
+	if err != nil {
+		return nil, err
+	}
+	var lenient RootBuildDefinition
+	if err := yaml.Unmarshal(content, &lenient); err != nil {
+		return nil, err
+	}
+	if err := lenient.readIncludesAndFragments(basePath, content); err != nil {
+		return nil, err
+	}
+	return &lenient, nil
+}
+
+func (v *validator) validateFile(basePath string, file string, t reflect.Type) {
+	fileName := relPath(basePath, file)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		v.addf(fileName, 0, DiagnosticError, "%s", err.Error())
+		return
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &doc); err != nil {
+		v.addf(fileName, 0, DiagnosticError, "%s", err.Error())
+		return
+	}
+	if len(doc.Content) == 0 {
+		return
+	}
+	v.validateNode(fileName, doc.Content[0], t, "", "")
+}
+
+// validateNode validates YAML node against the type it is unmarshalled into
+func (v *validator) validateNode(file string, node *yamlv3.Node, t reflect.Type, path string, schemaTag string) {
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yamlv3.MappingNode {
+			v.addf(file, node.Line, DiagnosticError, "%s must be a mapping", displayPath(path))
+			return
+		}
+		v.validateStruct(file, node, t, path)
+	case reflect.Map:
+		if node.Kind != yamlv3.MappingNode {
+			v.addf(file, node.Line, DiagnosticError, "%s must be a mapping", displayPath(path))
+			return
+		}
+		for _, pair := range mappingPairs(node) {
+			v.validateNode(file, pair[1], t.Elem(), path+"."+pair[0].Value, "")
+		}
+	case reflect.Slice:
+		if node.Kind != yamlv3.SequenceNode {
+			v.addf(file, node.Line, DiagnosticError, "%s must be a list", displayPath(path))
+			return
+		}
+		for i, item := range node.Content {
+			v.validateNode(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), schemaTag)
+		}
+	case reflect.String:
+		if node.Kind != yamlv3.ScalarNode {
+			v.addf(file, node.Line, DiagnosticError, "%s must be a string", displayPath(path))
+			return
+		}
+		v.validateEnum(file, node, path, schemaTag)
+		v.collectPlaceholders(file, node)
+	case reflect.Bool:
+		if node.Kind != yamlv3.ScalarNode || (node.Tag != "!!bool" && !isYaml11Bool(node)) {
+			v.addf(file, node.Line, DiagnosticError, "%s must be a boolean", displayPath(path))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.Kind != yamlv3.ScalarNode || node.Tag != "!!int" {
+			v.addf(file, node.Line, DiagnosticError, "%s must be an integer", displayPath(path))
+		}
+	}
+}
+
+// isYaml11Bool returns true if plain scalar is a boolean according to YAML 1.1 (e.g. yes or off),
+// since definitions are loaded with yaml.v2 which accepts them
+func isYaml11Bool(node *yamlv3.Node) bool {
+	if node.Style != 0 {
+		return false
+	}
+	switch node.Value {
+	case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON",
+		"n", "N", "no", "No", "NO", "off", "Off", "OFF":
+		return true
+	}
+	return false
+}
+
+func (v *validator) validateStruct(file string, node *yamlv3.Node, t reflect.Type, path string) {
+	fields := make(map[string]reflect.StructField)
+	collectYamlFields(t, fields)
+	var oneOfKeys []string
+	for name, field := range fields {
+		if schemaTagValues(field, "oneof_required") != nil {
+			oneOfKeys = append(oneOfKeys, name)
+		}
+	}
+	sort.Strings(oneOfKeys)
+
+	var specifiedOneOf []string
+	for _, pair := range mappingPairs(node) {
+		keyNode, valueNode := pair[0], pair[1]
+		key := keyNode.Value
+		field, known := fields[key]
+		if !known {
+			v.addf(file, keyNode.Line, DiagnosticError, "unknown field %q in %s", key, displayPath(path))
+			continue
+		}
+		if valueNode.Tag != "!!null" && util.SliceContains(oneOfKeys, key) {
+			specifiedOneOf = append(specifiedOneOf, key)
+		}
+		v.validateNode(file, valueNode, field.Type, joinPath(path, key), field.Tag.Get("jsonschema"))
+		v.collectReferences(file, t, key, valueNode)
+		if t == reflect.TypeOf(RootBuildDefinition{}) && key == "extensions" {
+			v.validateExtensions(file, valueNode)
+		}
+	}
+
+	if len(oneOfKeys) > 0 && len(specifiedOneOf) == 0 {
+		v.addf(file, node.Line, DiagnosticError, "one of [%s] must be specified in %s",
+			strings.Join(oneOfKeys, ", "), displayPath(path))
+	} else if len(specifiedOneOf) > 1 && exclusiveOneOfTypes[t] {
+		v.addf(file, node.Line, DiagnosticError, "only one of [%s] can be specified in %s",
+			strings.Join(specifiedOneOf, ", "), displayPath(path))
+	}
+}
+
+// collectReferences collects names of tasks and modules referenced by the node
+func (v *validator) collectReferences(file string, t reflect.Type, key string, node *yamlv3.Node) {
+	switch {
+	case t == reflect.TypeOf(StepsDefinition{}) && key == "task" && node.Kind == yamlv3.ScalarNode:
+		v.taskRefs = append(v.taskRefs, nodeRef{file: file, line: node.Line, name: node.Value})
+	case t == reflect.TypeOf(RunAfterStepDefinition{}) && key == "tasks" && node.Kind == yamlv3.SequenceNode:
+		for _, item := range node.Content {
+			v.taskRefs = append(v.taskRefs, nodeRef{file: file, line: item.Line, name: item.Value})
+		}
+	case t == reflect.TypeOf(ModuleDefinition{}) && key == "name" && node.Kind == yamlv3.ScalarNode:
+		v.moduleNames = append(v.moduleNames, nodeRef{file: file, line: node.Line, name: node.Value})
+	}
+}
+
+// IsBuiltInPlaceholderNamespace returns true if namespace is reserved by built-in placeholders (e.g. `env` or `arg`)
+func IsBuiltInPlaceholderNamespace(namespace string) bool {
+	return util.SliceContains(builtInPlaceholderNamespaces, namespace)
+}
+
+// validateExtensions validates names and commands of the custom placeholder namespaces
+func (v *validator) validateExtensions(file string, node *yamlv3.Node) {
+	for _, pair := range mappingPairs(node) {
+		keyNode, valueNode := pair[0], pair[1]
+		if IsBuiltInPlaceholderNamespace(keyNode.Value) {
+			v.addf(file, keyNode.Line, DiagnosticWarning,
+				"extension %q is ignored since it has the same name as the built-in placeholder namespace", keyNode.Value)
+		}
+		if command := mappingValue(valueNode, "command"); command == nil || command.Value == "" {
+			v.addf(file, keyNode.Line, DiagnosticError, "command must be specified in extensions.%s", keyNode.Value)
+		}
+	}
+}
+
+// collectPlaceholders collects names of arguments and profiles referenced by placeholders
+func (v *validator) collectPlaceholders(file string, node *yamlv3.Node) {
+	for _, match := range argPlaceholderRegex.FindAllStringSubmatch(node.Value, -1) {
+		if match[2] == "" { // placeholders with default value are always resolved
+			v.argRefs = append(v.argRefs, nodeRef{file: file, line: node.Line, name: match[1]})
+		}
+	}
+	for _, match := range profilePlaceholderRegex.FindAllStringSubmatch(node.Value, -1) {
+		v.profileRefs = append(v.profileRefs, nodeRef{file: file, line: node.Line, name: match[1]})
+	}
+}
+
+func (v *validator) validateEnum(file string, node *yamlv3.Node, path string, schemaTag string) {
+	enum := parseSchemaTag(schemaTag, "enum")
+	if len(enum) == 0 || node.Value == "" || strings.Contains(node.Value, "${") {
+		return
+	}
+	if !util.SliceContains(enum, node.Value) {
+		v.addf(file, node.Line, DiagnosticError, "invalid value %q of %s, must be one of [%s]",
+			node.Value, displayPath(path), strings.Join(enum, ", "))
+	}
+}
+
+// validateReferences validates references to tasks, profiles, modules and arguments against the merged definition
+func (v *validator) validateReferences(root *RootBuildDefinition) {
+	for _, ref := range v.taskRefs {
+		if strings.Contains(ref.name, "${") {
+			continue
+		}
+		if _, exists := root.Tasks[ref.name]; !exists {
+			v.addf(ref.file, ref.line, DiagnosticError, "unknown task %q", ref.name)
+		}
+	}
+	for _, ref := range v.profileRefs {
+		if _, exists := root.Profiles[ref.name]; !exists {
+			v.addf(ref.file, ref.line, DiagnosticError, "unknown profile %q", ref.name)
+		}
+	}
+	modules := make(map[string]nodeRef)
+	for _, ref := range v.moduleNames {
+		if prev, exists := modules[ref.name]; exists {
+			v.addf(ref.file, ref.line, DiagnosticError, "module %q is already defined in %s:%d", ref.name, prev.file, prev.line)
+			continue
+		}
+		modules[ref.name] = ref
+	}
+	declaredArgs := root.declaredArgs()
+	for _, ref := range v.argRefs {
+		if _, declared := declaredArgs[ref.name]; !declared {
+			v.addf(ref.file, ref.line, DiagnosticWarning,
+				"argument %q is not defined in any 'args' and has no default value (use ${arg:%s:<default>})", ref.name, ref.name)
+		}
+	}
+}
+
+// declaredArgs returns names of all arguments declared in the definition
+func (root *RootBuildDefinition) declaredArgs() map[string]bool {
+	res := make(map[string]bool)
+	addArgs := func(args ...BuildArgs) {
+		for _, a := range args {
+			for name := range a {
+				res[name] = true
+			}
+		}
+	}
+	addModule := func(module BasicModuleDefinition) {
+		addArgs(module.Build.Args, module.Deploy.Args)
+		for _, env := range module.Deploy.Environments {
+			addArgs(env.Args)
+		}
+	}
+	addModule(root.Default.BasicModuleDefinition)
+	for _, profile := range root.Profiles {
+		addModule(profile.BasicModuleDefinition)
+	}
+	for _, module := range root.Modules {
+		addModule(module.BasicModuleDefinition)
+	}
+	for _, task := range root.Tasks {
+		addArgs(task.Args)
+	}
+	return res
+}
+
+func (v *validator) addf(file string, line int, severity DiagnosticSeverity, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{File: file, Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) sorted() Diagnostics {
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		if v.diagnostics[i].File != v.diagnostics[j].File {
+			return v.diagnostics[i].File < v.diagnostics[j].File
+		}
+		return v.diagnostics[i].Line < v.diagnostics[j].Line
+	})
+	return v.diagnostics
+}
+
+// mappingPairs returns key and value nodes of the mapping resolving merge keys (`<<: *anchor`)
+func mappingPairs(node *yamlv3.Node) [][2]*yamlv3.Node {
+	var res [][2]*yamlv3.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag != "!!merge" {
+			res = append(res, [2]*yamlv3.Node{keyNode, valueNode})
+			continue
+		}
+		merged := []*yamlv3.Node{valueNode}
+		if valueNode.Kind == yamlv3.SequenceNode {
+			merged = valueNode.Content
+		}
+		for _, m := range merged {
+			if m.Kind == yamlv3.AliasNode {
+				m = m.Alias
+			}
+			if m.Kind == yamlv3.MappingNode {
+				res = append(res, mappingPairs(m)...)
+			}
+		}
+	}
+	return res
+}
+
+// collectYamlFields collects fields of the struct by their yaml names (including fields of inlined structs)
+func collectYamlFields(t reflect.Type, res map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if util.SliceContains(parts[1:], "inline") {
+			collectYamlFields(field.Type, res)
+			continue
+		}
+		name := parts[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		res[name] = field
+	}
+}
+
+// schemaTagValues returns values of the key within `jsonschema` tag of the field
+func schemaTagValues(field reflect.StructField, key string) []string {
+	return parseSchemaTag(field.Tag.Get("jsonschema"), key)
+}
+
+func parseSchemaTag(tag string, key string) []string {
+	var res []string
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, key+"=") {
+			res = append(res, strings.TrimPrefix(part, key+"="))
+		}
+	}
+	return res
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return path
+}
@@ -0,0 +1,71 @@
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	. "github.com/simple-container-com/welder/pkg/welder/types"
+)
+
+func TestValidateBuildDefinition(t *testing.T) {
+	RegisterTestingT(t)
+
+	diagnostics := ValidateBuildDefinition("testdata/invalid")
+	Expect(diagnostics.HasErrors()).To(BeTrue())
+
+	messages := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		messages[i] = d.String()
+	}
+	Expect(messages).To(Equal([]string{
+		`welder.yaml:6: error: unknown profile "cii"`,
+		`welder.yaml:11: error: unknown task "buld"`,
+		`welder.yaml:13: error: invalid value "somewhere" of modules[0].build.steps[1].step.runOn, must be one of [container, host]`,
+		`welder.yaml:15: warning: argument "undefined" is not defined in any 'args' and has no default value (use ${arg:undefined:<default>})`,
+		`welder.yaml:16: error: only one of [task, pipe] can be specified in modules[0].build.steps[2]`,
+		`welder.yaml:24: error: unknown task "missing"`,
+		`welder.yaml:25: error: module "api" is already defined in welder.yaml:8`,
+		`welder.yaml:30: error: one of [pipe, step, task] must be specified in modules[1].build.steps[0]`,
+		`welder.yaml:37: error: unknown field "imgae" in tasks.broken`,
+		`welder.yaml:37: error: one of [customImage, image, runOn] must be specified in tasks.broken`,
+		`welder.yaml:39: warning: extension "env" is ignored since it has the same name as the built-in placeholder namespace`,
+		`welder.yaml:41: error: command must be specified in extensions.vault`,
+	}))
+}
+
+func TestValidateValidBuildDefinition(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(ValidateBuildDefinition("testdata/include")).To(BeEmpty())
+}
+
+func TestValidateYaml11Booleans(t *testing.T) {
+	RegisterTestingT(t)
+
+	projectDir := t.TempDir()
+	Expect(os.WriteFile(filepath.Join(projectDir, BuildConfigFileName), []byte(`schemaVersion: "1.8.0"
+projectName: booleans
+profiles:
+  ci:
+    activation:
+      pipelines: yes
+      linux: On
+      sox: false
+  local:
+    activation:
+      darwin: "yes"
+      verbose: maybe
+`), 0o644)).To(BeNil())
+
+	messages := make([]string, 0)
+	for _, d := range ValidateBuildDefinition(projectDir) {
+		messages = append(messages, d.String())
+	}
+	Expect(messages).To(Equal([]string{
+		`welder.yaml:11: error: profiles.local.activation.darwin must be a boolean`,
+		`welder.yaml:12: error: profiles.local.activation.verbose must be a boolean`,
+	}))
+}
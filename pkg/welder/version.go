@@ -91,20 +91,19 @@ func (ctx *VersionCtx) unresolvedVersion() (string, error) {
 
 // Version returns version defined for current context
 func (ctx *VersionCtx) Version() (string, error) {
-	empty := ""
 	tpl := &Tpl{buildCtx: ctx.buildCtx, root: &ctx.root, module: ctx.activeModule, deployCtx: ctx.deployCtx}
 	unresolvedVersion, err := ctx.unresolvedVersion()
 	if err != nil {
-		return empty, err
+		return "", err
 	}
-	tpl.version = &empty
 
-	ctx.buildCtx.IncrementSubResolveContextDepth("version")
-	if ctx.buildCtx.SubResolveContextDepth("version") > 4 {
-		return unresolvedVersion, nil
+	// version is requested again while calculating definitions it's resolved with (e.g. by their ${project:version})
+	if err := ctx.buildCtx.StartResolving("version"); err != nil {
+		return unresolvedVersion, err
 	}
-	defer ctx.buildCtx.DecrementSubResolveContextDepth("version")
+	defer ctx.buildCtx.FinishResolving("version")
 
+	var r *resolver
 	if ctx.deployCtx != nil {
 		var deployDef types.DeployDefinition
 		if ctx.activeModule != nil {
@@ -112,11 +111,9 @@ func (ctx *VersionCtx) Version() (string, error) {
 		} else {
 			deployDef = ctx.root.Default.Deploy
 		}
-		err := tpl.calcActualBuildDefinitionFor(&deployDef.BuildDefinition, true)
-		if err != nil {
+		if r, err = tpl.resolveActualBuildDefinitionFor(&deployDef.BuildDefinition, true); err != nil {
 			return "", errors.Wrapf(err, "failed to calculate actual deploy definition")
 		}
-		tpl.buildCtx.BuildArgs = deployDef.Args
 		tpl.deployCtx.BuildContext = tpl.buildCtx
 	} else {
 		var buildDef types.BuildDefinition
@@ -125,15 +122,20 @@ func (ctx *VersionCtx) Version() (string, error) {
 		} else {
 			buildDef = ctx.root.Default.Build
 		}
-		err := tpl.calcActualBuildDefinitionFor(&buildDef, false)
-		if err != nil {
+		if r, err = tpl.resolveActualBuildDefinitionFor(&buildDef, false); err != nil {
 			return "", errors.Wrapf(err, "failed to calculate actual build definition")
 		}
-		tpl.buildCtx.BuildArgs = buildDef.Args
 	}
-	tpl.extraVars = util.Data{"project:root.version": tpl.applyTemplate(ctx.root.Version)}
-	res := tpl.applyTemplate(unresolvedVersion)
-	return res, nil
+	rootVersion, err := r.resolveValue("project:root.version", ctx.root.Version)
+	if err != nil {
+		return "", err
+	}
+	tpl.extraVars = util.Data{"project:root.version": rootVersion}
+	version, err := r.resolveVersion(unresolvedVersion)
+	if err != nil {
+		return "", err
+	}
+	return version, r.unresolvedError()
 }
 
 func (ctx *VersionCtx) resolveVersionArgs() {
@@ -232,6 +234,11 @@ func (ctx *VersionCtx) SetVersionInConfig(version string) error {
 			}
 		}
 	}
+	if ctx.activeModule != nil {
+		if moduleFile, ok := ctx.root.ModuleVersionFile(ctx.activeModule.Name); ok {
+			return ctx.yaml.ModifyProperty(moduleFile, "version", version)
+		}
+	}
 	buildYamlFile := path.Join(ctx.root.RootDirPath(), types.BuildConfigFileName)
 	path := "version"
 	if moduleIdx >= 0 && (len(ctx.root.Modules) > 1 || (ctx.activeModule.Version != "" && ctx.root.Version == "")) {
@@ -0,0 +1,165 @@
+package welder
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/simple-container-com/welder/pkg/git"
+)
+
+// VersionBump defines part of the version to bump
+type VersionBump int
+
+const (
+	NoBump VersionBump = iota
+	PatchBump
+	MinorBump
+	MajorBump
+)
+
+var (
+	conventionalCommitRegex      = regexp.MustCompile(`^(?P<type>[a-zA-Z]+)(?:\((?P<scope>[^)]*)\))?(?P<breaking>!)?:\s+(?P<description>.*)`)
+	breakingChangeFooterRegex    = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s+(?P<note>(?s:.*))`)
+	conventionalCommitPatchTypes = map[string]bool{"fix": true, "perf": true}
+)
+
+// conventionalCommit defines commit message parsed according to Conventional Commits
+// (e.g. `feat(api)!: drop v1 endpoints`)
+type conventionalCommit struct {
+	Type         string
+	Scope        string
+	Description  string
+	Breaking     bool
+	BreakingNote string // text of `BREAKING CHANGE:` footer (if present)
+}
+
+// parseConventionalCommit parses commit message, returns false if message doesn't follow Conventional Commits
+func parseConventionalCommit(message string) (conventionalCommit, bool) {
+	res := conventionalCommit{}
+	lines := strings.SplitN(message, "\n", 2)
+	match := conventionalCommitRegex.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if match == nil {
+		return res, false
+	}
+	res.Type = strings.ToLower(match[1])
+	res.Scope = match[2]
+	res.Breaking = match[3] != ""
+	res.Description = strings.TrimSpace(match[4])
+	if len(lines) > 1 {
+		if footer := breakingChangeFooterRegex.FindStringSubmatch(lines[1]); footer != nil {
+			res.Breaking = true
+			res.BreakingNote = strings.TrimSpace(footer[1])
+		}
+	}
+	return res, true
+}
+
+func (b VersionBump) String() string {
+	switch b {
+	case PatchBump:
+		return "patch"
+	case MinorBump:
+		return "minor"
+	case MajorBump:
+		return "major"
+	}
+	return "none"
+}
+
+// AutoBumpResult describes version bump calculated from commits
+type AutoBumpResult struct {
+	Since   string // tag commits are scanned since (empty if there are no matching tags)
+	Commits int    // number of scanned commits
+	Bump    VersionBump
+}
+
+// ConventionalCommitBump returns version bump required by the commit message according to Conventional Commits:
+// breaking changes (`feat!:` or `BREAKING CHANGE:` footer) bump major, `feat` bumps minor, `fix` and `perf` bump patch
+func ConventionalCommitBump(message string) VersionBump {
+	commit, ok := parseConventionalCommit(message)
+	if !ok {
+		return NoBump
+	}
+	if commit.Breaking {
+		return MajorBump
+	} else if commit.Type == "feat" {
+		return MinorBump
+	} else if conventionalCommitPatchTypes[commit.Type] {
+		return PatchBump
+	}
+	return NoBump
+}
+
+// CalcAutoBump calculates version bump from commits made since the latest tag matching the pattern (e.g. `api-*`),
+// only commits changing files of the active module are considered when module is active
+func (ctx *VersionCtx) CalcAutoBump(tagPattern string) (AutoBumpResult, error) {
+	res := AutoBumpResult{}
+	gitClient := ctx.buildCtx.GitClient()
+	if gitClient == nil {
+		return res, errors.New("git repository is not found")
+	}
+	description, err := gitClient.Describe(tagPattern)
+	if err != nil {
+		return res, errors.Wrapf(err, "failed to find the latest version tag")
+	}
+	res.Since = description.Tag
+	paths, err := ctx.gitPaths(gitClient)
+	if err != nil {
+		return res, err
+	}
+	commits, err := gitClient.Commits(description.Tag, "", paths...)
+	if err != nil {
+		return res, errors.Wrapf(err, "failed to read commits since %q", description.Tag)
+	}
+	res.Commits = len(commits)
+	for _, commit := range commits {
+		if bump := ConventionalCommitBump(commit.Message); bump > res.Bump {
+			res.Bump = bump
+		}
+	}
+	return res, nil
+}
+
+// BumpAuto bumps version according to Conventional Commits made since the latest tag matching the pattern
+// (version is left as is when there are no commits requiring the bump)
+func (ctx *VersionCtx) BumpAuto(tagPattern string) (AutoBumpResult, error) {
+	res, err := ctx.CalcAutoBump(tagPattern)
+	if err != nil {
+		return res, err
+	}
+	switch res.Bump {
+	case MajorBump:
+		err = ctx.BumpMajor()
+	case MinorBump:
+		err = ctx.BumpMinor()
+	case PatchBump:
+		err = ctx.BumpPatch()
+	}
+	return res, err
+}
+
+// gitPaths returns paths relative to git root commits should change to be considered
+// (path of the active module or of the project, none if the project is located at git root)
+func (ctx *VersionCtx) gitPaths(gitClient git.Git) ([]string, error) {
+	scope := ctx.root.RootDirPath()
+	if ctx.activeModule != nil && ctx.activeModule.Path != "" {
+		scope = filepath.Join(scope, ctx.activeModule.Path)
+	}
+	gitRoot, err := filepath.Abs(gitClient.Root())
+	if err != nil {
+		return nil, err
+	}
+	if scope, err = filepath.Abs(scope); err != nil {
+		return nil, err
+	}
+	relPath, err := filepath.Rel(gitRoot, scope)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to calculate path of %s within git repository", scope)
+	}
+	if relPath == "." {
+		return nil, nil
+	}
+	return []string{filepath.ToSlash(relPath)}, nil
+}
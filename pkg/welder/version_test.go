@@ -9,6 +9,7 @@ import (
 
 	. "github.com/onsi/gomega"
 
+	"github.com/simple-container-com/welder/pkg/git"
 	"github.com/simple-container-com/welder/pkg/git/mock"
 	"github.com/simple-container-com/welder/pkg/util"
 	. "github.com/simple-container-com/welder/pkg/welder/types"
@@ -74,6 +75,43 @@ func TestRootVersionBumpMajor(t *testing.T) {
 	Expect(version).To(Equal("1.0.0-1234567-${project:module.name}"))
 }
 
+func TestConventionalCommitBump(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(ConventionalCommitBump("fix: handle empty config")).To(Equal(PatchBump))
+	Expect(ConventionalCommitBump("perf(api): cache definitions")).To(Equal(PatchBump))
+	Expect(ConventionalCommitBump("feat(api): add endpoint")).To(Equal(MinorBump))
+	Expect(ConventionalCommitBump("feat!: drop v1 endpoints")).To(Equal(MajorBump))
+	Expect(ConventionalCommitBump("fix: rename option\n\nBREAKING CHANGE: option is renamed")).To(Equal(MajorBump))
+	Expect(ConventionalCommitBump("chore: update dependencies")).To(Equal(NoBump))
+	Expect(ConventionalCommitBump("Merge branch 'feature'")).To(Equal(NoBump))
+}
+
+func TestModuleVersionBumpAuto(t *testing.T) {
+	RegisterTestingT(t)
+	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/example")
+	defer cleanup()
+
+	versionCtx := readVersionContext(tmpProjectDir, "armory")
+	gitMock := versionCtx.buildCtx.GitClient().(*mock.GitMock)
+	gitMock.On("Root").Return(tmpProjectDir)
+	gitMock.On("Describe", "armory-*").Return(git.Description{Tag: "armory-0.0.2", Distance: 3}, nil)
+	gitMock.On("Commits", "armory-0.0.2", "", []string{"services/armory"}).Return([]git.CommitInfo{
+		{Message: "chore: update dependencies"},
+		{Message: "feat(armory): add endpoint"},
+		{Message: "fix: handle empty config"},
+	}, nil)
+
+	res, err := versionCtx.BumpAuto("armory-*")
+	Expect(err).To(BeNil())
+	Expect(res).To(Equal(AutoBumpResult{Since: "armory-0.0.2", Commits: 3, Bump: MinorBump}))
+
+	versionCtx = readVersionContext(tmpProjectDir, "armory")
+	version, err := versionCtx.Version()
+	Expect(err).To(BeNil())
+	Expect(version).To(Equal("0.1.0-1234567890f-test"))
+}
+
 func TestVersionFromArgs(t *testing.T) {
 	RegisterTestingT(t)
 	tmpProjectDir, cleanup := createTempExampleProject(t, "testdata/version-from-args")
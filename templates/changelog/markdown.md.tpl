@@ -0,0 +1,6 @@
+## {{ .Version }} ({{ .Date }})
+{{ range .Sections }}
+### {{ .Title }}
+{{ range .Scopes }}{{ $scope := .Name }}{{ range .Entries }}
+* {{ if $scope }}**{{ $scope }}:** {{ end }}{{ .Description }} ({{ .ShortHash }}){{ end }}{{ end }}
+{{ end }}